@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/services"
+)
+
+// RequestAccountDeletion schedules the current account for deletion after the grace period.
+// The password must be confirmed.
+func RequestAccountDeletion(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var input struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("username = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	deletion, err := services.ScheduleAccountDeletion(userID)
+	if err != nil {
+		if errors.Is(err, services.ErrDeletionPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Account deletion is already scheduled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule account deletion"})
+		return
+	}
+	recordAudit(c, models.AuditEvent{Event: services.AuditAccountDeletionRequested, TargetUserID: userID}, gin.H{"deletion_id": deletion.ID, "scheduled_for": deletion.ScheduledFor})
+
+	c.JSON(http.StatusAccepted, gin.H{"deletion": deletion})
+}
+
+// GetAccountDeletion returns the current account's most recent deletion request
+func GetAccountDeletion(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var deletion models.AccountDeletion
+	if err := database.DB.Where("user_id = ?", userID).Order("requested_at DESC").First(&deletion).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No account deletion requested"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deletion": deletion})
+}
+
+// CancelAccountDeletion cancels a scheduled deletion while it is still in its grace period
+func CancelAccountDeletion(c *gin.Context) {
+	userID := c.GetString("username")
+
+	result := database.DB.Model(&models.AccountDeletion{}).
+		Where("user_id = ? AND status = ? AND scheduled_for > ?", userID, models.DeletionStatusScheduled, time.Now()).
+		Update("status", models.DeletionStatusCancelled)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel account deletion"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No cancellable account deletion"})
+		return
+	}
+	recordAudit(c, models.AuditEvent{Event: services.AuditAccountDeletionCancelled, TargetUserID: userID}, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
+
+// GetDeletionReceipt returns the signed receipt of a completed deletion. The account no longer
+// exists by then, so the receipt is looked up by the deletion ID handed out when it was requested.
+func GetDeletionReceipt(c *gin.Context) {
+	deletionID := c.Param("id")
+
+	var deletion models.AccountDeletion
+	if err := database.DB.Where("id = ?", deletionID).First(&deletion).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deletion not found"})
+		return
+	}
+	if deletion.Status != models.DeletionStatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Deletion is " + deletion.Status, "scheduled_for": deletion.ScheduledFor})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"receipt":    deletion.Receipt,
+		"signature":  deletion.ReceiptSignature,
+		"public_key": services.KeyLogPublicKey(),
+	})
+}
+
+// VerifyEmail consumes the link from the verification email
+func VerifyEmail(c *gin.Context) {
+	user, err := services.VerifyEmail(c.Query("token"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidVerification) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified", "email": user.Email})
+}
+
+// ResendVerificationEmail sends a fresh verification link to the current user's email
+func ResendVerificationEmail(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already verified"})
+		return
+	}
+
+	if err := services.SendVerificationEmail(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// ChangePasswordInput carries the new password and the master key re-wrapped under the new KEK
+type ChangePasswordInput struct {
+	CurrentPassword    string `json:"current_password" binding:"required"`
+	NewPassword        string `json:"new_password" binding:"required"`
+	KEKSalt            string `json:"kek_salt" binding:"required"`
+	EncryptedMasterKey string `json:"encrypted_master_key" binding:"required"`
+	MasterKeyNonce     string `json:"master_key_nonce" binding:"required"`
+}
+
+// ChangePassword replaces the current user's password, signs out their other sessions and
+// sends a notice to their email
+func ChangePassword(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var input ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"password":             string(hashedPassword),
+		"kek_salt":             input.KEKSalt,
+		"encrypted_master_key": input.EncryptedMasterKey,
+		"master_key_nonce":     input.MasterKeyNonce,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	session := c.MustGet("session").(*models.Session)
+	revoked := services.RevokeOtherSessions(user.Username, session.ID)
+	recordAudit(c, models.AuditEvent{Event: services.AuditPasswordChanged, TargetUserID: user.Username}, gin.H{"revoked_sessions": revoked})
+	services.NotifyUser(user.Username, services.MailPasswordChanged, map[string]interface{}{
+		"Time": time.Now().Format(time.RFC1123),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed", "revoked_sessions": revoked})
+}
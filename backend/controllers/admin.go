@@ -0,0 +1,288 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/services"
+)
+
+// recordAdminAction stores an admin action in the audit log as an "admin.<action>" event
+func recordAdminAction(c *gin.Context, action, target string, details interface{}) {
+	event := models.AuditEvent{Event: services.AuditAdminPrefix + action, TargetUserID: target}
+	if target != "" {
+		event.TargetType = "user"
+		event.TargetID = target
+	}
+	recordAudit(c, event, details)
+}
+
+// findTargetUser loads the user named in the :username path parameter, or writes a 404
+func findTargetUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := database.DB.Where("username = ?", c.Param("username")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// AdminListUsers returns every user with their storage usage, image count and session count
+// Query Params: limit (default 50), cursor (page number), q (username prefix)
+func AdminListUsers(c *gin.Context) {
+	limit := 50
+	page := 0
+	fmt.Sscanf(c.Query("cursor"), "%d", &page)
+
+	query := database.DB.Model(&models.User{}).Order("username ASC")
+	if q := c.Query("q"); q != "" {
+		query = query.Where("username LIKE ?", q+"%")
+	}
+
+	var users []models.User
+	if err := query.Limit(limit + 1).Offset(page * limit).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+
+	var nextCursor string
+	if len(users) > limit {
+		users = users[:limit]
+		nextCursor = fmt.Sprintf("%d", page+1)
+	}
+
+	sessions := services.ActiveSessionCounts()
+	result := make([]gin.H, 0, len(users))
+	for _, u := range users {
+		used, _ := services.StorageUsage(u.Username)
+		var images int64
+		database.DB.Model(&models.Image{}).Where("user_id = ? AND is_deleted = ?", u.Username, false).Count(&images)
+
+		result = append(result, gin.H{
+			"username":        u.Username,
+			"email":           u.Email,
+			"created_at":      u.CreatedAt,
+			"is_admin":        u.IsAdmin,
+			"disabled":        u.Disabled,
+			"quota_bytes":     services.QuotaFor(u.Username),
+			"quota_override":  u.QuotaBytes,
+			"used_bytes":      used,
+			"image_count":     images,
+			"active_sessions": sessions[u.Username],
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": result, "next_cursor": nextCursor})
+}
+
+// AdminGetUser returns one user with their usage breakdown
+func AdminGetUser(c *gin.Context) {
+	user, ok := findTargetUser(c)
+	if !ok {
+		return
+	}
+
+	used, categories := services.StorageUsage(user.Username)
+	var images int64
+	database.DB.Model(&models.Image{}).Where("user_id = ? AND is_deleted = ?", user.Username, false).Count(&images)
+
+	c.JSON(http.StatusOK, gin.H{
+		"username":        user.Username,
+		"email":           user.Email,
+		"created_at":      user.CreatedAt,
+		"is_admin":        user.IsAdmin,
+		"disabled":        user.Disabled,
+		"discoverability": user.Discoverability,
+		"quota_bytes":     services.QuotaFor(user.Username),
+		"quota_override":  user.QuotaBytes,
+		"used_bytes":      used,
+		"categories":      categories,
+		"image_count":     images,
+		"active_sessions": services.ActiveSessionCounts()[user.Username],
+	})
+}
+
+// AdminDisableUser blocks a user from logging in and ends their sessions
+func AdminDisableUser(c *gin.Context) {
+	user, ok := findTargetUser(c)
+	if !ok {
+		return
+	}
+	if user.Username == c.GetString("username") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot disable your own account"})
+		return
+	}
+
+	if err := database.DB.Model(user).Update("disabled", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable user"})
+		return
+	}
+	revoked := services.RevokeSessions(user.Username)
+
+	recordAdminAction(c, "disable_user", user.Username, gin.H{"revoked_sessions": revoked})
+	c.JSON(http.StatusOK, gin.H{"message": "User disabled", "revoked_sessions": revoked})
+}
+
+// AdminEnableUser lets a disabled user log in again
+func AdminEnableUser(c *gin.Context) {
+	user, ok := findTargetUser(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Model(user).Update("disabled", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable user"})
+		return
+	}
+
+	recordAdminAction(c, "enable_user", user.Username, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "User enabled"})
+}
+
+// AdminForceLogout revokes every active session of a user
+func AdminForceLogout(c *gin.Context) {
+	user, ok := findTargetUser(c)
+	if !ok {
+		return
+	}
+
+	revoked := services.RevokeSessions(user.Username)
+
+	recordAdminAction(c, "force_logout", user.Username, gin.H{"revoked_sessions": revoked})
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked", "revoked_sessions": revoked})
+}
+
+// AdminSetQuota sets a user's quota override; quota_bytes 0 resets them to the server default
+// and -1 exempts them from any quota
+func AdminSetQuota(c *gin.Context) {
+	user, ok := findTargetUser(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		QuotaBytes *int64 `json:"quota_bytes" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if *input.QuotaBytes < models.QuotaUnlimited {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quota_bytes must be -1 (unlimited), 0 (server default) or a positive byte count"})
+		return
+	}
+
+	previous := user.QuotaBytes
+	if err := database.DB.Model(user).Update("quota_bytes", *input.QuotaBytes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quota"})
+		return
+	}
+
+	recordAdminAction(c, "set_quota", user.Username, gin.H{"previous": previous, "quota_bytes": *input.QuotaBytes})
+	c.JSON(http.StatusOK, gin.H{"quota_override": *input.QuotaBytes, "quota_bytes": services.QuotaFor(user.Username)})
+}
+
+// AdminGetFLStatus returns the federated learning aggregation state
+func AdminGetFLStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, services.FLStatus())
+}
+
+// AdminListModels returns the served model versions, aggregated models on disk and recent metrics
+func AdminListModels(c *gin.Context) {
+	var metadata []models.ModelMetadata
+	database.DB.Order("name ASC").Find(&metadata)
+
+	var metrics []models.ModelMetric
+	database.DB.Order("created_at DESC").Limit(20).Find(&metrics)
+
+	var channels []models.ModelChannel
+	database.DB.Order("model_name ASC, channel ASC").Find(&channels)
+
+	aggregated := []gin.H{}
+	if files, err := os.ReadDir(services.AggregatedModelsDir); err == nil {
+		for _, f := range files {
+			if f.IsDir() || filepath.Ext(f.Name()) != ".onnx" {
+				continue
+			}
+			if info, err := f.Info(); err == nil {
+				aggregated = append(aggregated, gin.H{"name": f.Name(), "size": info.Size(), "created_at": info.ModTime()})
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"models": metadata, "channels": channels, "aggregated": aggregated, "recent_metrics": metrics})
+}
+
+// AdminListModelVersions returns every registered version of a model with its lineage, metrics and channels
+func AdminListModelVersions(c *gin.Context) {
+	versions, err := services.ListModelVersions(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list model versions"})
+		return
+	}
+	if len(versions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+		return
+	}
+	response := gin.H{"model": c.Param("name"), "versions": versions}
+	if fl, err := services.GetFLModel(c.Param("name")); err == nil {
+		response["federated_learning"] = fl
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminRollbackModel makes a previously registered version of a model the stable one
+func AdminRollbackModel(c *gin.Context) {
+	name := c.Param("name")
+	var input struct {
+		Version string `json:"version" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous, err := services.RollbackModel(name, input.Version, c.GetString("username"))
+	if errors.Is(err, services.ErrUnknownModelVersion) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model version not found"})
+		return
+	}
+	if errors.Is(err, services.ErrModelChecksum) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stored model file failed its checksum; refusing to install it"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back model"})
+		return
+	}
+
+	details := gin.H{"version": input.Version}
+	if previous != nil {
+		details["previous_version"] = previous.Version
+	}
+	recordAudit(c, models.AuditEvent{Event: services.AuditAdminPrefix + "rollback_model", TargetType: "model", TargetID: name}, details)
+	c.JSON(http.StatusOK, gin.H{"model": name, "stable": input.Version, "previous": details["previous_version"]})
+}
+
+// AdminListActions returns the most recent admin actions from the audit log, optionally for one target user
+func AdminListActions(c *gin.Context) {
+	query := database.DB.Where("event LIKE ?", services.AuditAdminPrefix+"%").Order("id DESC").Limit(200)
+	if target := c.Query("target"); target != "" {
+		query = query.Where("target_user_id = ?", target)
+	}
+
+	var actions []models.AuditEvent
+	if err := query.Find(&actions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list admin actions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"actions": actions})
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/services"
+)
+
+// StartExport begins packaging the current user's account into a downloadable archive
+func StartExport(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var running int64
+	database.DB.Model(&models.ArchiveJob{}).
+		Where("user_id = ? AND kind = ? AND status IN ?", userID, models.ArchiveKindExport, []string{models.ArchiveStatusPending, models.ArchiveStatusRunning}).
+		Count(&running)
+	if running > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An export is already in progress"})
+		return
+	}
+
+	job, err := services.StartExport(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+// ListArchiveJobs returns the current user's export and import jobs, newest first
+func ListArchiveJobs(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var jobs []models.ArchiveJob
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(50).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetArchiveJob returns the progress of one export or import job
+func GetArchiveJob(c *gin.Context) {
+	jobID := c.Param("id")
+	userID := c.GetString("username")
+	if jobID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var job models.ArchiveJob
+	if err := database.DB.Where("id = ? AND user_id = ?", jobID, userID).First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+// DownloadExport streams a completed export archive
+func DownloadExport(c *gin.Context) {
+	jobID := c.Param("id")
+	userID := c.GetString("username")
+	if jobID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var job models.ArchiveJob
+	if err := database.DB.Where("id = ? AND user_id = ? AND kind = ?", jobID, userID, models.ArchiveKindExport).First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return
+	}
+	if job.Status != models.ArchiveStatusCompleted || job.FilePath == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Export is " + job.Status})
+		return
+	}
+
+	name := fmt.Sprintf("chithram-%s-%s.zip", userID, job.CreatedAt.Format("20060102"))
+	c.FileAttachment(job.FilePath, name)
+}
+
+// ImportArchive restores an exported archive (multipart field "archive") into the current,
+// still empty, account. kek_salt, encrypted_master_key and master_key_nonce may be sent to
+// replace the archived master key wrapping with one made from this account's password.
+// The import replaces the account's keys, so the password must be confirmed (form field "password").
+func ImportArchive(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var user models.User
+	if err := database.DB.Where("username = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(c.PostForm("password"))); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	file, err := c.FormFile("archive")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archive file required"})
+		return
+	}
+
+	var override *services.ArchiveKeyOverride
+	if c.PostForm("encrypted_master_key") != "" {
+		override = &services.ArchiveKeyOverride{
+			KEKSalt:            c.PostForm("kek_salt"),
+			EncryptedMasterKey: c.PostForm("encrypted_master_key"),
+			MasterKeyNonce:     c.PostForm("master_key_nonce"),
+		}
+		if override.KEKSalt == "" || override.MasterKeyNonce == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kek_salt, encrypted_master_key and master_key_nonce must be sent together"})
+			return
+		}
+	}
+
+	var running int64
+	database.DB.Model(&models.ArchiveJob{}).
+		Where("user_id = ? AND kind = ? AND status IN ?", userID, models.ArchiveKindImport, []string{models.ArchiveStatusPending, models.ArchiveStatusRunning}).
+		Count(&running)
+	if running > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An import is already in progress"})
+		return
+	}
+
+	archivePath := filepath.Join(services.ExportsDir, fmt.Sprintf("import-%s-%d.zip", uuid.New().String(), time.Now().Unix()))
+	if err := c.SaveUploadedFile(file, archivePath); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store archive"})
+		return
+	}
+
+	job, err := services.StartImport(userID, archivePath, override)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAccountNotFresh):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrQuotaExceeded):
+			respondQuotaExceeded(c, userID)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
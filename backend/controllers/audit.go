@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"chithram/models"
+	"chithram/services"
+)
+
+// recordAudit appends an audit event stamped with the request's IP and user agent. The actor
+// defaults to the authenticated user; identities the client merely claims are never recorded as
+// the actor.
+func recordAudit(c *gin.Context, event models.AuditEvent, details interface{}) {
+	if event.ActorID == "" {
+		event.ActorID = c.GetString("username")
+	}
+	event.IP = c.ClientIP()
+	event.UserAgent = c.GetHeader("User-Agent")
+	services.RecordAudit(event, details)
+}
+
+// parseAuditFilter reads the event, since, cursor and limit query params, or writes a 400
+func parseAuditFilter(c *gin.Context) (services.AuditFilter, bool) {
+	filter := services.AuditFilter{Event: c.Query("event")}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 timestamp"})
+			return filter, false
+		}
+		filter.Since = &t
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		before, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return filter, false
+		}
+		filter.Before = uint(before)
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	return filter, true
+}
+
+// respondAuditPage writes a page of audit events with the cursor of the next page
+func respondAuditPage(c *gin.Context, events []models.AuditEvent, next uint) {
+	var nextCursor string
+	if next > 0 {
+		nextCursor = strconv.FormatUint(uint64(next), 10)
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events, "next_cursor": nextCursor})
+}
+
+// GetMyAudit returns the audit events the current user performed or that targeted them.
+// The IP and user agent of events performed by someone else are withheld.
+// Query Params: event (type, or prefix ending in *), since (RFC 3339), cursor, limit
+func GetMyAudit(c *gin.Context) {
+	username := c.GetString("username")
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	filter.User = username
+
+	events, next, err := services.QueryAudit(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load audit log"})
+		return
+	}
+	for i := range events {
+		if events[i].ActorID != username {
+			events[i].IP = ""
+			events[i].UserAgent = ""
+		}
+	}
+
+	respondAuditPage(c, events, next)
+}
+
+// AdminListAudit returns audit events across all users.
+// Query Params: user (actor or target), event (type, or prefix ending in *), since, cursor, limit
+func AdminListAudit(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	filter.User = c.Query("user")
+
+	events, next, err := services.QueryAudit(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load audit log"})
+		return
+	}
+
+	respondAuditPage(c, events, next)
+}
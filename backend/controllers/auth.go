@@ -1,13 +1,19 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 
 	"chithram/database"
 	"chithram/models"
+	"chithram/services"
 )
 
 type SignupInput struct {
@@ -20,20 +26,33 @@ type SignupInput struct {
 	PublicKey           string `json:"public_key" binding:"required"`
 	EncryptedPrivateKey string `json:"encrypted_private_key" binding:"required"`
 	PrivateKeyNonce     string `json:"private_key_nonce" binding:"required"`
+	InviteCode          string `json:"invite_code"` // required when registration is invite-only
 }
 
 type LoginInput struct {
-	Email    string `json:"email" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Email    string               `json:"email" binding:"required"`
+	Password string               `json:"password" binding:"required"`
+	Device   *services.DeviceInfo `json:"device"` // optional; registers this install in the device list
 }
 
 func Signup(c *gin.Context) {
+	if services.RegistrationMode == services.RegistrationClosed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is closed"})
+		return
+	}
+
 	var input SignupInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	inviteCode := services.NormalizeInviteCode(input.InviteCode)
+	if services.RegistrationMode == services.RegistrationInvite && inviteCode == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "An invite code is required to sign up"})
+		return
+	}
+
 	// Check if username unique
 	var existingUser models.User
 	if err := database.DB.Where("username = ?", input.Username).First(&existingUser).Error; err == nil {
@@ -62,13 +81,34 @@ func Signup(c *gin.Context) {
 		PublicKey:           input.PublicKey,
 		EncryptedPrivateKey: input.EncryptedPrivateKey,
 		PrivateKeyNonce:     input.PrivateKeyNonce,
+		InviteCode:          inviteCode,
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if inviteCode != "" {
+			if err := services.RedeemInvite(tx, inviteCode); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		_, err := services.AddUserKey(tx, &user, models.KeyLogActionAdded)
+		return err
+	})
+	if errors.Is(err, services.ErrInviteInvalid) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired invite code"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create user"})
 		return
 	}
 
+	if err := services.SendVerificationEmail(&user); err != nil {
+		log.Printf("Failed to queue verification email for %s: %v", user.Username, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "User created successfully"})
 }
 
@@ -79,19 +119,61 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if input.Device != nil && !services.ValidDeviceInfo(*input.Device) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device"})
+		return
+	}
+
 	var user models.User
 	if err := database.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
+		recordAudit(c, models.AuditEvent{Event: services.AuditLoginFailure}, gin.H{"email": input.Email, "reason": "unknown_email"})
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		recordAudit(c, models.AuditEvent{Event: services.AuditLoginFailure, TargetUserID: user.Username}, gin.H{"reason": "wrong_password"})
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// In a real app, generate a JWT token here. For this demo, we return the encryption blobs immediately.
+	if user.Disabled {
+		recordAudit(c, models.AuditEvent{Event: services.AuditLoginFailure, TargetUserID: user.Username}, gin.H{"reason": "disabled"})
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+		return
+	}
+
+	userAgent := c.GetHeader("User-Agent")
+	newDevice := services.IsNewDevice(user.Username, userAgent)
+	token, session, err := services.CreateSession(user.Username, userAgent, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create session"})
+		return
+	}
+	var deviceID string
+	if input.Device != nil {
+		device, err := services.RegisterDevice(user.Username, *input.Device, session.ID)
+		if err != nil {
+			log.Printf("Failed to register device for %s: %v", user.Username, err)
+		} else {
+			deviceID = device.DeviceID
+		}
+	}
+	recordAudit(c, models.AuditEvent{Event: services.AuditLoginSuccess, ActorID: user.Username, TargetUserID: user.Username}, gin.H{"session_id": session.ID, "new_device": newDevice, "device_id": deviceID})
+	if newDevice {
+		services.NotifyUser(user.Username, services.MailNewDeviceLogin, map[string]interface{}{
+			"UserAgent": userAgent,
+			"IP":        session.IP,
+			"Time":      session.CreatedAt.Format(time.RFC1123),
+		})
+	}
+
+	// The session token authenticates requests to protected endpoints; the encryption blobs are returned as before.
 	c.JSON(http.StatusOK, gin.H{
+		"token":                 token,
+		"expires_at":            session.ExpiresAt,
+		"device_id":             deviceID,
+		"is_admin":              user.IsAdmin,
 		"username":              user.Username,
 		"email":                 user.Email,
 		"kek_salt":              user.KEKSalt,
@@ -100,5 +182,15 @@ func Login(c *gin.Context) {
 		"public_key":            user.PublicKey,
 		"encrypted_private_key": user.EncryptedPrivateKey,
 		"private_key_nonce":     user.PrivateKeyNonce,
+		"discoverability":       user.Discoverability,
+		"email_verified":        user.EmailVerifiedAt != nil,
 	})
 }
+
+// Logout revokes the session whose token authenticated the request
+func Logout(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	services.RevokeSession(token)
+	recordAudit(c, models.AuditEvent{Event: services.AuditLogout, TargetUserID: c.GetString("username")}, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+// areContacts reports whether two users have an accepted contact link in either direction
+func areContacts(a, b string) bool {
+	var count int64
+	database.DB.Model(&models.Contact{}).
+		Where("status = ? AND ((requester_id = ? AND addressee_id = ?) OR (requester_id = ? AND addressee_id = ?))",
+			models.ContactStatusAccepted, a, b, b, a).
+		Count(&count)
+	return count > 0
+}
+
+// contactUsernames returns the usernames of all accepted contacts of a user
+func contactUsernames(userID string) []string {
+	var contacts []models.Contact
+	database.DB.Where("status = ? AND (requester_id = ? OR addressee_id = ?)", models.ContactStatusAccepted, userID, userID).Find(&contacts)
+
+	usernames := make([]string, 0, len(contacts))
+	for _, ct := range contacts {
+		if ct.RequesterID == userID {
+			usernames = append(usernames, ct.AddresseeID)
+		} else {
+			usernames = append(usernames, ct.RequesterID)
+		}
+	}
+	return usernames
+}
+
+// canDiscover reports whether requester may see target in search results or fetch their public key by exact username
+func canDiscover(requester string, target *models.User) bool {
+	if requester == target.Username {
+		return true
+	}
+	if target.Discoverability == models.DiscoverabilityContacts {
+		return requester != "" && areContacts(requester, target.Username)
+	}
+	return true
+}
+
+// ListContacts returns the current user's accepted contacts
+func ListContacts(c *gin.Context) {
+	userID := c.GetString("username")
+
+	c.JSON(http.StatusOK, gin.H{"contacts": contactUsernames(userID)})
+}
+
+// ListContactRequests returns pending contact requests sent to and by the current user
+func ListContactRequests(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var incoming []models.Contact
+	if err := database.DB.Where("addressee_id = ? AND status = ?", userID, models.ContactStatusPending).Order("created_at DESC").Find(&incoming).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list contact requests"})
+		return
+	}
+
+	var outgoing []models.Contact
+	if err := database.DB.Where("requester_id = ? AND status = ?", userID, models.ContactStatusPending).Order("created_at DESC").Find(&outgoing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list contact requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"incoming": incoming, "outgoing": outgoing})
+}
+
+// SendContactRequest asks another user to become a contact.
+// If that user already asked the current user, the existing request is accepted instead.
+// Unknown users, users who blocked the requester and users the requester can't discover all get
+// the same 404, and requests share the search rate limit, so this can't be used to probe usernames.
+func SendContactRequest(c *gin.Context) {
+	userID := c.GetString("username")
+	if !searchLimiter.Allow("ip:"+c.ClientIP()) || !searchLimiter.Allow("user:"+userID) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please slow down"})
+		return
+	}
+
+	var input struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Username == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot add yourself as a contact"})
+		return
+	}
+
+	var target models.User
+	if err := database.DB.Where("username = ?", input.Username).First(&target).Error; err != nil ||
+		!canDiscover(userID, &target) || isBlocked(target.Username, userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var existing models.Contact
+	if err := database.DB.Where("requester_id = ? AND addressee_id = ?", userID, target.Username).First(&existing).Error; err == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Contact request already " + existing.Status, "contact": existing})
+		return
+	}
+
+	// Reverse request pending: treat this as acceptance
+	var reverse models.Contact
+	if err := database.DB.Where("requester_id = ? AND addressee_id = ?", target.Username, userID).First(&reverse).Error; err == nil {
+		if reverse.Status == models.ContactStatusPending {
+			now := time.Now()
+			reverse.Status = models.ContactStatusAccepted
+			reverse.AcceptedAt = &now
+			if err := database.DB.Save(&reverse).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept contact request"})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Contact request accepted", "contact": reverse})
+		return
+	}
+
+	contact := models.Contact{
+		RequesterID: userID,
+		AddresseeID: target.Username,
+		Status:      models.ContactStatusPending,
+		CreatedAt:   time.Now(),
+	}
+	if err := database.DB.Create(&contact).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send contact request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contact request sent", "contact": contact})
+}
+
+// AcceptContactRequest accepts a pending request addressed to the current user
+func AcceptContactRequest(c *gin.Context) {
+	requestID := c.Param("id")
+	userID := c.GetString("username")
+	if requestID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var contact models.Contact
+	if err := database.DB.Where("id = ? AND addressee_id = ? AND status = ?", requestID, userID, models.ContactStatusPending).First(&contact).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contact request not found"})
+		return
+	}
+
+	now := time.Now()
+	contact.Status = models.ContactStatusAccepted
+	contact.AcceptedAt = &now
+	if err := database.DB.Save(&contact).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept contact request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contact request accepted", "contact": contact})
+}
+
+// DeclineContactRequest drops a pending request addressed to the current user
+func DeclineContactRequest(c *gin.Context) {
+	requestID := c.Param("id")
+	userID := c.GetString("username")
+	if requestID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	result := database.DB.Where("id = ? AND addressee_id = ? AND status = ?", requestID, userID, models.ContactStatusPending).Delete(&models.Contact{})
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contact request not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contact request declined"})
+}
+
+// RemoveContact removes a contact (or cancels an outgoing request) in either direction
+func RemoveContact(c *gin.Context) {
+	username := c.Param("username")
+	userID := c.GetString("username")
+	if username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username required"})
+		return
+	}
+
+	result := database.DB.
+		Where("(requester_id = ? AND addressee_id = ?) OR (requester_id = ? AND addressee_id = ?)", userID, username, username, userID).
+		Delete(&models.Contact{})
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contact not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contact removed"})
+}
+
+// UpdateDiscoverability changes who can find the current user through search
+func UpdateDiscoverability(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var input struct {
+		Discoverability string `json:"discoverability" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch input.Discoverability {
+	case models.DiscoverabilityPublic, models.DiscoverabilityContacts, models.DiscoverabilityExact:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "discoverability must be public, contacts or exact"})
+		return
+	}
+
+	result := database.DB.Model(&models.User{}).Where("username = ?", userID).Update("discoverability", input.Discoverability)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update discoverability"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"discoverability": input.Discoverability})
+}
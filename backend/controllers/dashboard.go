@@ -3,6 +3,8 @@ package controllers
 import (
 	"chithram/database"
 	"chithram/models"
+	"chithram/services"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -10,9 +12,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// GetDashboard charts the evaluation history of one FL model
+// Query Params: model (optional, defaults to the face detection model)
 func GetDashboard(c *gin.Context) {
+	modelName := c.DefaultQuery("model", services.DefaultFLModel)
+
 	var metrics []models.ModelMetric
-	database.DB.Order("created_at ASC").Find(&metrics)
+	database.DB.Where("model_name = ?", modelName).Order("created_at ASC").Find(&metrics)
 
 	// Prepare data for the chart
 	var labelList []string
@@ -28,6 +34,7 @@ func GetDashboard(c *gin.Context) {
 	labelStr := "[" + strings.Join(labelList, ",") + "]"
 	accStr := "[" + strings.Join(accList, ",") + "]"
 	lossStr := "[" + strings.Join(lossList, ",") + "]"
+	modelJSON, _ := json.Marshal(modelName) // escapes <, > and & for the script block
 
 	html := fmt.Sprintf(`
 <!DOCTYPE html>
@@ -95,6 +102,24 @@ func GetDashboard(c *gin.Context) {
         canvas {
             margin-top: 20px;
         }
+        table {
+            width: 100%%;
+            margin-top: 30px;
+            border-collapse: collapse;
+            font-size: 0.875rem;
+        }
+        th, td {
+            padding: 8px;
+            border-bottom: 1px solid #334155;
+            text-align: left;
+        }
+        th {
+            color: #94a3b8;
+            text-transform: uppercase;
+            letter-spacing: 0.05em;
+        }
+        .failed { color: #f472b6; }
+        .succeeded { color: #38bdf8; }
     </style>
 </head>
 <body>
@@ -111,6 +136,10 @@ func GetDashboard(c *gin.Context) {
             </div>
         </div>
         <canvas id="accuracyChart" height="150"></canvas>
+        <table>
+            <thead><tr><th>Job</th><th>Version</th><th>Status</th><th>Attempts</th><th>Accuracy</th><th>Error</th></tr></thead>
+            <tbody id="evalJobs"></tbody>
+        </table>
     </div>
 
     <script>
@@ -163,10 +192,32 @@ func GetDashboard(c *gin.Context) {
                 }
             }
         });
+
+        // Follow the evaluation queue
+        async function loadJobs() {
+            const res = await fetch('/fl/jobs?model=' + encodeURIComponent(%s));
+            if (!res.ok) return;
+            const { jobs } = await res.json();
+            const body = document.getElementById('evalJobs');
+            body.replaceChildren(...(jobs || []).slice(0, 10).map(job => {
+                const row = document.createElement('tr');
+                const cells = [job.id, job.version, job.status, job.attempts,
+                    job.accuracy != null ? job.accuracy.toFixed(4) : '', job.error || ''];
+                for (const value of cells) {
+                    const cell = document.createElement('td');
+                    cell.textContent = value;
+                    row.appendChild(cell);
+                }
+                row.className = job.status;
+                return row;
+            }));
+        }
+        loadJobs();
+        setInterval(loadJobs, 10000);
     </script>
 </body>
 </html>
-`, len(metrics), getLatestAcc(metrics)*100, labelStr, accStr, lossStr)
+`, len(metrics), getLatestAcc(metrics)*100, labelStr, accStr, lossStr, modelJSON)
 
 	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
 }
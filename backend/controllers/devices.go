@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/services"
+)
+
+// ListDevices returns the current user's devices with their backup health, most recently seen first
+func ListDevices(c *gin.Context) {
+	username := c.GetString("username")
+	session := c.MustGet("session").(*models.Session)
+
+	var devices []models.Device
+	if err := database.DB.Where("user_id = ?", username).Order("last_seen_at DESC").Find(&devices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list devices"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(devices))
+	for i := range devices {
+		d := &devices[i]
+		result = append(result, gin.H{
+			"device":        d,
+			"backup_health": services.BackupHealth(d),
+			"current":       session.DeviceID != "" && session.DeviceID == d.DeviceID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"devices": result, "stale_after": services.BackupStaleAfter.String()})
+}
+
+// RegisterDevice registers the calling install under :device_id, or updates its details, and
+// links the current session to it. Lets clients that logged in without device info register later.
+func RegisterDevice(c *gin.Context) {
+	var info services.DeviceInfo
+	if err := c.ShouldBindJSON(&info); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	info.DeviceID = c.Param("device_id")
+
+	session := c.MustGet("session").(*models.Session)
+	device, err := services.RegisterDevice(c.GetString("username"), info, session.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDevice) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register device"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"device": device, "backup_health": services.BackupHealth(device)})
+}
+
+// ReportDeviceStatus stores the backup progress of one of the current user's devices.
+// Body: last_sync_cursor, last_backup_at (RFC 3339), pending_uploads; omitted fields are unchanged.
+func ReportDeviceStatus(c *gin.Context) {
+	var status services.DeviceStatus
+	if err := c.ShouldBindJSON(&status); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	device, err := services.ReportDeviceStatus(c.GetString("username"), c.Param("device_id"), status)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		case errors.Is(err, services.ErrInvalidDevice):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pending_uploads must not be negative"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update device status"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"device": device, "backup_health": services.BackupHealth(device)})
+}
+
+// RemoveDevice forgets one of the current user's devices and signs it out
+func RemoveDevice(c *gin.Context) {
+	username := c.GetString("username")
+	deviceID := c.Param("device_id")
+
+	revoked, err := services.RemoveDevice(username, deviceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove device"})
+		return
+	}
+
+	recordAudit(c, models.AuditEvent{Event: services.AuditDeviceRemoved, TargetUserID: username, TargetType: "device", TargetID: deviceID}, gin.H{"revoked_sessions": revoked})
+	c.JSON(http.StatusOK, gin.H{"message": "Device removed", "revoked_sessions": revoked})
+}
@@ -1,51 +1,287 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
+	"chithram/database"
+	"chithram/models"
 	"chithram/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// UploadLocalUpdate handles the reception of locally trained model updates
+// findFLModel resolves the FL model a request names, defaulting to the default model, or writes a 404
+func findFLModel(c *gin.Context, name string) (*services.FLModel, bool) {
+	model, err := services.GetFLModel(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model is not trained by federated learning"})
+		return nil, false
+	}
+	return model, true
+}
+
+// GetCurrentRound returns the FL round clients should train for and submit updates to
+// Query Params: model (optional, defaults to the face detection model)
+func GetCurrentRound(c *gin.Context) {
+	model, ok := findFLModel(c, c.Query("model"))
+	if !ok {
+		return
+	}
+	round, err := services.CurrentRound(model)
+	if errors.Is(err, services.ErrPrivacyBudgetExhausted) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Training for this model has ended: its privacy budget is spent", "code": "privacy_budget_exhausted"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load FL round"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"round": round})
+}
+
+// AdminListRounds returns the most recent FL rounds
+// Query Params: model (optional)
+func AdminListRounds(c *gin.Context) {
+	query := database.DB.Order("id DESC").Limit(50)
+	if model := c.Query("model"); model != "" {
+		query = query.Where("model_name = ?", model)
+	}
+
+	var rounds []models.FLRound
+	if err := query.Find(&rounds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list FL rounds"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rounds": rounds})
+}
+
+// AdminListRoundUpdates returns the updates of one FL round with their training metadata
+func AdminListRoundUpdates(c *gin.Context) {
+	var round models.FLRound
+	if err := database.DB.First(&round, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "FL round not found"})
+		return
+	}
+
+	var updates []models.FLUpdate
+	if err := database.DB.Where("round_id = ?", round.ID).Order("id ASC").Find(&updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list round updates"})
+		return
+	}
+
+	var totalWeight float64
+	for _, u := range updates {
+		totalWeight += u.Weight
+	}
+	c.JSON(http.StatusOK, gin.H{"round": round, "updates": updates, "total_weight": totalWeight})
+}
+
+// ListEvalJobs returns the most recent model evaluation jobs, newest first. The evaluator output
+// is left out, since it can hold server paths and tracebacks; admins read it from AdminGetEvalJob.
+// Query Params: model, status, round_id (all optional)
+func ListEvalJobs(c *gin.Context) {
+	query := database.DB.Omit("output").Order("id DESC").Limit(100)
+	if model := c.Query("model"); model != "" {
+		query = query.Where("model_name = ?", model)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if roundID := c.Query("round_id"); roundID != "" {
+		query = query.Where("round_id = ?", roundID)
+	}
+
+	var jobs []models.EvalJob
+	if err := query.Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list evaluation jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetEvalJob returns one model evaluation job without its evaluator output
+func GetEvalJob(c *gin.Context) {
+	getEvalJob(c, database.DB.Omit("output"))
+}
+
+// AdminGetEvalJob returns one model evaluation job with the evaluator output of its last attempt
+func AdminGetEvalJob(c *gin.Context) {
+	getEvalJob(c, database.DB)
+}
+
+// getEvalJob writes the job named by the id path parameter, loaded through query
+func getEvalJob(c *gin.Context, query *gorm.DB) {
+	var job models.EvalJob
+	if err := query.First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+// AdminListQuarantine returns the most recently quarantined FL updates and why they were rejected
+func AdminListQuarantine(c *gin.Context) {
+	var updates []models.FLUpdate
+	if err := database.DB.Where("status = ?", models.FLUpdateQuarantined).Order("id DESC").Limit(200).Find(&updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list quarantined updates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updates": updates})
+}
+
+// ListMyUpdates returns the updates a user contributed, newest first
+func ListMyUpdates(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var updates []models.FLUpdate
+	if err := database.DB.Where("user_id = ?", userID).Order("id DESC").Limit(100).Find(&updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list updates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updates": updates})
+}
+
+// UploadLocalUpdate handles the reception of locally trained model updates.
+// Form fields: model (file), model_name (optional, defaults to the face detection model),
+// round_id from GET /fl/round?model=<model_name>, and metadata, a JSON object with
+// sample_count, epochs, local_loss, local_accuracy, base_version, app_version and training_seconds.
+// base_version may also be sent as its own form field. Each user may submit one update per round.
 func UploadLocalUpdate(c *gin.Context) {
-	// 1. Receive the file
+	// 1. Receive the file, refusing bodies far beyond the update size limit before they hit disk
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxUpdateBytes+1<<20)
 	file, err := c.FormFile("model")
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			log.Printf("Refused FL update from %s: body exceeds %d bytes", c.ClientIP(), services.MaxUpdateBytes)
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Update exceeds %d bytes", services.MaxUpdateBytes), "code": services.RejectTooLarge})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No model file provided"})
 		return
 	}
 
-	// 2. Validate (optional, check size/extension)
-	ext := filepath.Ext(file.Filename)
-	if ext != ".onnx" && ext != ".pt" && ext != ".tflite" {
-		// allowing multiple formats for now, though ONNX is preferred based on context
+	roundID, err := strconv.ParseUint(c.PostForm("round_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "round_id is required"})
+		return
+	}
+	meta, ok := bindUpdateMetadata(c)
+	if !ok {
+		return
+	}
+
+	model, ok := findFLModel(c, c.PostForm("model_name"))
+	if !ok {
+		return
 	}
 
-	// 3. Save to a temporary "pending" directory managed by the FL service
+	userID := c.GetString("username")
+	round, err := services.CheckRoundUpdate(uint(roundID), model.Name, meta.BaseVersion, userID)
+	if err != nil {
+		respondRoundError(c, err)
+		return
+	}
+
+	// 2. Save to the round's pending directory managed by the FL service
 	// We use a timestamp-based name to avoid collisions
-	filename := fmt.Sprintf("update_%d_%s", time.Now().UnixNano(), file.Filename)
-	savePath := filepath.Join(services.PendingUpdatesDir, filename)
+	filename := fmt.Sprintf("update_%d_%s", time.Now().UnixNano(), filepath.Base(file.Filename))
+	savePath := filepath.Join(services.RoundUpdatesDir(round), filename)
 
 	if err := c.SaveUploadedFile(file, savePath); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save model update"})
 		return
 	}
 
-	// 4. Trigger check (optional, or just let the background worker handle it)
-	// services.CheckForAggregation()
+	// 3. Validate size, format and contents; rejected updates are quarantined
+	rejection := services.CheckUpdateFormat(file.Filename)
+	if rejection == nil && file.Size > services.MaxUpdateBytes {
+		rejection = &services.UpdateRejection{Code: services.RejectTooLarge, Reason: fmt.Sprintf("update is %d bytes, limit is %d", file.Size, services.MaxUpdateBytes)}
+	}
+	if rejection == nil {
+		rejection, err = services.ValidateUpdate(round, savePath)
+		if err != nil {
+			os.Remove(savePath)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Update validation is unavailable, try again later"})
+			return
+		}
+	}
+	if rejection != nil {
+		update, err := services.QuarantineUpdate(round, userID, savePath, file.Size, meta, rejection)
+		if err != nil {
+			os.Remove(savePath)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record rejected update"})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Update rejected: " + rejection.Reason, "code": rejection.Code, "update_id": update.ID})
+		return
+	}
+
+	// 4. Register it with the round; the background worker aggregates once the round is ready
+	update, err := services.RecordRoundUpdate(round, userID, savePath, file.Size, meta)
+	if err != nil {
+		os.Remove(savePath)
+		respondRoundError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Model update received successfully", "id": filename, "update_id": update.ID, "round_id": round.ID, "weight": update.Weight})
+}
+
+// bindUpdateMetadata reads and validates the training metadata form fields of an update, or
+// writes a 400
+func bindUpdateMetadata(c *gin.Context) (services.FLUpdateMetadata, bool) {
+	var meta services.FLUpdateMetadata
+	if raw := c.PostForm("metadata"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "metadata must be a JSON object"})
+			return meta, false
+		}
+	}
+	if meta.BaseVersion == "" {
+		meta.BaseVersion = c.PostForm("base_version")
+	}
+	if err := meta.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metadata: counts and durations must not be negative, metrics must be finite and accuracy within [0, 1]"})
+		return meta, false
+	}
+	return meta, true
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Model update received successfully", "id": filename})
+// respondRoundError writes the response for an update the current round won't accept
+func respondRoundError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRoundStale):
+		c.JSON(http.StatusConflict, gin.H{"error": "Stale update: fetch the current round and train from its base model", "code": "stale_round"})
+	case errors.Is(err, services.ErrRoundModelMismatch):
+		c.JSON(http.StatusConflict, gin.H{"error": "round_id belongs to a different model than model_name", "code": "wrong_model"})
+	case errors.Is(err, services.ErrDuplicateUpdate):
+		c.JSON(http.StatusConflict, gin.H{"error": "An update was already submitted to this round", "code": "duplicate_update"})
+	case errors.Is(err, services.ErrSecureAggregationRequired):
+		c.JSON(http.StatusConflict, gin.H{"error": "This round only accepts masked updates through secure aggregation", "code": "secure_aggregation_required"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record model update"})
+	}
 }
 
-// GetGlobalModel serves the current global model to clients
+// GetGlobalModel serves the stable version of an FL model, the base clients train from
+// Query Params: model (optional, defaults to the face detection model)
 func GetGlobalModel(c *gin.Context) {
-	modelPath := services.CurrentGlobalModelPath
-	if modelPath == "" {
+	model, ok := findFLModel(c, c.Query("model"))
+	if !ok {
+		return
+	}
+	modelPath := services.ServedModelPath(model.Name)
+	if _, err := os.Stat(modelPath); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No global model available yet"})
 		return
 	}
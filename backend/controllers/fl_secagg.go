@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"chithram/secagg"
+	"chithram/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The secure aggregation endpoints run the secagg protocol for rounds of models configured with
+// secure_aggregation. Participants poll GET /fl/rounds/:id/secagg for the current phase and
+// answer it once. Every request requires a session, and the participant is the session's user.
+
+// secAggParticipant reads the round ID and participant of a protocol request, or writes a 400
+func secAggParticipant(c *gin.Context) (uint, string, bool) {
+	roundID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid round ID"})
+		return 0, "", false
+	}
+	return uint(roundID), c.GetString("username"), true
+}
+
+// respondSecAggError writes the response for a protocol message the round won't accept
+func respondSecAggError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotSecureRound):
+		c.JSON(http.StatusConflict, gin.H{"error": "This round does not use secure aggregation", "code": "not_secure_round"})
+	case errors.Is(err, secagg.ErrWrongPhase):
+		c.JSON(http.StatusConflict, gin.H{"error": "The round is not in the phase this message belongs to", "code": "wrong_phase"})
+	case errors.Is(err, secagg.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": "Already submitted in this phase", "code": "duplicate_submission"})
+	case errors.Is(err, secagg.ErrNotParticipant):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a participant in this phase of the round", "code": "not_participant"})
+	case errors.Is(err, secagg.ErrInvalidMessage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "invalid_message"})
+	case errors.Is(err, services.ErrRoundStale), errors.Is(err, services.ErrDuplicateUpdate):
+		respondRoundError(c, err)
+	default:
+		log.Printf("Secure aggregation request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process secure aggregation message"})
+	}
+}
+
+// GetSecAggState returns the phase of a secure round and what the caller needs to answer it: the
+// tensor layout of the vector to mask, the roster of participants' keys, the encrypted shares
+// addressed to the caller, and the survivors to unmask
+func GetSecAggState(c *gin.Context) {
+	roundID, userID, ok := secAggParticipant(c)
+	if !ok {
+		return
+	}
+	status, err := services.SecAggState(roundID, userID)
+	if err != nil {
+		respondSecAggError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// SubmitSecAggKeys joins a secure round in its keys phase
+// Body: {"enc_key": base64, "mask_key": base64, "base_version": string}, X25519 public keys
+func SubmitSecAggKeys(c *gin.Context) {
+	roundID, userID, ok := secAggParticipant(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		secagg.PublicKeys
+		BaseVersion string `json:"base_version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := services.SecAggSubmitKeys(roundID, userID, req.BaseVersion, req.PublicKeys); err != nil {
+		respondSecAggError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Joined secure aggregation round", "round_id": roundID})
+}
+
+// SubmitSecAggShares answers the shares phase
+// Body: {"shares": {"<recipient username>": base64 ciphertext, ...}} with one entry per other participant
+func SubmitSecAggShares(c *gin.Context) {
+	roundID, userID, ok := secAggParticipant(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Shares map[string][]byte `json:"shares" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := services.SecAggSubmitShares(roundID, userID, req.Shares); err != nil {
+		respondSecAggError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Shares received"})
+}
+
+// SubmitSecAggMasked answers the masked phase with the participant's masked update
+// Form fields: masked (file of little-endian uint64s) and metadata, as for POST /fl/update
+func SubmitSecAggMasked(c *gin.Context) {
+	roundID, userID, ok := secAggParticipant(c)
+	if !ok {
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxUpdateBytes+1<<20)
+	file, err := c.FormFile("masked")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Update exceeds %d bytes", services.MaxUpdateBytes), "code": services.RejectTooLarge})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No masked update provided"})
+		return
+	}
+	meta, ok := bindUpdateMetadata(c)
+	if !ok {
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read masked update"})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read masked update"})
+		return
+	}
+
+	update, err := services.SecAggSubmitMasked(roundID, userID, data, meta)
+	if err != nil {
+		respondSecAggError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Masked update received", "update_id": update.ID, "round_id": roundID})
+}
+
+// SubmitSecAggUnmask answers the unmask phase
+// Body: {"mask_key_shares": {...}, "seed_shares": {...}}, shares keyed by the participant they belong to
+func SubmitSecAggUnmask(c *gin.Context) {
+	roundID, userID, ok := secAggParticipant(c)
+	if !ok {
+		return
+	}
+	var resp secagg.UnmaskResponse
+	if err := c.ShouldBindJSON(&resp); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := services.SecAggSubmitUnmask(roundID, userID, &resp); err != nil {
+		respondSecAggError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Unmask shares received"})
+}
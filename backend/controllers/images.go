@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -27,6 +28,29 @@ type AlbumResponse struct {
 	CoverImage string `json:"cover_image_url"`
 }
 
+// imageObjectName returns the storage path of one variant of a user's image,
+// using the same layout as GenerateUploadURLs ("original" or a thumbnail such as "thumb_256")
+func imageObjectName(userID, imageID, variant string) string {
+	if variant == "original" {
+		return fmt.Sprintf("%s/images/originals/%s.enc", userID, imageID)
+	}
+	return fmt.Sprintf("%s/images/thumbnails/%s_%s.enc", userID, imageID, variant)
+}
+
+// imageVariants lists every stored variant of an image
+var imageVariants = []string{"original", "thumb_1024", "thumb_256", "thumb_64"}
+
+// uploadObjectName returns the storage path for a variant accepted by GenerateUploadURLs,
+// including the per-user "faces" and "semantic" metadata blobs
+func uploadObjectName(userID, imageID, variant string) string {
+	if variant == "faces" {
+		return fmt.Sprintf("%s/metadata/faces.enc", userID)
+	} else if variant == "semantic" {
+		return fmt.Sprintf("%s/metadata/semantic.enc", userID)
+	}
+	return imageObjectName(userID, imageID, variant)
+}
+
 // RegisterOrUpdateImage registers a new image or updates an existing one (upsert).
 func RegisterOrUpdateImage(c *gin.Context) {
 	var input models.Image
@@ -34,6 +58,15 @@ func RegisterOrUpdateImage(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	input.UserID = c.GetString("username")
+
+	// The upsert is keyed on image_id alone, so refuse to overwrite another user's image
+	var taken int64
+	database.DB.Model(&models.Image{}).Where("image_id = ? AND user_id != ?", input.ImageID, input.UserID).Count(&taken)
+	if taken > 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
 
 	// Set timestamps if not provided
 	now := time.Now()
@@ -45,6 +78,14 @@ func RegisterOrUpdateImage(c *gin.Context) {
 	}
 	input.ModifiedAt = now
 
+	// Account for the original if it has already been uploaded (thumbnails are counted on finalize)
+	if !input.IsDeleted {
+		if _, err := services.RecordUpload(input.UserID, imageObjectName(input.UserID, input.ImageID, "original")); errors.Is(err, services.ErrQuotaExceeded) {
+			respondQuotaExceeded(c, input.UserID)
+			return
+		}
+	}
+
 	// Use GORM's Save which performs an upsert based on the primary key (image_id)
 	if err := database.DB.Save(&input).Error; err != nil {
 		fmt.Println("RegisterOrUpdateImage DB Error:", err)
@@ -58,11 +99,7 @@ func RegisterOrUpdateImage(c *gin.Context) {
 // ListImages returns a paginated list of images with signed URLs
 // Query Params: limit (default 50), cursor (last modified_at timestamp, optional)
 func ListImages(c *gin.Context) {
-	userID := c.Query("user_id") // Should come from auth token in real app
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	limit := 50
 
@@ -125,14 +162,9 @@ func ListImages(c *gin.Context) {
 
 // SyncImages returns incremental updates since a given timestamp
 func SyncImages(c *gin.Context) {
-	userID := c.Query("user_id")
+	userID := c.GetString("username")
 	modifiedAfter := c.Query("modified_after")
 
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
-
 	var images []models.Image
 	query := database.DB.Where("user_id = ?", userID)
 
@@ -176,12 +208,13 @@ func SyncImages(c *gin.Context) {
 	})
 }
 
-// GenerateUploadURLs provides presigned URLs for client-side upload
+// GenerateUploadURLs provides presigned URLs for client-side upload. The PUTs themselves can't be
+// size-limited, so uploads are checked against the quota again when they are finalized.
 func GenerateUploadURLs(c *gin.Context) {
 	var input struct {
-		ImageID  string   `json:"image_id" binding:"required"`
-		UserID   string   `json:"user_id" binding:"required"`  // Should be from auth context
-		Variants []string `json:"variants" binding:"required"` // e.g. ["original", "thumb_256"]
+		ImageID  string           `json:"image_id" binding:"required"`
+		Variants []string         `json:"variants" binding:"required"` // e.g. ["original", "thumb_256"]
+		Sizes    map[string]int64 `json:"sizes"`                       // optional expected bytes per variant, checked against the quota
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -189,20 +222,21 @@ func GenerateUploadURLs(c *gin.Context) {
 		return
 	}
 
+	var expected int64
+	for _, variant := range input.Variants {
+		expected += input.Sizes[variant]
+	}
+	userID := c.GetString("username")
+	if err := services.CheckQuota(userID, expected); err != nil {
+		respondQuotaExceeded(c, userID)
+		return
+	}
+
 	urls := make(map[string]string)
 	expiry := 7 * 24 * time.Hour
 
 	for _, variant := range input.Variants {
-		var objectName string
-		if variant == "original" {
-			objectName = fmt.Sprintf("%s/images/originals/%s.enc", input.UserID, input.ImageID)
-		} else if variant == "faces" {
-			objectName = fmt.Sprintf("%s/metadata/faces.enc", input.UserID)
-		} else if variant == "semantic" {
-			objectName = fmt.Sprintf("%s/metadata/semantic.enc", input.UserID)
-		} else {
-			objectName = fmt.Sprintf("%s/images/thumbnails/%s_%s.enc", input.UserID, input.ImageID, variant)
-		}
+		objectName := uploadObjectName(userID, input.ImageID, variant)
 
 		url, err := services.GetPresignedPutURL(objectName, expiry)
 		if err != nil {
@@ -217,11 +251,7 @@ func GenerateUploadURLs(c *gin.Context) {
 
 // GetChecksums returns a list of all checksums for a user to allow client-side deduplication
 func GetChecksums(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var checksums []string
 	// Select only checksum column where user_id matches and is not deleted
@@ -237,11 +267,7 @@ func GetChecksums(c *gin.Context) {
 
 // GetSourceIDs returns a list of all source_ids for a user to allow fast client-side deduplication
 func GetSourceIDs(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var sourceIDs []string
 	if err := database.DB.Model(&models.Image{}).
@@ -256,11 +282,7 @@ func GetSourceIDs(c *gin.Context) {
 
 // GetFacesDownloadURL generates a presigned GET URL to download the user's master encrypted faces blob and includes the current version
 func GetFacesDownloadURL(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var user models.User
 	if err := database.DB.Where("username = ?", userID).First(&user).Error; err != nil {
@@ -283,11 +305,7 @@ func GetFacesDownloadURL(c *gin.Context) {
 
 // RegisterPeopleVersion updates the people_version for a user and returns the new version
 func RegisterPeopleVersion(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var user models.User
 	if err := database.DB.Where("username = ?", userID).First(&user).Error; err != nil {
@@ -295,6 +313,11 @@ func RegisterPeopleVersion(c *gin.Context) {
 		return
 	}
 
+	if _, err := services.RecordUpload(userID, fmt.Sprintf("%s/metadata/faces.enc", userID)); errors.Is(err, services.ErrQuotaExceeded) {
+		respondQuotaExceeded(c, userID)
+		return
+	}
+
 	// Increment version
 	user.PeopleVersion++
 	if err := database.DB.Save(&user).Error; err != nil {
@@ -310,11 +333,7 @@ func RegisterPeopleVersion(c *gin.Context) {
 
 // GetPeopleVersion returns the current people_version for a user
 func GetPeopleVersion(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var user models.User
 	if err := database.DB.Where("username = ?", userID).First(&user).Error; err != nil {
@@ -326,11 +345,7 @@ func GetPeopleVersion(c *gin.Context) {
 
 // GetSemanticDownloadURL generates a presigned GET URL to download the user's encrypted semantic blob and includes version
 func GetSemanticDownloadURL(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var user models.User
 	if err := database.DB.Where("username = ?", userID).First(&user).Error; err != nil {
@@ -353,11 +368,7 @@ func GetSemanticDownloadURL(c *gin.Context) {
 
 // RegisterSemanticVersion updates the semantic_version for a user and returns the new version
 func RegisterSemanticVersion(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var user models.User
 	if err := database.DB.Where("username = ?", userID).First(&user).Error; err != nil {
@@ -365,6 +376,11 @@ func RegisterSemanticVersion(c *gin.Context) {
 		return
 	}
 
+	if _, err := services.RecordUpload(userID, fmt.Sprintf("%s/metadata/semantic.enc", userID)); errors.Is(err, services.ErrQuotaExceeded) {
+		respondQuotaExceeded(c, userID)
+		return
+	}
+
 	// Increment version
 	user.SemanticVersion++
 	if err := database.DB.Save(&user).Error; err != nil {
@@ -380,11 +396,7 @@ func RegisterSemanticVersion(c *gin.Context) {
 
 // GetSemanticVersion returns the current semantic_version for a user
 func GetSemanticVersion(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var user models.User
 	if err := database.DB.Where("username = ?", userID).First(&user).Error; err != nil {
@@ -400,10 +412,10 @@ func GetSemanticVersion(c *gin.Context) {
 // GetSingleImage returns a single image metadata with signed URLs
 func GetSingleImage(c *gin.Context) {
 	imageID := c.Param("id")
-	userID := c.Query("user_id")
+	userID := c.GetString("username")
 
-	if userID == "" || imageID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id and image_id required"})
+	if imageID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image_id required"})
 		return
 	}
 
@@ -431,11 +443,7 @@ func GetSingleImage(c *gin.Context) {
 
 // GetAlbums returns a list of distinct albums created by the user
 func GetAlbums(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var results []struct {
 		Album   string
@@ -481,11 +489,7 @@ func GetAlbums(c *gin.Context) {
 
 // DeleteImages handles permanent removal of images from cloud storage while soft-deleting in the DB for sync.
 func DeleteImages(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var input struct {
 		ImageIDs []string `json:"image_ids" binding:"required"`
@@ -515,25 +519,22 @@ func DeleteImages(c *gin.Context) {
 
 	// Issue hard deletion commands to MinIO to free up space and ensure privacy
 	for _, id := range input.ImageIDs {
-		originalPath := fmt.Sprintf("%s/images/originals/%s.enc", userID, id)
-		thumb256Path := fmt.Sprintf("%s/images/thumbnails/%s_thumb_256.enc", userID, id)
-		thumb64Path := fmt.Sprintf("%s/images/thumbnails/%s_thumb_64.enc", userID, id)
-
-		services.DeleteObject(originalPath)
-		services.DeleteObject(thumb256Path)
-		services.DeleteObject(thumb64Path)
+		var paths []string
+		for _, variant := range imageVariants {
+			path := imageObjectName(userID, id, variant)
+			services.DeleteObject(path)
+			paths = append(paths, path)
+		}
+		services.ForgetObjects(paths...)
 	}
 
+	recordAudit(c, models.AuditEvent{Event: services.AuditImagesDeleted, TargetUserID: userID, TargetType: "image"}, gin.H{"image_ids": input.ImageIDs})
 	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Successfully processing deletion for %d images", len(input.ImageIDs))})
 }
 
 // UpdateImageLocation performs a bulk update of latitude and longitude for the specified image IDs.
 func UpdateImageLocation(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var input struct {
 		ImageIDs  []string `json:"image_ids" binding:"required"`
@@ -568,11 +569,7 @@ func UpdateImageLocation(c *gin.Context) {
 
 // UpdateImageAlbum performs a bulk update of the album property for the specified image IDs.
 func UpdateImageAlbum(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var input struct {
 		ImageIDs  []string `json:"image_ids" binding:"required"`
@@ -605,11 +602,7 @@ func UpdateImageAlbum(c *gin.Context) {
 
 // UpdateImageFavorite performs a bulk update of the favorite status for the specified image IDs.
 func UpdateImageFavorite(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var input struct {
 		ImageIDs   []string `json:"image_ids" binding:"required"`
@@ -643,11 +636,11 @@ func UpdateImageFavorite(c *gin.Context) {
 // DownloadImage proxies a file download from MinIO to the client
 func DownloadImage(c *gin.Context) {
 	imageID := c.Param("id")
-	userID := c.Query("user_id")
+	userID := c.GetString("username")
 	variant := c.Query("variant")
 
-	if userID == "" || imageID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id and id are required"})
+	if imageID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
 		return
 	}
 
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/services"
+)
+
+// InviteCreateInput for creating an invite code
+type InviteCreateInput struct {
+	MaxUses   *int   `json:"max_uses"`   // default 1, 0 = unlimited
+	ExpiresIn string `json:"expires_in"` // Go duration, e.g. "72h"; default 7 days
+	Note      string `json:"note"`
+}
+
+// createInvite creates an invite owned by creator from the request body, or writes an error
+func createInvite(c *gin.Context, creator string) (*models.Invite, bool) {
+	var input InviteCreateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	maxUses := 1
+	if input.MaxUses != nil {
+		maxUses = *input.MaxUses
+	}
+	if maxUses < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_uses must not be negative"})
+		return nil, false
+	}
+
+	ttl := services.DefaultInviteTTL
+	if input.ExpiresIn != "" {
+		d, err := time.ParseDuration(input.ExpiresIn)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in must be a positive duration such as 72h"})
+			return nil, false
+		}
+		ttl = d
+	}
+
+	code, err := services.NewInviteCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invite code"})
+		return nil, false
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	invite := models.Invite{
+		Code:      code,
+		CreatedBy: creator,
+		Note:      input.Note,
+		MaxUses:   maxUses,
+		ExpiresAt: &expiresAt,
+		CreatedAt: now,
+	}
+	if err := database.DB.Create(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return nil, false
+	}
+	return &invite, true
+}
+
+// GetRegistrationInfo tells clients whether signup is open, invite-only or closed
+func GetRegistrationInfo(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"mode": services.RegistrationMode})
+}
+
+// CreateInvite lets a regular user invite someone, when user invites are enabled
+func CreateInvite(c *gin.Context) {
+	if !services.UserInvitesEnabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can create invites on this server"})
+		return
+	}
+
+	invite, ok := createInvite(c, c.GetString("username"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invite": invite})
+}
+
+// ListMyInvites returns the invites created by the current user and who signed up with them
+func ListMyInvites(c *gin.Context) {
+	username := c.GetString("username")
+
+	var invites []models.Invite
+	if err := database.DB.Where("created_by = ?", username).Order("created_at DESC").Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invites": invitesWithRedeemers(invites)})
+}
+
+// RevokeMyInvite revokes an invite the current user created
+func RevokeMyInvite(c *gin.Context) {
+	code := services.NormalizeInviteCode(c.Param("code"))
+
+	result := database.DB.Model(&models.Invite{}).
+		Where("code = ? AND created_by = ? AND revoked_at IS NULL", code, c.GetString("username")).
+		Update("revoked_at", time.Now())
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+}
+
+// AdminCreateInvite creates an invite as an admin
+func AdminCreateInvite(c *gin.Context) {
+	invite, ok := createInvite(c, c.GetString("username"))
+	if !ok {
+		return
+	}
+
+	recordAdminAction(c, "create_invite", "", gin.H{"code": invite.Code, "max_uses": invite.MaxUses, "expires_at": invite.ExpiresAt})
+	c.JSON(http.StatusOK, gin.H{"invite": invite})
+}
+
+// AdminListInvites returns every invite with the accounts created from it
+func AdminListInvites(c *gin.Context) {
+	var invites []models.Invite
+	if err := database.DB.Order("created_at DESC").Limit(500).Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invites": invitesWithRedeemers(invites)})
+}
+
+// AdminRevokeInvite revokes any invite
+func AdminRevokeInvite(c *gin.Context) {
+	code := services.NormalizeInviteCode(c.Param("code"))
+
+	result := database.DB.Model(&models.Invite{}).
+		Where("code = ? AND revoked_at IS NULL", code).
+		Update("revoked_at", time.Now())
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	recordAdminAction(c, "revoke_invite", "", gin.H{"code": code})
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+}
+
+// invitesWithRedeemers pairs each invite with the usernames that signed up using it
+func invitesWithRedeemers(invites []models.Invite) []gin.H {
+	codes := make([]string, 0, len(invites))
+	for _, inv := range invites {
+		codes = append(codes, inv.Code)
+	}
+
+	redeemed := map[string][]string{}
+	if len(codes) > 0 {
+		var users []models.User
+		database.DB.Select("username", "invite_code").Where("invite_code IN ?", codes).Find(&users)
+		for _, u := range users {
+			redeemed[u.InviteCode] = append(redeemed[u.InviteCode], u.Username)
+		}
+	}
+
+	result := make([]gin.H, 0, len(invites))
+	for _, inv := range invites {
+		users := redeemed[inv.Code]
+		if users == nil {
+			users = []string{}
+		}
+		result = append(result, gin.H{"invite": inv, "redeemed_by": users})
+	}
+	return result
+}
@@ -0,0 +1,431 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/services"
+)
+
+// isKnownUserKey reports whether a fingerprint belongs to any key version of a user
+func isKnownUserKey(userID, fingerprint string) bool {
+	var count int64
+	database.DB.Model(&models.UserKey{}).Where("user_id = ? AND fingerprint = ?", userID, fingerprint).Count(&count)
+	return count > 0
+}
+
+// GetUserKeyHistory returns every key version a user has had, with fingerprints and timestamps
+func GetUserKeyHistory(c *gin.Context) {
+	username := c.Param("username")
+	requester := c.GetString("username")
+	if username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username required"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil || !canDiscover(requester, &user) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var keys []models.UserKey
+	if err := database.DB.Where("user_id = ?", user.Username).Order("version ASC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load key history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// ListPinnedKeys returns the fingerprints the current user has pinned for their contacts
+func ListPinnedKeys(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var pins []models.PinnedKey
+	if err := database.DB.Where("user_id = ?", userID).Order("contact_id ASC").Find(&pins).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list pinned keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pins": pins})
+}
+
+// PinKey records a contact's fingerprint after the user verified it out of band.
+// The fingerprint must match the contact's current key.
+func PinKey(c *gin.Context) {
+	username := c.Param("username")
+	userID := c.GetString("username")
+	if username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username required"})
+		return
+	}
+
+	var input struct {
+		Fingerprint string `json:"fingerprint" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil || !canDiscover(userID, &user) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	key, err := services.CurrentUserKey(username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if key.Fingerprint != input.Fingerprint {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":               "Fingerprint does not match the user's current key",
+			"current_fingerprint": key.Fingerprint,
+			"key_version":         key.Version,
+		})
+		return
+	}
+
+	pin := models.PinnedKey{UserID: userID, ContactID: username}
+	database.DB.Where("user_id = ? AND contact_id = ?", userID, username).First(&pin)
+	pin.Fingerprint = key.Fingerprint
+	pin.KeyVersion = key.Version
+	pin.PinnedAt = time.Now()
+	if err := database.DB.Save(&pin).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pin key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pin": pin})
+}
+
+// UnpinKey forgets a pinned fingerprint
+func UnpinKey(c *gin.Context) {
+	username := c.Param("username")
+	userID := c.GetString("username")
+	if username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username required"})
+		return
+	}
+
+	result := database.DB.Where("user_id = ? AND contact_id = ?", userID, username).Delete(&models.PinnedKey{})
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No pinned key for this user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Key unpinned"})
+}
+
+// GetKeyLog returns signed key log entries after a sequence number so clients can audit key changes.
+// Each entry_hash is sha256("seq|user_id|key_version|fingerprint|action|created_at_unix_nano|prev_hash")
+// and signature is the server's ed25519 signature over the raw entry_hash bytes.
+// Entries about users the requester can't discover have their user_id and fingerprint blanked;
+// their hashes and signatures are kept so the chain can still be followed.
+// Query Params: after (seq, default 0), limit (default 500, max 1000), user (optional username filter)
+func GetKeyLog(c *gin.Context) {
+	requester := c.GetString("username")
+	after := 0
+	if a := c.Query("after"); a != "" {
+		fmt.Sscanf(a, "%d", &after)
+	}
+
+	limit := 500
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit <= 0 || limit > 1000 {
+		limit = 500
+	}
+
+	query := database.DB.Where("seq > ?", after)
+	if username := c.Query("user"); username != "" {
+		var user models.User
+		if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil || !canDiscover(requester, &user) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		query = query.Where("user_id = ?", username)
+	}
+
+	var entries []models.KeyLogEntry
+	if err := query.Order("seq ASC").Limit(limit).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read key log"})
+		return
+	}
+	redactKeyLog(requester, entries)
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":           entries,
+		"server_public_key": services.KeyLogPublicKey(),
+	})
+}
+
+// redactKeyLog blanks the user and fingerprint of entries about users requester can't discover,
+// including users who have since deleted their account
+func redactKeyLog(requester string, entries []models.KeyLogEntry) {
+	ids := map[string]bool{}
+	for _, e := range entries {
+		ids[e.UserID] = false
+	}
+	names := make([]string, 0, len(ids))
+	for id := range ids {
+		names = append(names, id)
+	}
+	var users []models.User
+	database.DB.Where("username IN ?", names).Find(&users)
+	for i := range users {
+		ids[users[i].Username] = canDiscover(requester, &users[i])
+	}
+
+	for i := range entries {
+		if !ids[entries[i].UserID] {
+			entries[i].UserID = ""
+			entries[i].Fingerprint = ""
+		}
+	}
+}
+
+// GetKeyLogPublicKey returns the key that signs the key log
+func GetKeyLogPublicKey(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"public_key": services.KeyLogPublicKey()})
+}
+
+// RotateKeypairInput carries a freshly generated keypair; the private key is already encrypted by the master key
+type RotateKeypairInput struct {
+	Password            string `json:"password" binding:"required"`
+	PublicKey           string `json:"public_key" binding:"required"`
+	EncryptedPrivateKey string `json:"encrypted_private_key" binding:"required"`
+	PrivateKeyNonce     string `json:"private_key_nonce" binding:"required"`
+}
+
+// RotateKeypair replaces the user's keypair and retires the old one.
+// Existing incoming shares keep referencing the key version they were wrapped for until re-wrapped.
+func RotateKeypair(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var input RotateKeypairInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("username = ?", userID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	if services.Fingerprint(input.PublicKey) == services.Fingerprint(user.PublicKey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New public key is the same as the current one"})
+		return
+	}
+
+	user.PublicKey = input.PublicKey
+	user.EncryptedPrivateKey = input.EncryptedPrivateKey
+	user.PrivateKeyNonce = input.PrivateKeyNonce
+
+	var key *models.UserKey
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"public_key":            user.PublicKey,
+			"encrypted_private_key": user.EncryptedPrivateKey,
+			"private_key_nonce":     user.PrivateKeyNonce,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		key, err = services.AddUserKey(tx, &user, models.KeyLogActionRotated)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate keypair"})
+		return
+	}
+
+	shares, items := countSharesToRewrap(userID, key.Version)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":                "Keypair rotated",
+		"key_version":            key.Version,
+		"fingerprint":            key.Fingerprint,
+		"shares_to_rewrap":       shares,
+		"bundle_items_to_rewrap": items,
+	})
+}
+
+// activeIncomingShares scopes shares the user can still open: not declined and not a consumed one-time share
+func activeIncomingShares(userID string) *gorm.DB {
+	return database.DB.Model(&models.Share{}).
+		Where("receiver_id = ? AND status != ?", userID, models.ShareStatusDeclined).
+		Where("NOT (share_type = ? AND viewed_at IS NOT NULL)", models.ShareTypeOneTime)
+}
+
+// activeIncomingBundleItems scopes bundle items of bundles the user can still open
+func activeIncomingBundleItems(userID string) *gorm.DB {
+	return database.DB.Model(&models.ShareBundleItem{}).
+		Where("bundle_id IN (?)", database.DB.Model(&models.ShareBundle{}).
+			Select("id").
+			Where("receiver_id = ? AND status != ?", userID, models.ShareStatusDeclined))
+}
+
+func countSharesToRewrap(userID string, currentVersion int) (int64, int64) {
+	var shares, items int64
+	activeIncomingShares(userID).Where("receiver_key_version < ?", currentVersion).Count(&shares)
+	activeIncomingBundleItems(userID).Where("receiver_key_version < ?", currentVersion).Count(&items)
+	return shares, items
+}
+
+// ListSharesToRewrap returns active incoming shares still wrapped for a retired key,
+// together with the retired keys (encrypted private keys) needed to unwrap them
+func ListSharesToRewrap(c *gin.Context) {
+	userID := c.GetString("username")
+
+	current, err := services.CurrentUserKey(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no registered key"})
+		return
+	}
+
+	var shares []models.Share
+	if err := activeIncomingShares(userID).Where("receiver_key_version < ?", current.Version).Find(&shares).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list shares"})
+		return
+	}
+
+	var items []models.ShareBundleItem
+	if err := activeIncomingBundleItems(userID).Where("receiver_key_version < ?", current.Version).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list bundle items"})
+		return
+	}
+
+	shareResp := make([]gin.H, 0, len(shares))
+	for _, s := range shares {
+		shareResp = append(shareResp, gin.H{
+			"share_id":             s.ID,
+			"receiver_key_version": s.ReceiverKeyVersion,
+			"encrypted_share_key":  s.EncryptedShareKey,
+			"sender_public_key":    s.SenderPublicKey,
+		})
+	}
+
+	var retired []models.UserKey
+	database.DB.Where("user_id = ? AND version < ?", userID, current.Version).Order("version ASC").Find(&retired)
+	keyResp := make([]gin.H, 0, len(retired))
+	for _, k := range retired {
+		keyResp = append(keyResp, gin.H{
+			"version":               k.Version,
+			"public_key":            k.PublicKey,
+			"encrypted_private_key": k.EncryptedPrivateKey,
+			"private_key_nonce":     k.PrivateKeyNonce,
+			"retired_at":            k.RetiredAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"current_key_version": current.Version,
+		"retired_keys":        keyResp,
+		"shares":              shareResp,
+		"bundle_items":        items,
+	})
+}
+
+// RewrapSharesInput carries share keys re-encrypted for the user's current public key
+type RewrapSharesInput struct {
+	Shares []struct {
+		ShareID           string `json:"share_id" binding:"required"`
+		EncryptedShareKey string `json:"encrypted_share_key" binding:"required"`
+	} `json:"shares"`
+	BundleItems []struct {
+		ItemID            string `json:"item_id" binding:"required"`
+		EncryptedShareKey string `json:"encrypted_share_key" binding:"required"`
+	} `json:"bundle_items"`
+}
+
+// RewrapShares replaces the EncryptedShareKey of active incoming shares in bulk with keys wrapped for the current key version
+func RewrapShares(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var input RewrapSharesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	current, err := services.CurrentUserKey(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no registered key"})
+		return
+	}
+
+	var rewrapped, skipped int
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, s := range input.Shares {
+			result := tx.Model(&models.Share{}).
+				Where("id = ? AND receiver_id = ? AND status != ?", s.ShareID, userID, models.ShareStatusDeclined).
+				Updates(map[string]interface{}{
+					"encrypted_share_key":      s.EncryptedShareKey,
+					"receiver_key_version":     current.Version,
+					"receiver_key_fingerprint": current.Fingerprint,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				skipped++
+			} else {
+				rewrapped++
+			}
+		}
+
+		ownBundles := tx.Model(&models.ShareBundle{}).Select("id").
+			Where("receiver_id = ? AND status != ?", userID, models.ShareStatusDeclined)
+		for _, item := range input.BundleItems {
+			result := tx.Model(&models.ShareBundleItem{}).
+				Where("id = ? AND bundle_id IN (?)", item.ItemID, ownBundles).
+				Updates(map[string]interface{}{
+					"encrypted_share_key":  item.EncryptedShareKey,
+					"receiver_key_version": current.Version,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				skipped++
+			} else {
+				rewrapped++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to re-wrap share keys"})
+		return
+	}
+
+	remainingShares, remainingItems := countSharesToRewrap(userID, current.Version)
+
+	c.JSON(http.StatusOK, gin.H{
+		"rewrapped":              rewrapped,
+		"skipped":                skipped,
+		"key_version":            current.Version,
+		"remaining_shares":       remainingShares,
+		"remaining_bundle_items": remainingItems,
+	})
+}
@@ -0,0 +1,387 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/services"
+)
+
+// ShareBundleItemInput describes one image of a bundle being created
+type ShareBundleItemInput struct {
+	ImageID           string `json:"image_id" binding:"required"`
+	EncryptedShareKey string `json:"encrypted_share_key"` // base64, share_key encrypted for receiver
+	SenderPublicKey   string `json:"sender_public_key"`   // base64, for receiver to decrypt
+}
+
+// ShareBundleCreateInput for creating a multi-image share
+type ShareBundleCreateInput struct {
+	ReceiverUsername string                 `json:"receiver_username" binding:"required"`
+	Caption          string                 `json:"caption"`
+	Items            []ShareBundleItemInput `json:"items" binding:"required"`
+	// Fingerprint of the receiver key the client wrapped the item keys for (optional);
+	// the bundle is refused if the receiver's key has changed since the client fetched it
+	ReceiverKeyFingerprint string `json:"receiver_key_fingerprint"`
+}
+
+// MaxShareBundleItems caps how many images a single bundle can carry
+const MaxShareBundleItems = 500
+
+// shareBundleObjectName returns the storage path of a bundle item's ciphertext
+func shareBundleObjectName(bundleID, itemID string) string {
+	return fmt.Sprintf("shares/bundles/%s/%s.enc", bundleID, itemID)
+}
+
+// CreateShareBundle creates a bundle granting the receiver a set of images in one share.
+// Client flow: 1) Call this with the per-item keys 2) Get upload URLs for the items 3) Upload encrypted images
+func CreateShareBundle(c *gin.Context) {
+	senderID := c.GetString("username")
+
+	var input ShareBundleCreateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(input.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No items provided"})
+		return
+	}
+	if len(input.Items) > MaxShareBundleItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A bundle can hold at most %d items", MaxShareBundleItems)})
+		return
+	}
+
+	// Verify receiver exists
+	var receiver models.User
+	if err := database.DB.Where("username = ?", input.ReceiverUsername).First(&receiver).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receiver user not found"})
+		return
+	}
+
+	if receiver.Username == senderID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot share with yourself"})
+		return
+	}
+
+	if isBlocked(receiver.Username, senderID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Receiver is not accepting shares from you"})
+		return
+	}
+
+	receiverKey, err := services.CurrentUserKey(receiver.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Receiver has no registered key"})
+		return
+	}
+	if input.ReceiverKeyFingerprint != "" && input.ReceiverKeyFingerprint != receiverKey.Fingerprint {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":               "Receiver key has changed, fetch the new key and verify it",
+			"current_fingerprint": receiverKey.Fingerprint,
+		})
+		return
+	}
+
+	now := time.Now()
+	bundle := models.ShareBundle{
+		ID:         uuid.New().String(),
+		SenderID:   senderID,
+		ReceiverID: receiver.Username,
+		Caption:    input.Caption,
+		ItemCount:  len(input.Items),
+		Status:     models.ShareStatusPending,
+		CreatedAt:  now,
+
+		SenderKeyFingerprint:   services.Fingerprint(input.Items[0].SenderPublicKey),
+		ReceiverKeyFingerprint: receiverKey.Fingerprint,
+	}
+
+	items := make([]models.ShareBundleItem, 0, len(input.Items))
+	for i, in := range input.Items {
+		items = append(items, models.ShareBundleItem{
+			ID:                uuid.New().String(),
+			BundleID:          bundle.ID,
+			ImageID:           in.ImageID,
+			Position:          i,
+			EncryptedShareKey: in.EncryptedShareKey,
+			SenderPublicKey:   in.SenderPublicKey,
+			CreatedAt:         now,
+
+			ReceiverKeyVersion: receiverKey.Version,
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&bundle).Error; err != nil {
+			return err
+		}
+		return tx.CreateInBatches(&items, 100).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share bundle"})
+		return
+	}
+
+	recordAudit(c, models.AuditEvent{Event: services.AuditShareBundleCreated, TargetUserID: receiver.Username, TargetType: "share_bundle", TargetID: bundle.ID}, gin.H{"items": len(items)})
+	services.NotifyUser(receiver.Username, services.MailShareReceived, map[string]interface{}{
+		"Sender":  senderID,
+		"Count":   len(items),
+		"Caption": bundle.Caption,
+	})
+
+	created := make([]gin.H, 0, len(items))
+	for _, item := range items {
+		created = append(created, gin.H{"item_id": item.ID, "image_id": item.ImageID, "position": item.Position})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bundle_id":                bundle.ID,
+		"items":                    created,
+		"receiver_key_fingerprint": bundle.ReceiverKeyFingerprint,
+		"created_at":               bundle.CreatedAt,
+	})
+}
+
+// GetShareBundleUploadURLs returns presigned PUT URLs for every item of a bundle, keyed by item ID
+func GetShareBundleUploadURLs(c *gin.Context) {
+	bundleID := c.Param("id")
+	userID := c.GetString("username")
+	if bundleID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	// Verify bundle exists and user is sender
+	var bundle models.ShareBundle
+	if err := database.DB.Where("id = ? AND sender_id = ?", bundleID, userID).First(&bundle).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share bundle not found"})
+		return
+	}
+
+	if err := services.CheckQuota(userID, 0); err != nil {
+		respondQuotaExceeded(c, userID)
+		return
+	}
+
+	var items []models.ShareBundleItem
+	if err := database.DB.Where("bundle_id = ?", bundle.ID).Order("position ASC").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list bundle items"})
+		return
+	}
+
+	urls := make(map[string]string, len(items))
+	for _, item := range items {
+		url, err := services.GetPresignedPutURL(shareBundleObjectName(bundle.ID, item.ID), 15*time.Minute)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate upload URL"})
+			return
+		}
+		urls[item.ID] = url
+	}
+
+	c.JSON(http.StatusOK, gin.H{"upload_urls": urls})
+}
+
+// ListShareBundlesWithMe returns bundles where current user is receiver
+// Query Params: status (pending | accepted | declined, optional; declined bundles are hidden by default)
+func ListShareBundlesWithMe(c *gin.Context) {
+	userID := c.GetString("username")
+
+	query := database.DB.Where("receiver_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	} else {
+		query = query.Where("status != ?", models.ShareStatusDeclined)
+	}
+
+	var bundles []models.ShareBundle
+	if err := query.Order("created_at DESC").Find(&bundles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list share bundles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bundles": bundles})
+}
+
+// ListShareBundlesByMe returns bundles where current user is sender
+func ListShareBundlesByMe(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var bundles []models.ShareBundle
+	if err := database.DB.Where("sender_id = ?", userID).Order("created_at DESC").Find(&bundles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list share bundles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bundles": bundles})
+}
+
+// ListShareBundleItems returns a page of a bundle's items for the receiver (includes keys for decryption)
+// Query Params: limit (default 50, max 200), cursor (page number, optional)
+func ListShareBundleItems(c *gin.Context) {
+	bundleID := c.Param("id")
+	userID := c.GetString("username")
+	if bundleID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var bundle models.ShareBundle
+	if err := database.DB.Where("id = ? AND receiver_id = ?", bundleID, userID).First(&bundle).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share bundle not found"})
+		return
+	}
+
+	if bundle.Status == models.ShareStatusDeclined {
+		c.JSON(http.StatusGone, gin.H{"error": "This share bundle was declined"})
+		return
+	}
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		fmt.Sscanf(l, "%d", &limit)
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	page := 0
+	if cursor := c.Query("cursor"); cursor != "" {
+		var err error
+		if page, err = strconv.Atoi(cursor); err != nil || page < 0 || page > math.MaxInt32 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+	}
+
+	var items []models.ShareBundleItem
+	if err := database.DB.Where("bundle_id = ?", bundle.ID).
+		Order("position ASC").
+		Limit(limit).
+		Offset(page * limit).
+		Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list bundle items"})
+		return
+	}
+
+	// Enrich with image metadata from the sender's library
+	type BundleItemWithMeta struct {
+		models.ShareBundleItem
+		Width    int    `json:"width"`
+		Height   int    `json:"height"`
+		MimeType string `json:"mime_type"`
+	}
+
+	result := make([]BundleItemWithMeta, 0, len(items))
+	for _, item := range items {
+		bim := BundleItemWithMeta{ShareBundleItem: item}
+		var img models.Image
+		if err := database.DB.Where("image_id = ? AND user_id = ?", item.ImageID, bundle.SenderID).First(&img).Error; err == nil {
+			bim.Width = img.Width
+			bim.Height = img.Height
+			bim.MimeType = img.MimeType
+		}
+		result = append(result, bim)
+	}
+
+	nextCursor := ""
+	if len(items) == limit {
+		nextCursor = fmt.Sprintf("%d", page+1)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bundle":      bundle,
+		"items":       result,
+		"next_cursor": nextCursor,
+	})
+}
+
+// GetShareBundleItemDownloadURL returns presigned GET URL for one image of a bundle
+func GetShareBundleItemDownloadURL(c *gin.Context) {
+	bundleID := c.Param("id")
+	itemID := c.Param("item_id")
+	userID := c.GetString("username")
+	if bundleID == "" || itemID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id and item_id required"})
+		return
+	}
+
+	var bundle models.ShareBundle
+	if err := database.DB.Where("id = ? AND receiver_id = ?", bundleID, userID).First(&bundle).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share bundle not found"})
+		return
+	}
+
+	if bundle.Status == models.ShareStatusDeclined {
+		c.JSON(http.StatusGone, gin.H{"error": "This share bundle was declined"})
+		return
+	}
+
+	var item models.ShareBundleItem
+	if err := database.DB.Where("id = ? AND bundle_id = ?", itemID, bundle.ID).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bundle item not found"})
+		return
+	}
+
+	url, err := services.GetPresignedURL(shareBundleObjectName(bundle.ID, item.ID), 15*time.Minute)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate download URL"})
+		return
+	}
+	recordAudit(c, models.AuditEvent{Event: services.AuditShareBundleViewed, TargetUserID: bundle.SenderID, TargetType: "share_bundle", TargetID: bundle.ID}, gin.H{"item_id": item.ID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"download_url": url,
+		"sender_id":    bundle.SenderID,
+		"image_id":     item.ImageID,
+	})
+}
+
+// RevokeShareBundle allows sender to revoke a whole bundle at once
+func RevokeShareBundle(c *gin.Context) {
+	bundleID := c.Param("id")
+	userID := c.GetString("username")
+	if bundleID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var bundle models.ShareBundle
+	if err := database.DB.Where("id = ? AND sender_id = ?", bundleID, userID).First(&bundle).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share bundle not found"})
+		return
+	}
+
+	var itemIDs []string
+	database.DB.Model(&models.ShareBundleItem{}).Where("bundle_id = ?", bundle.ID).Pluck("id", &itemIDs)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&models.ShareBundleItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&bundle).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share bundle"})
+		return
+	}
+
+	// Remove the ciphertexts from MinIO
+	for _, id := range itemIDs {
+		objectName := shareBundleObjectName(bundle.ID, id)
+		_ = services.DeleteObject(objectName)
+		services.ForgetObjects(objectName)
+	}
+
+	recordAudit(c, models.AuditEvent{Event: services.AuditShareBundleRevoked, TargetUserID: bundle.ReceiverID, TargetType: "share_bundle", TargetID: bundle.ID}, gin.H{"items": len(itemIDs)})
+	c.JSON(http.StatusOK, gin.H{"message": "Share bundle revoked"})
+}
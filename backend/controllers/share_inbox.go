@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/services"
+)
+
+// isBlocked reports whether receiverID has blocked senderID
+func isBlocked(receiverID, senderID string) bool {
+	var count int64
+	database.DB.Model(&models.BlockedUser{}).Where("user_id = ? AND blocked_id = ?", receiverID, senderID).Count(&count)
+	return count > 0
+}
+
+// declineShare marks a share declined and removes its ciphertext from storage
+func declineShare(share *models.Share) error {
+	now := time.Now()
+	if err := database.DB.Model(share).Updates(map[string]interface{}{
+		"status":       models.ShareStatusDeclined,
+		"responded_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	objectName := "shares/" + share.ID + ".enc"
+	_ = services.DeleteObject(objectName)
+	services.ForgetObjects(objectName)
+	return nil
+}
+
+// declineShareBundle marks a bundle declined and removes every item's ciphertext from storage
+func declineShareBundle(bundle *models.ShareBundle) error {
+	now := time.Now()
+	if err := database.DB.Model(bundle).Updates(map[string]interface{}{
+		"status":       models.ShareStatusDeclined,
+		"responded_at": now,
+	}).Error; err != nil {
+		return err
+	}
+
+	var itemIDs []string
+	database.DB.Model(&models.ShareBundleItem{}).Where("bundle_id = ?", bundle.ID).Pluck("id", &itemIDs)
+	for _, id := range itemIDs {
+		objectName := shareBundleObjectName(bundle.ID, id)
+		_ = services.DeleteObject(objectName)
+		services.ForgetObjects(objectName)
+	}
+	return nil
+}
+
+// AcceptShare moves a pending share from the receiver's inbox into their accepted shares
+func AcceptShare(c *gin.Context) {
+	shareID := c.Param("id")
+	userID := c.GetString("username")
+	if shareID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var share models.Share
+	if err := database.DB.Where("id = ? AND receiver_id = ?", shareID, userID).First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+
+	if share.Status != models.ShareStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Share is already " + share.Status})
+		return
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&share).Updates(map[string]interface{}{
+		"status":       models.ShareStatusAccepted,
+		"responded_at": now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept share"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share accepted"})
+}
+
+// DeclineShare refuses a pending share and cleans its ciphertext from storage
+func DeclineShare(c *gin.Context) {
+	shareID := c.Param("id")
+	userID := c.GetString("username")
+	if shareID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var share models.Share
+	if err := database.DB.Where("id = ? AND receiver_id = ?", shareID, userID).First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+
+	if share.Status != models.ShareStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Share is already " + share.Status})
+		return
+	}
+
+	if err := declineShare(&share); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline share"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share declined"})
+}
+
+// AcceptShareBundle moves a pending bundle from the receiver's inbox into their accepted shares
+func AcceptShareBundle(c *gin.Context) {
+	bundleID := c.Param("id")
+	userID := c.GetString("username")
+	if bundleID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var bundle models.ShareBundle
+	if err := database.DB.Where("id = ? AND receiver_id = ?", bundleID, userID).First(&bundle).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share bundle not found"})
+		return
+	}
+
+	if bundle.Status != models.ShareStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Share bundle is already " + bundle.Status})
+		return
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&bundle).Updates(map[string]interface{}{
+		"status":       models.ShareStatusAccepted,
+		"responded_at": now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept share bundle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share bundle accepted"})
+}
+
+// DeclineShareBundle refuses a pending bundle and cleans its ciphertexts from storage
+func DeclineShareBundle(c *gin.Context) {
+	bundleID := c.Param("id")
+	userID := c.GetString("username")
+	if bundleID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var bundle models.ShareBundle
+	if err := database.DB.Where("id = ? AND receiver_id = ?", bundleID, userID).First(&bundle).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share bundle not found"})
+		return
+	}
+
+	if bundle.Status != models.ShareStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Share bundle is already " + bundle.Status})
+		return
+	}
+
+	if err := declineShareBundle(&bundle); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline share bundle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share bundle declined"})
+}
+
+// ListBlockedUsers returns the usernames the current user has blocked
+func ListBlockedUsers(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var blocks []models.BlockedUser
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&blocks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list blocked users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocked": blocks})
+}
+
+// BlockUser stops a sender from sharing with the current user and declines their pending shares
+func BlockUser(c *gin.Context) {
+	userID := c.GetString("username")
+
+	var input struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Username == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot block yourself"})
+		return
+	}
+
+	var blocked models.User
+	if err := database.DB.Where("username = ?", input.Username).First(&blocked).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !isBlocked(userID, blocked.Username) {
+		block := models.BlockedUser{
+			UserID:    userID,
+			BlockedID: blocked.Username,
+			CreatedAt: time.Now(),
+		}
+		if err := database.DB.Create(&block).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+			return
+		}
+	}
+
+	// Decline everything still waiting in the inbox from the blocked sender
+	declined := 0
+	var shares []models.Share
+	database.DB.Where("receiver_id = ? AND sender_id = ? AND status = ?", userID, blocked.Username, models.ShareStatusPending).Find(&shares)
+	for i := range shares {
+		if err := declineShare(&shares[i]); err == nil {
+			declined++
+		}
+	}
+	var bundles []models.ShareBundle
+	database.DB.Where("receiver_id = ? AND sender_id = ? AND status = ?", userID, blocked.Username, models.ShareStatusPending).Find(&bundles)
+	for i := range bundles {
+		if err := declineShareBundle(&bundles[i]); err == nil {
+			declined++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked", "declined_shares": declined})
+}
+
+// UnblockUser removes a sender from the current user's blocklist
+func UnblockUser(c *gin.Context) {
+	userID := c.GetString("username")
+	username := c.Param("username")
+	if username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username required"})
+		return
+	}
+
+	result := database.DB.Where("user_id = ? AND blocked_id = ?", userID, username).Delete(&models.BlockedUser{})
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not blocked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked"})
+}
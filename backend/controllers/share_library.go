@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/services"
+)
+
+// SaveSharedImageInput describes the receiver's copy of a shared image.
+// The client re-encrypts the image for its own library; when ReuseCiphertext is set the
+// share ciphertext is copied server-side and EncryptedFileKey carries the share key wrapped by the receiver's master key.
+type SaveSharedImageInput struct {
+	ImageID          string    `json:"image_id" binding:"required"` // new image ID in the receiver's library
+	ReuseCiphertext  bool      `json:"reuse_ciphertext"`
+	EncryptedFileKey string    `json:"encrypted_file_key"` // base64, required with reuse_ciphertext
+	FileKeyNonce     string    `json:"file_key_nonce"`     // required with reuse_ciphertext
+	Width            int       `json:"width"`
+	Height           int       `json:"height"`
+	Size             int64     `json:"size"`
+	Checksum         string    `json:"checksum"`
+	MimeType         string    `json:"mime_type"`
+	Album            string    `json:"album"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// savedThumbnailVariants are always uploaded by the client, since thumbnails are never part of a share
+var savedThumbnailVariants = []string{"thumb_1024", "thumb_256", "thumb_64"}
+
+// SaveShareToLibrary imports a received share into the receiver's own library
+func SaveShareToLibrary(c *gin.Context) {
+	shareID := c.Param("id")
+	userID := c.GetString("username")
+	if shareID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var share models.Share
+	if err := database.DB.Where("id = ? AND receiver_id = ?", shareID, userID).First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+
+	if share.Status == models.ShareStatusDeclined {
+		c.JSON(http.StatusGone, gin.H{"error": "This share was declined"})
+		return
+	}
+
+	if share.ShareType == models.ShareTypeOneTime {
+		c.JSON(http.StatusForbidden, gin.H{"error": "One-time shares cannot be saved"})
+		return
+	}
+
+	origin := models.Image{
+		OriginShareID:  share.ID,
+		OriginSenderID: share.SenderID,
+	}
+	if saveSharedImage(c, userID, origin, "shares/"+share.ID+".enc") && share.Status == models.ShareStatusPending {
+		// Saving a share implies accepting it
+		database.DB.Model(&share).Updates(map[string]interface{}{
+			"status":       models.ShareStatusAccepted,
+			"responded_at": time.Now(),
+		})
+	}
+}
+
+// SaveShareBundleItemToLibrary imports one image of a received bundle into the receiver's own library
+func SaveShareBundleItemToLibrary(c *gin.Context) {
+	bundleID := c.Param("id")
+	itemID := c.Param("item_id")
+	userID := c.GetString("username")
+	if bundleID == "" || itemID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id and item_id required"})
+		return
+	}
+
+	var bundle models.ShareBundle
+	if err := database.DB.Where("id = ? AND receiver_id = ?", bundleID, userID).First(&bundle).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share bundle not found"})
+		return
+	}
+
+	if bundle.Status == models.ShareStatusDeclined {
+		c.JSON(http.StatusGone, gin.H{"error": "This share bundle was declined"})
+		return
+	}
+
+	var item models.ShareBundleItem
+	if err := database.DB.Where("id = ? AND bundle_id = ?", itemID, bundle.ID).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bundle item not found"})
+		return
+	}
+
+	origin := models.Image{
+		OriginShareID:     bundle.ID,
+		OriginShareItemID: item.ID,
+		OriginSenderID:    bundle.SenderID,
+	}
+	if saveSharedImage(c, userID, origin, shareBundleObjectName(bundle.ID, item.ID)) && bundle.Status == models.ShareStatusPending {
+		// Saving from a bundle implies accepting it
+		database.DB.Model(&bundle).Updates(map[string]interface{}{
+			"status":       models.ShareStatusAccepted,
+			"responded_at": time.Now(),
+		})
+	}
+}
+
+// saveSharedImage registers the receiver's copy of a shared image and returns upload URLs for the variants the client still has to upload.
+// It writes the response itself and reports whether the image was saved.
+func saveSharedImage(c *gin.Context, userID string, origin models.Image, shareObjectName string) bool {
+	var input SaveSharedImageInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if input.ReuseCiphertext && (input.EncryptedFileKey == "" || input.FileKeyNonce == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "encrypted_file_key and file_key_nonce are required to reuse the share ciphertext"})
+		return false
+	}
+
+	// Saving the same share twice is rejected with the existing copy's ID
+	var existing models.Image
+	query := database.DB.Where("user_id = ? AND origin_share_id = ? AND is_deleted = ?", userID, origin.OriginShareID, false)
+	if origin.OriginShareItemID != "" {
+		query = query.Where("origin_share_item_id = ?", origin.OriginShareItemID)
+	}
+	if err := query.First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Share already saved to library", "image_id": existing.ImageID})
+		return false
+	}
+
+	var taken int64
+	database.DB.Model(&models.Image{}).Where("image_id = ?", input.ImageID).Count(&taken)
+	if taken > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "image_id already in use"})
+		return false
+	}
+
+	if input.ReuseCiphertext {
+		size, err := services.StatObjectSize(shareObjectName)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shared image not found in storage"})
+			return false
+		}
+		if err := services.CheckQuota(userID, size); err != nil {
+			respondQuotaExceeded(c, userID)
+			return false
+		}
+
+		originalPath := imageObjectName(userID, input.ImageID, "original")
+		if err := services.CopyObject(shareObjectName, originalPath); err != nil {
+			fmt.Println("SaveShareToLibrary copy error:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy shared image"})
+			return false
+		}
+		services.RecordObjectSize(userID, originalPath, size)
+	} else if err := services.CheckQuota(userID, input.Size); err != nil {
+		respondQuotaExceeded(c, userID)
+		return false
+	}
+
+	now := time.Now()
+	img := models.Image{
+		ImageID:           input.ImageID,
+		UserID:            userID,
+		CreatedAt:         input.CreatedAt,
+		UploadedAt:        now,
+		ModifiedAt:        now,
+		Width:             input.Width,
+		Height:            input.Height,
+		Size:              input.Size,
+		Checksum:          input.Checksum,
+		MimeType:          input.MimeType,
+		Album:             input.Album,
+		OriginShareID:     origin.OriginShareID,
+		OriginShareItemID: origin.OriginShareItemID,
+		OriginSenderID:    origin.OriginSenderID,
+	}
+	if img.CreatedAt.IsZero() {
+		img.CreatedAt = now
+	}
+	if input.ReuseCiphertext {
+		img.EncryptedFileKey = input.EncryptedFileKey
+		img.FileKeyNonce = input.FileKeyNonce
+	}
+
+	if err := database.DB.Create(&img).Error; err != nil {
+		fmt.Println("SaveShareToLibrary DB Error:", err)
+		if input.ReuseCiphertext {
+			// Nothing references the copy without the row, so don't leave it behind
+			originalPath := imageObjectName(userID, input.ImageID, "original")
+			_ = services.DeleteObject(originalPath)
+			services.ForgetObjects(originalPath)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register saved image"})
+		return false
+	}
+
+	variants := append([]string{}, savedThumbnailVariants...)
+	if !input.ReuseCiphertext {
+		variants = append(variants, "original")
+	}
+
+	urls := make(map[string]string, len(variants))
+	expiry := 7 * 24 * time.Hour
+	for _, variant := range variants {
+		url, err := services.GetPresignedPutURL(imageObjectName(userID, img.ImageID, variant), expiry)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to generate URL for %s: %v", variant, err)})
+			return false
+		}
+		urls[variant] = url
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Shared image saved to library",
+		"image":       img,
+		"upload_urls": urls,
+	})
+	return true
+}
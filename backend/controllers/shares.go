@@ -19,16 +19,15 @@ type ShareCreateInput struct {
 	ShareType         string `json:"share_type" binding:"required"` // one_time | normal
 	EncryptedShareKey string `json:"encrypted_share_key"`           // base64, share_key encrypted for receiver
 	SenderPublicKey   string `json:"sender_public_key"`             // base64, for receiver to decrypt
+	// Fingerprint of the receiver key the client wrapped the share key for (optional);
+	// the share is refused if the receiver's key has changed since the client fetched it
+	ReceiverKeyFingerprint string `json:"receiver_key_fingerprint"`
 }
 
 // CreateShare creates a new share (sender uploads encrypted image to shares/; this endpoint just creates the DB record)
 // Client flow: 1) Get upload URL from backend 2) Upload encrypted image 3) Call this with share metadata
 func CreateShare(c *gin.Context) {
-	senderID := c.Query("user_id")
-	if senderID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
-		return
-	}
+	senderID := c.GetString("username")
 
 	var input ShareCreateInput
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -53,6 +52,24 @@ func CreateShare(c *gin.Context) {
 		return
 	}
 
+	if isBlocked(receiver.Username, senderID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Receiver is not accepting shares from you"})
+		return
+	}
+
+	receiverKey, err := services.CurrentUserKey(receiver.Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Receiver has no registered key"})
+		return
+	}
+	if input.ReceiverKeyFingerprint != "" && input.ReceiverKeyFingerprint != receiverKey.Fingerprint {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":               "Receiver key has changed, fetch the new key and verify it",
+			"current_fingerprint": receiverKey.Fingerprint,
+		})
+		return
+	}
+
 	shareID := uuid.New().String()
 	share := models.Share{
 		ID:                shareID,
@@ -62,7 +79,12 @@ func CreateShare(c *gin.Context) {
 		ShareType:         input.ShareType,
 		EncryptedShareKey: input.EncryptedShareKey,
 		SenderPublicKey:   input.SenderPublicKey,
-		CreatedAt:         time.Now(),
+		Status:            models.ShareStatusPending,
+
+		SenderKeyFingerprint:   services.Fingerprint(input.SenderPublicKey),
+		ReceiverKeyFingerprint: receiverKey.Fingerprint,
+		ReceiverKeyVersion:     receiverKey.Version,
+		CreatedAt:              time.Now(),
 	}
 
 	if err := database.DB.Create(&share).Error; err != nil {
@@ -70,18 +92,25 @@ func CreateShare(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, models.AuditEvent{Event: services.AuditShareCreated, TargetUserID: receiver.Username, TargetType: "share", TargetID: shareID}, gin.H{"image_id": share.ImageID, "share_type": share.ShareType})
+	services.NotifyUser(receiver.Username, services.MailShareReceived, map[string]interface{}{
+		"Sender": senderID,
+		"Count":  1,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
-		"share_id":   shareID,
-		"created_at": share.CreatedAt,
+		"share_id":                 shareID,
+		"receiver_key_fingerprint": share.ReceiverKeyFingerprint,
+		"created_at":               share.CreatedAt,
 	})
 }
 
 // GetShareUploadURL returns a presigned PUT URL for uploading the shared (re-encrypted) image
 func GetShareUploadURL(c *gin.Context) {
 	shareID := c.Param("id")
-	userID := c.Query("user_id")
-	if userID == "" || shareID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "id and user_id required"})
+	userID := c.GetString("username")
+	if shareID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
 		return
 	}
 
@@ -92,6 +121,11 @@ func GetShareUploadURL(c *gin.Context) {
 		return
 	}
 
+	if err := services.CheckQuota(userID, 0); err != nil {
+		respondQuotaExceeded(c, userID)
+		return
+	}
+
 	objectName := "shares/" + shareID + ".enc"
 	url, err := services.GetPresignedPutURL(objectName, 15*time.Minute)
 	if err != nil {
@@ -103,15 +137,19 @@ func GetShareUploadURL(c *gin.Context) {
 }
 
 // ListSharesWithMe returns shares where current user is receiver
+// Query Params: status (pending | accepted | declined, optional; declined shares are hidden by default)
 func ListSharesWithMe(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
-		return
+	userID := c.GetString("username")
+
+	query := database.DB.Where("receiver_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	} else {
+		query = query.Where("status != ?", models.ShareStatusDeclined)
 	}
 
 	var shares []models.Share
-	if err := database.DB.Where("receiver_id = ?", userID).Order("created_at DESC").Find(&shares).Error; err != nil {
+	if err := query.Order("created_at DESC").Find(&shares).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list shares"})
 		return
 	}
@@ -142,11 +180,7 @@ func ListSharesWithMe(c *gin.Context) {
 
 // ListSharesByMe returns shares where current user is sender
 func ListSharesByMe(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
-		return
-	}
+	userID := c.GetString("username")
 
 	var shares []models.Share
 	if err := database.DB.Where("sender_id = ?", userID).Order("created_at DESC").Find(&shares).Error; err != nil {
@@ -160,9 +194,9 @@ func ListSharesByMe(c *gin.Context) {
 // GetShare returns share metadata for receiver (includes keys for decryption)
 func GetShare(c *gin.Context) {
 	shareID := c.Param("id")
-	userID := c.Query("user_id")
-	if userID == "" || shareID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "id and user_id required"})
+	userID := c.GetString("username")
+	if shareID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
 		return
 	}
 
@@ -172,6 +206,11 @@ func GetShare(c *gin.Context) {
 		return
 	}
 
+	if share.Status == models.ShareStatusDeclined {
+		c.JSON(http.StatusGone, gin.H{"error": "This share was declined"})
+		return
+	}
+
 	// For one_time, check if already viewed
 	if share.ShareType == models.ShareTypeOneTime && share.ViewedAt != nil {
 		c.JSON(http.StatusGone, gin.H{"error": "This share was one-time and has already been viewed"})
@@ -183,18 +222,24 @@ func GetShare(c *gin.Context) {
 		"sender_id":           share.SenderID,
 		"image_id":            share.ImageID,
 		"share_type":          share.ShareType,
+		"status":              share.Status,
 		"encrypted_share_key": share.EncryptedShareKey,
 		"sender_public_key":   share.SenderPublicKey,
 		"created_at":          share.CreatedAt,
+
+		"sender_key_fingerprint":   share.SenderKeyFingerprint,
+		"receiver_key_fingerprint": share.ReceiverKeyFingerprint,
+		"receiver_key_version":     share.ReceiverKeyVersion,
+		"sender_key_known":         isKnownUserKey(share.SenderID, share.SenderKeyFingerprint),
 	})
 }
 
 // GetShareDownloadURL returns presigned GET URL for the shared image
 func GetShareDownloadURL(c *gin.Context) {
 	shareID := c.Param("id")
-	userID := c.Query("user_id")
-	if userID == "" || shareID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "id and user_id required"})
+	userID := c.GetString("username")
+	if shareID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
 		return
 	}
 
@@ -204,6 +249,11 @@ func GetShareDownloadURL(c *gin.Context) {
 		return
 	}
 
+	if share.Status == models.ShareStatusDeclined {
+		c.JSON(http.StatusGone, gin.H{"error": "This share was declined"})
+		return
+	}
+
 	// For one_time, check if already viewed
 	if share.ShareType == models.ShareTypeOneTime && share.ViewedAt != nil {
 		c.JSON(http.StatusGone, gin.H{"error": "This share was one-time and has already been viewed"})
@@ -222,6 +272,7 @@ func GetShareDownloadURL(c *gin.Context) {
 		now := time.Now()
 		database.DB.Model(&share).Update("viewed_at", now)
 	}
+	recordAudit(c, models.AuditEvent{Event: services.AuditShareViewed, TargetUserID: share.SenderID, TargetType: "share", TargetID: share.ID}, nil)
 
 	c.JSON(http.StatusOK, gin.H{
 		"download_url": url,
@@ -233,36 +284,60 @@ func GetShareDownloadURL(c *gin.Context) {
 // RevokeShare allows sender to revoke a share
 func RevokeShare(c *gin.Context) {
 	shareID := c.Param("id")
-	userID := c.Query("user_id")
-	if userID == "" || shareID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "id and user_id required"})
+	userID := c.GetString("username")
+	if shareID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
 		return
 	}
 
-	result := database.DB.Where("id = ? AND sender_id = ?", shareID, userID).Delete(&models.Share{})
-	if result.RowsAffected == 0 {
+	var share models.Share
+	if err := database.DB.Where("id = ? AND sender_id = ?", shareID, userID).First(&share).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
 		return
 	}
+	if err := database.DB.Delete(&share).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share"})
+		return
+	}
+	recordAudit(c, models.AuditEvent{Event: services.AuditShareRevoked, TargetUserID: share.ReceiverID, TargetType: "share", TargetID: share.ID}, nil)
 
 	// Optionally delete the object from MinIO
 	objectName := "shares/" + shareID + ".enc"
 	_ = services.DeleteObject(objectName)
+	services.ForgetObjects(objectName)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Share revoked"})
 }
 
-// SearchUsers returns usernames matching prefix (for share autocomplete)
+// searchLimiter throttles user search per client IP and per requesting user
+var searchLimiter = services.NewRateLimiter(30, time.Minute)
+
+// SearchUsers returns usernames matching prefix (for share autocomplete).
+// Prefix matches only include users who are public or contacts of the requester;
+// users with exact-match discoverability are only returned when q is their full username.
 func SearchUsers(c *gin.Context) {
 	prefix := c.Query("q")
 	excludeID := c.Query("exclude") // current user to exclude
+	requester := c.GetString("username")
+
+	if !searchLimiter.Allow("ip:"+c.ClientIP()) || !searchLimiter.Allow("user:"+requester) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many searches, please slow down"})
+		return
+	}
+
 	if len(prefix) < 2 {
 		c.JSON(http.StatusOK, gin.H{"usernames": []string{}})
 		return
 	}
 
+	contacts := contactUsernames(requester)
+
 	var usernames []string
-	query := database.DB.Model(&models.User{}).Where("username LIKE ?", prefix+"%").Limit(10)
+	query := database.DB.Model(&models.User{}).
+		Where("username LIKE ?", prefix+"%").
+		Where("discoverability = ? OR username IN ? OR (discoverability = ? AND username = ?)",
+			models.DiscoverabilityPublic, contacts, models.DiscoverabilityExact, prefix).
+		Limit(10)
 	if excludeID != "" {
 		query = query.Where("username != ?", excludeID)
 	}
@@ -273,19 +348,44 @@ func SearchUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"usernames": usernames})
 }
 
-// GetUserPublicKey returns a user's public key (for share encryption)
+// GetUserPublicKey returns a user's public key (for share encryption).
+// Users who are discoverable by contacts only look nonexistent to everyone else.
 func GetUserPublicKey(c *gin.Context) {
 	username := c.Param("username")
+	requester := c.GetString("username")
 	if username == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "username required"})
 		return
 	}
 
 	var user models.User
-	if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil {
+	if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil || !canDiscover(requester, &user) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"public_key": user.PublicKey})
+	key, err := services.CurrentUserKey(user.Username)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no registered key"})
+		return
+	}
+
+	resp := gin.H{
+		"public_key":     key.PublicKey,
+		"fingerprint":    key.Fingerprint,
+		"key_version":    key.Version,
+		"key_created_at": key.CreatedAt,
+		"key_changed":    false,
+	}
+
+	// Warn the requester if this differs from the fingerprint they pinned
+	if requester != "" {
+		var pin models.PinnedKey
+		if err := database.DB.Where("user_id = ? AND contact_id = ?", requester, user.Username).First(&pin).Error; err == nil {
+			resp["pinned_fingerprint"] = pin.Fingerprint
+			resp["key_changed"] = pin.Fingerprint != key.Fingerprint
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
 }
@@ -19,12 +19,7 @@ func BatchUploadImages(c *gin.Context) {
 		return
 	}
 
-	// Get Username
-	username := c.PostForm("username")
-	if username == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Username is required"})
-		return
-	}
+	username := c.GetString("username")
 
 	files := form.File["files"]
 	if len(files) == 0 {
@@ -32,6 +27,15 @@ func BatchUploadImages(c *gin.Context) {
 		return
 	}
 
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+	if err := services.CheckQuota(username, total); err != nil {
+		respondQuotaExceeded(c, username)
+		return
+	}
+
 	var uploaded []map[string]interface{}
 	var failed []map[string]interface{}
 
@@ -54,6 +58,7 @@ func BatchUploadImages(c *gin.Context) {
 		if err != nil {
 			failed = append(failed, gin.H{"filename": file.Filename, "error": fmt.Sprintf("MinIO upload failed: %v", err)})
 		} else {
+			services.RecordObjectSize(username, info.Key, info.Size)
 			uploaded = append(uploaded, gin.H{
 				"filename": file.Filename,
 				"location": info.Location,
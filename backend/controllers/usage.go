@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/services"
+)
+
+// respondQuotaExceeded rejects an upload that would take the user over their storage quota
+func respondQuotaExceeded(c *gin.Context, userID string) {
+	used, _ := services.StorageUsage(userID)
+	c.JSON(http.StatusInsufficientStorage, gin.H{
+		"error":       "Storage quota exceeded",
+		"used_bytes":  used,
+		"quota_bytes": services.QuotaFor(userID),
+	})
+}
+
+// GetUsage returns the current user's storage usage per category and their quota
+func GetUsage(c *gin.Context) {
+	userID := c.GetString("username")
+
+	used, categories := services.StorageUsage(userID)
+	quota := services.QuotaFor(userID)
+
+	var remaining interface{}
+	if quota > 0 {
+		left := quota - used
+		if left < 0 {
+			left = 0
+		}
+		remaining = left
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"used_bytes":      used,
+		"quota_bytes":     quota,
+		"remaining_bytes": remaining,
+		"categories":      categories,
+	})
+}
+
+// FinalizeImageUpload records the size of variants uploaded through GenerateUploadURLs
+func FinalizeImageUpload(c *gin.Context) {
+	var input struct {
+		ImageID  string   `json:"image_id" binding:"required"`
+		Variants []string `json:"variants" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetString("username")
+	sizes := make(map[string]int64)
+	var missing []string
+	overQuota := false
+	for _, variant := range input.Variants {
+		size, err := services.RecordUpload(userID, uploadObjectName(userID, input.ImageID, variant))
+		if errors.Is(err, services.ErrQuotaExceeded) {
+			overQuota = true
+			continue
+		}
+		if err != nil {
+			missing = append(missing, variant)
+			continue
+		}
+		sizes[variant] = size
+	}
+	if overQuota {
+		respondQuotaExceeded(c, userID)
+		return
+	}
+
+	used, _ := services.StorageUsage(userID)
+	c.JSON(http.StatusOK, gin.H{"sizes": sizes, "missing": missing, "used_bytes": used})
+}
+
+// FinalizeShareUpload records the size of a share's uploaded ciphertext against the sender
+func FinalizeShareUpload(c *gin.Context) {
+	shareID := c.Param("id")
+	userID := c.GetString("username")
+	if shareID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var share models.Share
+	if err := database.DB.Where("id = ? AND sender_id = ?", shareID, userID).First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+
+	size, err := services.RecordUpload(userID, "shares/"+share.ID+".enc")
+	if errors.Is(err, services.ErrQuotaExceeded) {
+		respondQuotaExceeded(c, userID)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share has not been uploaded"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"size": size})
+}
+
+// FinalizeShareBundleUpload records the sizes of a bundle's uploaded ciphertexts against the sender
+func FinalizeShareBundleUpload(c *gin.Context) {
+	bundleID := c.Param("id")
+	userID := c.GetString("username")
+	if bundleID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+
+	var bundle models.ShareBundle
+	if err := database.DB.Where("id = ? AND sender_id = ?", bundleID, userID).First(&bundle).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share bundle not found"})
+		return
+	}
+
+	var itemIDs []string
+	database.DB.Model(&models.ShareBundleItem{}).Where("bundle_id = ?", bundle.ID).Pluck("id", &itemIDs)
+
+	var total int64
+	missing := []string{}
+	overQuota := false
+	for _, id := range itemIDs {
+		size, err := services.RecordUpload(userID, shareBundleObjectName(bundle.ID, id))
+		if errors.Is(err, services.ErrQuotaExceeded) {
+			overQuota = true
+			continue
+		}
+		if err != nil {
+			missing = append(missing, id)
+			continue
+		}
+		total += size
+	}
+	if overQuota {
+		respondQuotaExceeded(c, userID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"total_bytes": total, "missing_items": missing})
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,7 +11,9 @@ import (
 
 	"chithram/controllers"
 	"chithram/database"
+	"chithram/middleware"
 	"chithram/models"
+	"chithram/secagg"
 	"chithram/services"
 
 	"github.com/gin-gonic/gin"
@@ -18,21 +22,44 @@ import (
 // ModelInfo represents the metadata of a model
 type ModelInfo struct {
 	Name    string `json:"name"`
-	Version string `json:"version"` // For now, we can use file mod time or a hash as version
+	Version string `json:"version"` // Registry version, or the file mod time for unregistered models
 	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256,omitempty"`
+	Channel string `json:"channel,omitempty"`
 }
 
 func main() {
 	// Connect to database
 	database.Connect()
 	// Auto migrate
-	database.DB.AutoMigrate(&models.User{}, &models.Image{}, &models.Share{}, &models.ModelMetadata{}, &models.ModelMetric{})
+	database.DB.AutoMigrate(&models.User{}, &models.Image{}, &models.Share{}, &models.ShareBundle{}, &models.ShareBundleItem{}, &models.BlockedUser{}, &models.Contact{}, &models.UserKey{}, &models.KeyLogEntry{}, &models.PinnedKey{}, &models.StorageObject{}, &models.ArchiveJob{}, &models.AccountDeletion{}, &models.Session{}, &models.Device{}, &models.AuditEvent{}, &models.Invite{}, &models.OutboxMessage{}, &models.EmailVerification{}, &models.FLRound{}, &models.FLUpdate{}, &models.ModelMetadata{}, &models.ModelMetric{}, &models.ModelVersion{}, &models.ModelChannel{}, &models.EvalJob{})
 
 	// Seed initial model metadata if missing
 	seedModelMetadata()
+	services.InitModelRegistry()
 
 	// Init MinIO
 	services.InitMinio()
+	services.InitStorageQuotas()
+	services.InitReconciler()
+	services.InitSessions()
+	services.InitDevices()
+	services.InitRegistration()
+
+	// Admin commands: go run . reconcile [--dry-run] | go run . make-admin <username> [--revoke] | go run . secagg-simulate
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+	services.StartReconcileSchedule()
+	services.InitArchives()
+	services.InitMailer()
+
+	// Key log signing key, and key history for users created before it existed
+	services.InitKeyLog()
+	services.BackfillUserKeys()
+	services.BackfillAdminActions()
+	services.InitAccountDeletion()
 
 	r := gin.Default()
 
@@ -59,49 +86,162 @@ func main() {
 	// Auth Endpoints
 	r.POST("/signup", controllers.Signup)
 	r.POST("/login", controllers.Login)
+	r.POST("/logout", middleware.RequireAuth(), controllers.Logout)
+	r.GET("/registration", controllers.GetRegistrationInfo)
+	r.GET("/verify-email", controllers.VerifyEmail)
+	r.POST("/account/verify-email", middleware.RequireAuth(), controllers.ResendVerificationEmail)
+	r.POST("/account/password", middleware.RequireAuth(), controllers.ChangePassword)
+
+	// Device Endpoints (session token required)
+	r.GET("/devices", middleware.RequireAuth(), controllers.ListDevices)
+	r.PUT("/devices/:device_id", middleware.RequireAuth(), controllers.RegisterDevice)
+	r.POST("/devices/:device_id/status", middleware.RequireAuth(), controllers.ReportDeviceStatus)
+	r.DELETE("/devices/:device_id", middleware.RequireAuth(), controllers.RemoveDevice)
+
+	// Audit Log (session token required)
+	r.GET("/audit", middleware.RequireAuth(), controllers.GetMyAudit)
+
+	// Invite Endpoints (session token required)
+	r.POST("/invites", middleware.RequireAuth(), controllers.CreateInvite)
+	r.GET("/invites", middleware.RequireAuth(), controllers.ListMyInvites)
+	r.DELETE("/invites/:code", middleware.RequireAuth(), controllers.RevokeMyInvite)
+
+	// User Endpoints (session token required; the user is taken from the session)
+	authed := r.Group("", middleware.RequireAuth())
+
+	// Account Deletion Endpoints
+	authed.POST("/account/delete", controllers.RequestAccountDeletion)
+	authed.GET("/account/delete", controllers.GetAccountDeletion)
+	authed.DELETE("/account/delete", controllers.CancelAccountDeletion)
+	r.GET("/account/deletions/:id/receipt", controllers.GetDeletionReceipt) // the account is gone by then
 
 	// Upload Endpoint
-	r.POST("/upload", controllers.BatchUploadImages)
+	authed.POST("/upload", controllers.BatchUploadImages)
 
 	// Image Endpoints
-	r.DELETE("/images", controllers.DeleteImages)
-	r.POST("/images/delete", controllers.DeleteImages) // Windows/Dart POST-with-body fallback
-	r.PUT("/images/location", controllers.UpdateImageLocation)
-	r.PUT("/images/album", controllers.UpdateImageAlbum)
-	r.PUT("/images/favorite", controllers.UpdateImageFavorite)
-	r.GET("/albums", controllers.GetAlbums)
-	r.GET("/images", controllers.ListImages)
-	r.GET("/images/:id", controllers.GetSingleImage)
-	r.POST("/images/register", controllers.RegisterOrUpdateImage)
-	r.POST("/images/upload_urls", controllers.GenerateUploadURLs)
-	r.GET("/images/checksums", controllers.GetChecksums)  // Add this
-	r.GET("/images/source_ids", controllers.GetSourceIDs) // Add this for fast deduplication
-	r.GET("/images/faces", controllers.GetFacesDownloadURL)
-	r.GET("/images/faces/version", controllers.GetPeopleVersion)
-	r.POST("/images/faces/register", controllers.RegisterPeopleVersion)
-
-	r.GET("/images/semantic", controllers.GetSemanticDownloadURL)
-	r.GET("/images/semantic/version", controllers.GetSemanticVersion)
-	r.POST("/images/semantic/register", controllers.RegisterSemanticVersion)
-
-	r.GET("/sync", controllers.SyncImages)
-	r.GET("/images/download/:id", controllers.DownloadImage)
+	authed.DELETE("/images", controllers.DeleteImages)
+	authed.POST("/images/delete", controllers.DeleteImages) // Windows/Dart POST-with-body fallback
+	authed.PUT("/images/location", controllers.UpdateImageLocation)
+	authed.PUT("/images/album", controllers.UpdateImageAlbum)
+	authed.PUT("/images/favorite", controllers.UpdateImageFavorite)
+	authed.GET("/albums", controllers.GetAlbums)
+	authed.GET("/images", controllers.ListImages)
+	authed.GET("/images/:id", controllers.GetSingleImage)
+	authed.POST("/images/register", controllers.RegisterOrUpdateImage)
+	authed.POST("/images/upload_urls", controllers.GenerateUploadURLs)
+	authed.POST("/images/finalize", controllers.FinalizeImageUpload)
+	authed.GET("/images/checksums", controllers.GetChecksums)  // Add this
+	authed.GET("/images/source_ids", controllers.GetSourceIDs) // Add this for fast deduplication
+	authed.GET("/images/faces", controllers.GetFacesDownloadURL)
+	authed.GET("/images/faces/version", controllers.GetPeopleVersion)
+	authed.POST("/images/faces/register", controllers.RegisterPeopleVersion)
+
+	authed.GET("/images/semantic", controllers.GetSemanticDownloadURL)
+	authed.GET("/images/semantic/version", controllers.GetSemanticVersion)
+	authed.POST("/images/semantic/register", controllers.RegisterSemanticVersion)
+
+	authed.GET("/usage", controllers.GetUsage)
+
+	// Account Export/Import Endpoints
+	authed.POST("/exports", controllers.StartExport)
+	authed.GET("/exports", controllers.ListArchiveJobs)
+	authed.GET("/exports/:id", controllers.GetArchiveJob)
+	authed.GET("/exports/:id/download", controllers.DownloadExport)
+	authed.POST("/imports", controllers.ImportArchive)
+	authed.GET("/imports/:id", controllers.GetArchiveJob)
+
+	authed.GET("/sync", controllers.SyncImages)
+	authed.GET("/images/download/:id", controllers.DownloadImage)
 
 	// Share Endpoints (static paths before :id)
-	r.POST("/shares", controllers.CreateShare)
-	r.GET("/shares/with-me", controllers.ListSharesWithMe)
-	r.GET("/shares/by-me", controllers.ListSharesByMe)
-	r.GET("/shares/:id/upload-url", controllers.GetShareUploadURL)
-	r.GET("/shares/:id/download-url", controllers.GetShareDownloadURL)
-	r.GET("/shares/:id", controllers.GetShare)
-	r.DELETE("/shares/:id", controllers.RevokeShare)
-	r.GET("/users/search", controllers.SearchUsers)
-	r.GET("/users/:username/public-key", controllers.GetUserPublicKey)
+	authed.POST("/shares", controllers.CreateShare)
+	authed.GET("/shares/with-me", controllers.ListSharesWithMe)
+	authed.GET("/shares/by-me", controllers.ListSharesByMe)
+	authed.GET("/shares/:id/upload-url", controllers.GetShareUploadURL)
+	authed.POST("/shares/:id/finalize", controllers.FinalizeShareUpload)
+	authed.GET("/shares/:id/download-url", controllers.GetShareDownloadURL)
+	authed.POST("/shares/:id/save", controllers.SaveShareToLibrary)
+	authed.POST("/shares/:id/accept", controllers.AcceptShare)
+	authed.POST("/shares/:id/decline", controllers.DeclineShare)
+	authed.GET("/shares/:id", controllers.GetShare)
+	authed.DELETE("/shares/:id", controllers.RevokeShare)
+
+	// Share Bundle Endpoints (multi-image shares)
+	authed.POST("/share-bundles", controllers.CreateShareBundle)
+	authed.GET("/share-bundles/with-me", controllers.ListShareBundlesWithMe)
+	authed.GET("/share-bundles/by-me", controllers.ListShareBundlesByMe)
+	authed.GET("/share-bundles/:id/upload-urls", controllers.GetShareBundleUploadURLs)
+	authed.POST("/share-bundles/:id/finalize", controllers.FinalizeShareBundleUpload)
+	authed.GET("/share-bundles/:id/items", controllers.ListShareBundleItems)
+	authed.GET("/share-bundles/:id/items/:item_id/download-url", controllers.GetShareBundleItemDownloadURL)
+	authed.POST("/share-bundles/:id/items/:item_id/save", controllers.SaveShareBundleItemToLibrary)
+	authed.POST("/share-bundles/:id/accept", controllers.AcceptShareBundle)
+	authed.POST("/share-bundles/:id/decline", controllers.DeclineShareBundle)
+
+	// Blocklist Endpoints
+	authed.GET("/blocks", controllers.ListBlockedUsers)
+	authed.POST("/blocks", controllers.BlockUser)
+	authed.DELETE("/blocks/:username", controllers.UnblockUser)
+	authed.DELETE("/share-bundles/:id", controllers.RevokeShareBundle)
+
+	authed.GET("/users/search", controllers.SearchUsers)
+	authed.PUT("/users/discoverability", controllers.UpdateDiscoverability)
+	authed.GET("/users/:username/public-key", controllers.GetUserPublicKey)
+	authed.POST("/users/keys/rotate", controllers.RotateKeypair)
+	authed.GET("/users/keys/rewrap", controllers.ListSharesToRewrap)
+	authed.POST("/users/keys/rewrap", controllers.RewrapShares)
+	authed.GET("/users/:username/keys", controllers.GetUserKeyHistory)
+
+	// Key Transparency Endpoints
+	authed.GET("/pins", controllers.ListPinnedKeys)
+	authed.PUT("/pins/:username", controllers.PinKey)
+	authed.DELETE("/pins/:username", controllers.UnpinKey)
+	authed.GET("/keylog", controllers.GetKeyLog)
+	r.GET("/keylog/public-key", controllers.GetKeyLogPublicKey)
+
+	// Contact Endpoints
+	authed.GET("/contacts", controllers.ListContacts)
+	authed.GET("/contacts/requests", controllers.ListContactRequests)
+	authed.POST("/contacts/requests", controllers.SendContactRequest)
+	authed.POST("/contacts/requests/:id/accept", controllers.AcceptContactRequest)
+	authed.POST("/contacts/requests/:id/decline", controllers.DeclineContactRequest)
+	authed.DELETE("/contacts/:username", controllers.RemoveContact)
+
+	// Admin Endpoints (session token of an admin user required)
+	admin := r.Group("/admin", middleware.RequireAuth(), middleware.RequireAdmin())
+	admin.GET("/users", controllers.AdminListUsers)
+	admin.GET("/users/:username", controllers.AdminGetUser)
+	admin.POST("/users/:username/disable", controllers.AdminDisableUser)
+	admin.POST("/users/:username/enable", controllers.AdminEnableUser)
+	admin.POST("/users/:username/logout", controllers.AdminForceLogout)
+	admin.PUT("/users/:username/quota", controllers.AdminSetQuota)
+	admin.GET("/fl/status", controllers.AdminGetFLStatus)
+	admin.GET("/fl/rounds", controllers.AdminListRounds)
+	admin.GET("/fl/rounds/:id/updates", controllers.AdminListRoundUpdates)
+	admin.GET("/fl/quarantine", controllers.AdminListQuarantine)
+	admin.GET("/fl/jobs/:id", controllers.AdminGetEvalJob)
+	admin.GET("/models", controllers.AdminListModels)
+	admin.GET("/models/:name/versions", controllers.AdminListModelVersions)
+	admin.POST("/models/:name/rollback", controllers.AdminRollbackModel)
+	admin.GET("/actions", controllers.AdminListActions)
+	admin.GET("/audit", controllers.AdminListAudit)
+	admin.POST("/invites", controllers.AdminCreateInvite)
+	admin.GET("/invites", controllers.AdminListInvites)
+	admin.DELETE("/invites/:code", controllers.AdminRevokeInvite)
 
 	// Federated Learning Endpoints
 	services.InitFLService()
-	r.POST("/fl/update", controllers.UploadLocalUpdate)
+	r.GET("/fl/round", controllers.GetCurrentRound)
+	authed.POST("/fl/update", controllers.UploadLocalUpdate)
+	authed.GET("/fl/updates", controllers.ListMyUpdates)
 	r.GET("/fl/global", controllers.GetGlobalModel)
+	r.GET("/fl/jobs", controllers.ListEvalJobs)
+	r.GET("/fl/jobs/:id", controllers.GetEvalJob)
+	authed.GET("/fl/rounds/:id/secagg", controllers.GetSecAggState)
+	authed.POST("/fl/rounds/:id/secagg/keys", controllers.SubmitSecAggKeys)
+	authed.POST("/fl/rounds/:id/secagg/shares", controllers.SubmitSecAggShares)
+	authed.POST("/fl/rounds/:id/secagg/masked", controllers.SubmitSecAggMasked)
+	authed.POST("/fl/rounds/:id/secagg/unmask", controllers.SubmitSecAggUnmask)
 	r.GET("/dashboard", controllers.GetDashboard)
 
 	// Ensure models directory exists
@@ -111,8 +251,24 @@ func main() {
 	}
 
 	// Model Info Endpoint
+	// Query Params: channel (default stable)
 	r.GET("/models/:name/info", func(c *gin.Context) {
 		modelName := c.Param("name")
+		channel := c.DefaultQuery("channel", models.ModelChannelStable)
+
+		if v, err := services.ChannelVersion(modelName, channel); err == nil {
+			c.JSON(http.StatusOK, ModelInfo{
+				Name:    modelName,
+				Version: v.Version,
+				Size:    v.Size,
+				SHA256:  v.SHA256,
+				Channel: channel,
+			})
+			return
+		} else if channel != models.ModelChannelStable {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Model channel not found"})
+			return
+		}
 
 		var meta models.ModelMetadata
 		if err := database.DB.Where("name = ?", modelName).First(&meta).Error; err != nil {
@@ -140,10 +296,20 @@ func main() {
 	})
 
 	// Model Download Endpoint
+	// Query Params: channel (default stable, served from the models directory)
 	r.GET("/models/:name/download", func(c *gin.Context) {
 		modelName := c.Param("name")
 		modelPath := filepath.Join(modelsDir, modelName+".onnx")
 
+		if channel := c.Query("channel"); channel != "" && channel != models.ModelChannelStable {
+			v, err := services.ChannelVersion(modelName, channel)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Model channel not found"})
+				return
+			}
+			modelPath = v.FilePath
+		}
+
 		if _, err := os.Stat(modelPath); os.IsNotExist(err) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
 			return
@@ -176,6 +342,70 @@ func main() {
 	}
 }
 
+// runCommand executes an admin subcommand instead of starting the server
+func runCommand(name string, args []string) {
+	switch name {
+	case "reconcile":
+		fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+		dryRun := fs.Bool("dry-run", false, "report orphans and dangling rows without changing anything")
+		grace := fs.Duration("grace", services.ReconcileGrace, "only act on objects and rows older than this")
+		fs.Parse(args)
+
+		services.ReconcileGrace = *grace
+		report, err := services.Reconcile(*dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Reconciliation failed: %v\n", err)
+			os.Exit(1)
+		}
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+	case "make-admin":
+		fs := flag.NewFlagSet("make-admin", flag.ExitOnError)
+		revoke := fs.Bool("revoke", false, "remove admin rights instead of granting them")
+		fs.Parse(args)
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: make-admin [--revoke] <username>")
+			os.Exit(2)
+		}
+
+		username := fs.Arg(0)
+		result := database.DB.Model(&models.User{}).Where("username = ?", username).Update("is_admin", !*revoke)
+		if result.Error != nil || result.RowsAffected == 0 {
+			fmt.Fprintf(os.Stderr, "User %q not found\n", username)
+			os.Exit(1)
+		}
+		services.RecordAudit(models.AuditEvent{
+			Event:        services.AuditAdminPrefix + map[bool]string{false: "grant_admin", true: "revoke_admin"}[*revoke],
+			ActorID:      "cli",
+			TargetUserID: username,
+			TargetType:   "user",
+			TargetID:     username,
+		}, nil)
+		fmt.Printf("%s is_admin=%v\n", username, !*revoke)
+	case "secagg-simulate":
+		fs := flag.NewFlagSet("secagg-simulate", flag.ExitOnError)
+		sim := secagg.Simulation{}
+		fs.IntVar(&sim.Clients, "clients", 10, "number of simulated participants")
+		fs.IntVar(&sim.Length, "length", 100000, "length of each participant's update")
+		fs.IntVar(&sim.DropBeforeMasking, "drop-before-masking", 2, "participants that vanish after sharing keys")
+		fs.IntVar(&sim.DropBeforeUnmask, "drop-before-unmask", 1, "participants that upload but never help unmask")
+		fs.Parse(args)
+
+		res, err := secagg.Simulate(sim)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Secure aggregation failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%d of %d participants aggregated (threshold %d), max error %g\n", res.Survivors, sim.Clients, res.Threshold, res.MaxError)
+		if res.MaxError > 0 {
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q (available: reconcile, make-admin, secagg-simulate)\n", name)
+		os.Exit(2)
+	}
+}
+
 func seedModelMetadata() {
 	modelsDir := "./models"
 	files, _ := os.ReadDir(modelsDir)
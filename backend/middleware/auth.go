@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/services"
+)
+
+// RequireAuth rejects requests without a valid "Authorization: Bearer <token>" session.
+// On success the username is available as c.GetString("username"), the user as c.Get("user")
+// and the session as c.Get("session").
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		session, err := services.ValidateSession(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		var user models.User
+		if err := database.DB.Where("username = ?", session.UserID).First(&user).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		if user.Disabled {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+			return
+		}
+
+		c.Set("username", user.Username)
+		c.Set("user", &user)
+		c.Set("session", session)
+		c.Next()
+	}
+}
+
+// RequireAdmin must run after RequireAuth and only lets admins through
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.Get("user")
+		if !ok || !user.(*models.User).IsAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Account deletion statuses
+const (
+	DeletionStatusScheduled = "scheduled"
+	DeletionStatusCancelled = "cancelled"
+	DeletionStatusRunning   = "running"
+	DeletionStatusCompleted = "completed"
+	DeletionStatusFailed    = "failed"
+)
+
+// AccountDeletion is a self-service request to delete an account. It waits out a grace period
+// and is then purged step by step; Step records progress so an interrupted purge can resume.
+type AccountDeletion struct {
+	ID               string     `gorm:"primaryKey;type:text" json:"id"`
+	UserID           string     `gorm:"index;not null" json:"user_id"`
+	Status           string     `gorm:"index;not null;default:scheduled" json:"status"` // scheduled | cancelled | running | completed | failed
+	Step             string     `json:"step,omitempty"`                                 // last purge step that finished
+	RequestedAt      time.Time  `json:"requested_at"`
+	ScheduledFor     time.Time  `gorm:"index" json:"scheduled_for"`
+	StartedAt        *time.Time `json:"started_at,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	Counts           string     `gorm:"type:text" json:"-"`                 // JSON running totals of what was removed
+	Receipt          string     `gorm:"type:text" json:"receipt,omitempty"` // JSON receipt, signed with the server key
+	ReceiptSignature string     `json:"receipt_signature,omitempty"`        // base64 ed25519 signature over Receipt
+	Error            string     `json:"error,omitempty"`
+}
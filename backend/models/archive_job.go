@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// Archive job kinds
+const (
+	ArchiveKindExport = "export"
+	ArchiveKindImport = "import"
+)
+
+// Archive job statuses
+const (
+	ArchiveStatusPending   = "pending"
+	ArchiveStatusRunning   = "running"
+	ArchiveStatusCompleted = "completed"
+	ArchiveStatusFailed    = "failed"
+)
+
+// ArchiveJob tracks a full account export into a portable archive, or the import of one
+type ArchiveJob struct {
+	ID               string     `gorm:"primaryKey;type:text" json:"id"`
+	UserID           string     `gorm:"index;not null" json:"user_id"`
+	Kind             string     `gorm:"index;not null" json:"kind"`                   // export | import
+	Status           string     `gorm:"index;not null;default:pending" json:"status"` // pending | running | completed | failed
+	TotalObjects     int        `json:"total_objects"`
+	ProcessedObjects int        `json:"processed_objects"`
+	TotalBytes       int64      `json:"total_bytes"`
+	ProcessedBytes   int64      `json:"processed_bytes"`
+	FilePath         string     `json:"-"` // archive on local disk
+	Error            string     `json:"error,omitempty"`
+	Summary          string     `gorm:"type:text" json:"summary,omitempty"` // JSON counts of what was exported/imported
+	CreatedAt        time.Time  `json:"created_at"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+}
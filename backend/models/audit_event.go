@@ -0,0 +1,35 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditAppendOnly is returned when something tries to change or remove an audit event
+var ErrAuditAppendOnly = errors.New("audit events are append-only")
+
+// AuditEvent is one entry of the append-only security audit log
+type AuditEvent struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Event        string    `gorm:"index;not null" json:"event"`     // e.g. login_success, share_viewed, admin.disable_user
+	ActorID      string    `gorm:"index" json:"actor_id,omitempty"` // username, "system" or "cli"; empty for failed logins
+	TargetUserID string    `gorm:"index" json:"target_user_id,omitempty"`
+	TargetType   string    `json:"target_type,omitempty"` // user, image, share, share_bundle, invite, model
+	TargetID     string    `json:"target_id,omitempty"`
+	IP           string    `json:"ip,omitempty"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	Details      string    `gorm:"type:text" json:"details,omitempty"` // JSON
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+// BeforeUpdate keeps audit events immutable
+func (e *AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+// BeforeDelete keeps audit events from being removed
+func (e *AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditAppendOnly
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// BlockedUser records that UserID refuses shares from BlockedID
+type BlockedUser struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    string    `gorm:"uniqueIndex:idx_blocked_pair;not null" json:"user_id"`
+	BlockedID string    `gorm:"uniqueIndex:idx_blocked_pair;not null" json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// ContactStatus: a contact request stays pending until the addressee accepts it
+const (
+	ContactStatusPending  = "pending"
+	ContactStatusAccepted = "accepted"
+)
+
+// Contact links two users; RequesterID sent the request to AddresseeID
+type Contact struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	RequesterID string     `gorm:"uniqueIndex:idx_contact_pair;not null" json:"requester_id"`
+	AddresseeID string     `gorm:"uniqueIndex:idx_contact_pair;index;not null" json:"addressee_id"`
+	Status      string     `gorm:"index;not null" json:"status"` // pending | accepted
+	CreatedAt   time.Time  `json:"created_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+}
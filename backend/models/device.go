@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Device is an app install of a user, keyed by an ID the client generates once and keeps
+type Device struct {
+	ID                uint       `gorm:"primaryKey" json:"-"`
+	UserID            string     `gorm:"uniqueIndex:idx_device_user;not null" json:"user_id"`
+	DeviceID          string     `gorm:"uniqueIndex:idx_device_user;not null" json:"device_id"`
+	Name              string     `json:"name"`
+	Platform          string     `json:"platform"` // android, ios, windows, macos, linux, web
+	AppVersion        string     `json:"app_version"`
+	SourceIDNamespace string     `json:"source_id_namespace"` // scope of the Image.SourceID values this device assigns
+	LastSyncCursor    string     `json:"last_sync_cursor"`
+	LastBackupAt      *time.Time `json:"last_backup_at"`
+	PendingUploads    int        `json:"pending_uploads"`
+	StatusReportedAt  *time.Time `json:"status_reported_at"`
+	LastSeenAt        time.Time  `json:"last_seen_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// EmailVerification is a single-use link sent to confirm a user owns their email address.
+// Only the sha256 of the token is stored.
+type EmailVerification struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	UserID    string     `gorm:"index;not null" json:"user_id"`
+	Email     string     `gorm:"not null" json:"email"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// Evaluation job statuses
+const (
+	EvalJobQueued    = "queued"
+	EvalJobRunning   = "running"
+	EvalJobSucceeded = "succeeded"
+	EvalJobFailed    = "failed" // gave up after the maximum number of attempts
+)
+
+// EvalJob is one evaluation of a model version, run by the evaluation workers. Jobs for a
+// round's candidate carry the round, which is promoted or rejected once they finish.
+type EvalJob struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	ModelName     string     `gorm:"index;not null" json:"model_name"`
+	Version       string     `gorm:"index;not null" json:"version"`
+	ModelPath     string     `json:"-"`
+	RoundID       *uint      `gorm:"index" json:"round_id,omitempty"`
+	Status        string     `gorm:"index;not null;default:queued" json:"status"` // queued | running | succeeded | failed
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	Accuracy      *float64   `json:"accuracy,omitempty"`
+	Loss          *float64   `json:"loss,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	Output        string     `gorm:"type:text" json:"output,omitempty"` // evaluator output of the last attempt, truncated
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
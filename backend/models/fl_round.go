@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+)
+
+// FL round states. A round is open until its first update arrives, collects updates until it
+// reaches its target or deadline, then is aggregated, evaluated and finally promoted or rejected.
+// Models promoted manually skip evaluation and end published on the candidate channel.
+const (
+	FLRoundOpen        = "open"
+	FLRoundCollecting  = "collecting"
+	FLRoundAggregating = "aggregating"
+	FLRoundEvaluating  = "evaluating"
+	FLRoundPromoted    = "promoted"
+	FLRoundRejected    = "rejected"
+	FLRoundPublished   = "published"
+)
+
+// FLRound is one federated learning round against a fixed base model version
+type FLRound struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	ModelName          string     `gorm:"index;not null" json:"model_name"`
+	BaseVersion        string     `json:"base_version"`
+	TargetParticipants int        `json:"target_participants"`
+	MinParticipants    int        `json:"min_participants"`
+	Deadline           time.Time  `json:"deadline"`
+	Status             string     `gorm:"index;not null" json:"status"`
+	Updates            int        `json:"updates"`
+	Strategy           string     `gorm:"index" json:"strategy"`              // aggregation strategy, fixed when the round opens
+	StrategyParams     string     `gorm:"type:text" json:"strategy_params"`   // JSON AggregationConfig
+	Privacy            string     `gorm:"type:text" json:"privacy,omitempty"` // JSON PrivacyConfig, fixed when the round opens
+	SecureAggregation  bool       `json:"secure_aggregation"`                 // updates arrive masked through the secagg protocol
+	PrivacyRho         float64    `json:"privacy_rho,omitempty"`              // zCDP cost of the noisy aggregate
+	Epsilon            *float64   `json:"epsilon,omitempty"`                  // the model's cumulative epsilon after this round
+	ResultModel        string     `json:"result_model,omitempty"`             // aggregated file in AggregatedModelsDir
+	ResultVersion      string     `json:"result_version,omitempty"`           // registered version of the aggregate
+	Error              string     `json:"error,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	ClosedAt           *time.Time `json:"closed_at,omitempty"`
+}
+
+// FL update states
+const (
+	FLUpdateAccepted    = "accepted"
+	FLUpdateQuarantined = "quarantined"
+)
+
+// FLUpdate is a locally trained update submitted to a round, with the training metadata the
+// client reported alongside it
+type FLUpdate struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	RoundID     uint      `gorm:"index;uniqueIndex:idx_fl_update_user,where:status = 'accepted';not null" json:"round_id"`
+	UserID      string    `gorm:"index;uniqueIndex:idx_fl_update_user" json:"user_id,omitempty"` // one accepted update per user per round
+	FilePath    string    `json:"-"`
+	BaseVersion string    `json:"base_version"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Status       string `gorm:"index;default:accepted" json:"status"`
+	RejectCode   string `json:"reject_code,omitempty"`
+	RejectReason string `json:"reject_reason,omitempty"`
+
+	SampleCount     int      `json:"sample_count"`
+	Epochs          int      `json:"epochs"`
+	LocalLoss       *float64 `json:"local_loss,omitempty"`
+	LocalAccuracy   *float64 `json:"local_accuracy,omitempty"`
+	AppVersion      string   `json:"app_version,omitempty"`
+	TrainingSeconds float64  `json:"training_seconds"`
+	Weight          float64  `json:"weight"` // aggregation weight derived from SampleCount
+}
@@ -20,4 +20,14 @@ type Image struct {
 	MimeType   string    `json:"mime_type"`
 	Album      string    `json:"album"`
 	IsDeleted  bool      `json:"is_deleted"`
+
+	// Set by storage reconciliation when the original is missing from MinIO so clients re-upload it
+	ObjectsMissing bool `json:"objects_missing" gorm:"default:false"`
+
+	// Provenance for images imported from a received share
+	OriginShareID     string `json:"origin_share_id,omitempty" gorm:"index"`        // Share or ShareBundle ID the image was saved from
+	OriginShareItemID string `json:"origin_share_item_id,omitempty"`                // ShareBundleItem ID when saved from a bundle
+	OriginSenderID    string `json:"origin_sender_id,omitempty"`                    // Username of the original sender
+	EncryptedFileKey  string `json:"encrypted_file_key,omitempty" gorm:"type:text"` // base64, set when the original is encrypted with its own key (reused share ciphertext) instead of the master key
+	FileKeyNonce      string `json:"file_key_nonce,omitempty"`                      // Nonce for EncryptedFileKey (encrypted by MasterKey)
 }
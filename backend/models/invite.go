@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// Invite is a registration code. MaxUses 0 means unlimited uses until it expires or is revoked.
+type Invite struct {
+	Code      string     `gorm:"primaryKey;type:text" json:"code"`
+	CreatedBy string     `gorm:"index;not null" json:"created_by"`
+	Note      string     `json:"note,omitempty"`
+	MaxUses   int        `gorm:"not null" json:"max_uses"`
+	Uses      int        `gorm:"not null;default:0" json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Model release channels
+const (
+	ModelChannelStable    = "stable"    // served to every client
+	ModelChannelCandidate = "candidate" // newest aggregate, awaiting promotion
+)
+
+// Where a model version came from
+const (
+	ModelSourceSeed    = "seed"     // a model file that predates the registry
+	ModelSourceFLRound = "fl_round" // aggregated from a federated learning round
+)
+
+// ErrModelVersionImmutable is returned when something tries to change or remove a registered version
+var ErrModelVersionImmutable = errors.New("model versions are immutable")
+
+// ModelVersion is one immutable build of a model in the registry. Evaluation results are
+// ModelMetric rows with the same model name and version.
+type ModelVersion struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ModelName     string    `gorm:"uniqueIndex:idx_model_version;not null" json:"model_name"`
+	Version       string    `gorm:"uniqueIndex:idx_model_version;not null" json:"version"`
+	SHA256        string    `gorm:"index;not null" json:"sha256"`
+	Size          int64     `json:"size"`
+	FilePath      string    `json:"-"`
+	ParentVersion string    `json:"parent_version,omitempty"`
+	RoundID       *uint     `json:"round_id,omitempty"`
+	Epsilon       *float64  `json:"epsilon,omitempty"` // cumulative differential privacy epsilon spent training up to this version
+	Source        string    `json:"source"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// BeforeUpdate keeps registered versions immutable
+func (v *ModelVersion) BeforeUpdate(tx *gorm.DB) error {
+	return ErrModelVersionImmutable
+}
+
+// BeforeDelete keeps registered versions from being removed
+func (v *ModelVersion) BeforeDelete(tx *gorm.DB) error {
+	return ErrModelVersionImmutable
+}
+
+// ModelChannel points a release channel of a model at one of its versions
+type ModelChannel struct {
+	ModelName string    `gorm:"primaryKey" json:"model_name"`
+	Channel   string    `gorm:"primaryKey" json:"channel"`
+	Version   string    `json:"version"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
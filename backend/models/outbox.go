@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Outbox message statuses
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusFailed  = "failed" // gave up after the maximum number of attempts
+)
+
+// OutboxMessage is an email waiting to be sent (or already sent) by the mailer worker
+type OutboxMessage struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	To            string     `gorm:"not null" json:"to"`
+	Template      string     `gorm:"index;not null" json:"template"`
+	Subject       string     `gorm:"not null" json:"subject"`
+	Body          string     `gorm:"type:text;not null" json:"-"`
+	Status        string     `gorm:"index;not null;default:pending" json:"status"` // pending | sent | failed
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is a login session. Only the sha256 of the bearer token is stored.
+type Session struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	UserID     string     `gorm:"index;not null" json:"user_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	DeviceID   string     `gorm:"index" json:"device_id,omitempty"` // client-generated ID of the registered Device, if any
+}
@@ -10,14 +10,26 @@ const (
 	ShareTypeNormal  = "normal"
 )
 
+// ShareStatus: receivers start with pending shares in their inbox and accept or decline them
+const (
+	ShareStatusPending  = "pending"
+	ShareStatusAccepted = "accepted"
+	ShareStatusDeclined = "declined"
+)
+
 type Share struct {
-	ID                 string     `gorm:"primaryKey;type:text" json:"id"`
-	SenderID           string     `gorm:"index;not null" json:"sender_id"`
-	ReceiverID         string     `gorm:"index;not null" json:"receiver_id"`
-	ImageID            string     `gorm:"index;not null" json:"image_id"`
-	ShareType          string     `gorm:"not null" json:"share_type"` // one_time | normal
-	EncryptedShareKey  string     `gorm:"type:text" json:"-"`         // base64, for receiver to decrypt image
-	SenderPublicKey    string     `gorm:"type:text" json:"-"`         // base64, for receiver to decrypt share_key
-	ViewedAt           *time.Time `json:"viewed_at,omitempty"`
-	CreatedAt          time.Time  `json:"created_at"`
+	ID                     string     `gorm:"primaryKey;type:text" json:"id"`
+	SenderID               string     `gorm:"index;not null" json:"sender_id"`
+	ReceiverID             string     `gorm:"index;not null" json:"receiver_id"`
+	ImageID                string     `gorm:"index;not null" json:"image_id"`
+	ShareType              string     `gorm:"not null" json:"share_type"`                   // one_time | normal
+	EncryptedShareKey      string     `gorm:"type:text" json:"-"`                           // base64, for receiver to decrypt image
+	SenderPublicKey        string     `gorm:"type:text" json:"-"`                           // base64, for receiver to decrypt share_key
+	SenderKeyFingerprint   string     `json:"sender_key_fingerprint"`                       // fingerprint of SenderPublicKey
+	ReceiverKeyFingerprint string     `json:"receiver_key_fingerprint"`                     // fingerprint of the receiver key the share key was wrapped for
+	ReceiverKeyVersion     int        `gorm:"default:1" json:"receiver_key_version"`        // UserKey version of the receiver the share key was wrapped for
+	Status                 string     `gorm:"index;not null;default:pending" json:"status"` // pending | accepted | declined
+	RespondedAt            *time.Time `json:"responded_at,omitempty"`
+	ViewedAt               *time.Time `json:"viewed_at,omitempty"`
+	CreatedAt              time.Time  `json:"created_at"`
 }
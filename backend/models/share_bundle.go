@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// ShareBundle groups several images shared from one sender to one receiver
+// so they can be listed, captioned and revoked as a single share.
+type ShareBundle struct {
+	ID                     string     `gorm:"primaryKey;type:text" json:"id"`
+	SenderID               string     `gorm:"index;not null" json:"sender_id"`
+	ReceiverID             string     `gorm:"index;not null" json:"receiver_id"`
+	Caption                string     `json:"caption"`
+	ItemCount              int        `json:"item_count"`
+	SenderKeyFingerprint   string     `json:"sender_key_fingerprint"`                       // fingerprint of the sender's public key used for the items
+	ReceiverKeyFingerprint string     `json:"receiver_key_fingerprint"`                     // fingerprint of the receiver key the item keys were wrapped for
+	Status                 string     `gorm:"index;not null;default:pending" json:"status"` // pending | accepted | declined
+	RespondedAt            *time.Time `json:"responded_at,omitempty"`
+	CreatedAt              time.Time  `json:"created_at"`
+}
+
+// ShareBundleItem is a single image inside a ShareBundle, with its own encrypted payload key
+type ShareBundleItem struct {
+	ID                 string    `gorm:"primaryKey;type:text" json:"id"`
+	BundleID           string    `gorm:"index;not null" json:"bundle_id"`
+	ImageID            string    `gorm:"not null" json:"image_id"`
+	Position           int       `gorm:"index" json:"position"`
+	EncryptedShareKey  string    `gorm:"type:text" json:"encrypted_share_key"`  // base64, share_key encrypted for receiver
+	SenderPublicKey    string    `gorm:"type:text" json:"sender_public_key"`    // base64, for receiver to decrypt share_key
+	ReceiverKeyVersion int       `gorm:"default:1" json:"receiver_key_version"` // UserKey version of the receiver the share key was wrapped for
+	CreatedAt          time.Time `json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Storage categories used for usage accounting
+const (
+	StorageCategoryOriginals  = "originals"
+	StorageCategoryThumbnails = "thumbnails"
+	StorageCategoryMetadata   = "metadata"
+	StorageCategoryShares     = "shares"
+)
+
+// StorageObject is the usage ledger: one row per stored object, charged to the user who uploaded it
+type StorageObject struct {
+	ObjectName string    `gorm:"primaryKey;type:text" json:"object_name"`
+	UserID     string    `gorm:"index;not null" json:"user_id"`
+	Category   string    `gorm:"index;not null" json:"category"` // originals | thumbnails | metadata | shares
+	Size       int64     `json:"size"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -1,12 +1,28 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Discoverability controls who can find a user through search and fetch their public key
+const (
+	DiscoverabilityPublic   = "public"   // any user can find them by username prefix
+	DiscoverabilityContacts = "contacts" // only accepted contacts can find them
+	DiscoverabilityExact    = "exact"    // anyone typing the exact username can find them
+)
+
+// QuotaUnlimited is the QuotaBytes override that exempts a user from the server default quota
+const QuotaUnlimited int64 = -1
 
 type User struct {
 	gorm.Model
 	Username string `json:"username" gorm:"unique"`
 	Email    string `json:"email" gorm:"unique"`
-	Password string `json:"password"` // Authenticating password hash (bcrypt)
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"` // nil until the verification link is used
+	Password        string     `json:"password"`                    // Authenticating password hash (bcrypt)
 
 	// Key Derivation Parameters
 	KEKSalt string `json:"kek_salt"` // stored base64
@@ -24,4 +40,13 @@ type User struct {
 
 	PeopleVersion   int `json:"people_version" gorm:"default:0"`
 	SemanticVersion int `json:"semantic_version" gorm:"default:0"`
+
+	Discoverability string `json:"discoverability" gorm:"default:exact"` // public | contacts | exact
+
+	QuotaBytes int64 `json:"quota_bytes" gorm:"default:0"` // Per-user storage quota override, 0 = server default, QuotaUnlimited = no quota
+
+	IsAdmin  bool `json:"is_admin" gorm:"default:false"` // Can use the /admin API
+	Disabled bool `json:"disabled" gorm:"default:false"` // Disabled accounts can't log in
+
+	InviteCode string `json:"invite_code,omitempty" gorm:"index"` // Invite redeemed at signup, if any
 }
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// UserKey is one version of a user's keypair; the highest version is current and older ones are retired
+type UserKey struct {
+	ID          uint       `gorm:"primaryKey" json:"-"`
+	UserID      string     `gorm:"uniqueIndex:idx_user_key_version;not null" json:"user_id"`
+	Version     int        `gorm:"uniqueIndex:idx_user_key_version;not null" json:"version"`
+	PublicKey   string     `gorm:"type:text;not null" json:"public_key"` // base64
+	Fingerprint string     `gorm:"index;not null" json:"fingerprint"`    // hex sha256 of the raw public key
+	CreatedAt   time.Time  `json:"created_at"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+
+	// Kept so the owner can still open shares wrapped for a retired key until they are re-wrapped
+	EncryptedPrivateKey string `gorm:"type:text" json:"-"` // decrypted by MasterKey
+	PrivateKeyNonce     string `json:"-"`
+}
+
+// KeyLog actions
+const (
+	KeyLogActionAdded   = "added"
+	KeyLogActionRotated = "rotated"
+	KeyLogActionDeleted = "account_deleted"
+)
+
+// KeyLogEntry is one record of the append-only, server-signed public key log.
+// EntryHash chains to the previous entry so any rewrite of history is detectable.
+type KeyLogEntry struct {
+	Seq         uint      `gorm:"primaryKey;autoIncrement:false" json:"seq"`
+	UserID      string    `gorm:"index;not null" json:"user_id"`
+	KeyVersion  int       `json:"key_version"`
+	Fingerprint string    `json:"fingerprint"`
+	Action      string    `json:"action"` // added | rotated
+	PrevHash    string    `json:"prev_hash"`
+	EntryHash   string    `json:"entry_hash"`
+	Signature   string    `json:"signature"` // base64 ed25519 signature of EntryHash by the server key
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PinnedKey is a fingerprint a user has verified for one of their contacts
+type PinnedKey struct {
+	ID          uint      `gorm:"primaryKey" json:"-"`
+	UserID      string    `gorm:"uniqueIndex:idx_pinned_pair;not null" json:"user_id"`
+	ContactID   string    `gorm:"uniqueIndex:idx_pinned_pair;not null" json:"contact_id"`
+	Fingerprint string    `gorm:"not null" json:"fingerprint"`
+	KeyVersion  int       `json:"key_version"`
+	PinnedAt    time.Time `json:"pinned_at"`
+}
@@ -0,0 +1,193 @@
+package secagg
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Client is one participant's side of a round. A client is single-use: its keys are
+// ephemeral and it answers each phase at most once.
+type Client struct {
+	id      string
+	encKey  *ecdh.PrivateKey
+	maskKey *ecdh.PrivateKey
+	seed    []byte
+
+	roster   *Roster
+	selfSeed Share                   // own share of the seed, kept rather than sent
+	received map[string]sharePayload // shares from peers that reached the shares phase
+	unmasked bool
+}
+
+// NewClient creates a client for participant id with fresh keys and self-mask seed
+func NewClient(id string) (*Client, error) {
+	encKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	maskKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return &Client{id: id, encKey: encKey, maskKey: maskKey, seed: seed}, nil
+}
+
+// ID returns the participant ID
+func (c *Client) ID() string {
+	return c.id
+}
+
+// PublicKeys returns the keys to advertise in the keys phase
+func (c *Client) PublicKeys() PublicKeys {
+	return PublicKeys{EncKey: c.encKey.PublicKey().Bytes(), MaskKey: c.maskKey.PublicKey().Bytes()}
+}
+
+// ShareKeys answers the shares phase: it splits the mask key and seed among the roster and
+// returns one ciphertext per peer, keyed by recipient ID
+func (c *Client) ShareKeys(roster Roster) (map[string][]byte, error) {
+	if c.roster != nil {
+		return nil, ErrDuplicate
+	}
+	me := roster.index(c.id)
+	if me < 0 {
+		return nil, ErrNotParticipant
+	}
+	n := len(roster.Participants)
+	if roster.Threshold < DefaultThreshold(n) || roster.Threshold > n {
+		// a lower threshold would let the server collude with too few participants
+		return nil, fmt.Errorf("threshold %d is unsafe for %d participants", roster.Threshold, n)
+	}
+	for i, p := range roster.Participants {
+		if i > 0 && roster.Participants[i-1].ID >= p.ID {
+			return nil, errors.New("roster is not sorted by ID")
+		}
+		if p.ID == c.id && (!slices.Equal(p.Keys.EncKey, c.PublicKeys().EncKey) || !slices.Equal(p.Keys.MaskKey, c.PublicKeys().MaskKey)) {
+			return nil, errors.New("roster does not carry this client's keys")
+		}
+	}
+
+	keyShares, err := Split(c.maskKey.Bytes(), n, roster.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	seedShares, err := Split(c.seed, n, roster.Threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]byte, n-1)
+	for i, p := range roster.Participants {
+		if i == me {
+			continue
+		}
+		key, err := agree(c.encKey, p.Keys.EncKey, "share encryption")
+		if err != nil {
+			return nil, fmt.Errorf("key agreement with %s: %w", p.ID, err)
+		}
+		plaintext, err := json.Marshal(sharePayload{From: c.id, To: p.ID, MaskKey: keyShares[i], Seed: seedShares[i]})
+		if err != nil {
+			return nil, err
+		}
+		if out[p.ID], err = seal(key, plaintext, shareAAD(c.id, p.ID)); err != nil {
+			return nil, err
+		}
+	}
+
+	c.roster = &roster
+	c.selfSeed = seedShares[me]
+	return out, nil
+}
+
+// MaskInput answers the masked phase. incoming holds the ciphertexts addressed to this client
+// by every other participant that completed the shares phase, keyed by sender; together with
+// this client they are the peers whose masks are applied. input must already be encoded.
+func (c *Client) MaskInput(input []uint64, incoming map[string][]byte) ([]uint64, error) {
+	if c.roster == nil {
+		return nil, ErrWrongPhase
+	}
+	if c.received != nil {
+		return nil, ErrDuplicate
+	}
+	if len(incoming)+1 < c.roster.Threshold {
+		return nil, ErrTooFewParticipants
+	}
+
+	received := make(map[string]sharePayload, len(incoming))
+	masked := slices.Clone(input)
+	for from, ciphertext := range incoming {
+		i := c.roster.index(from)
+		if i < 0 || from == c.id {
+			return nil, fmt.Errorf("share from %q: %w", from, ErrNotParticipant)
+		}
+		peer := c.roster.Participants[i]
+
+		key, err := agree(c.encKey, peer.Keys.EncKey, "share encryption")
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := open(key, ciphertext, shareAAD(from, c.id))
+		if err != nil {
+			return nil, fmt.Errorf("share from %s: %w", from, err)
+		}
+		var p sharePayload
+		if err := json.Unmarshal(plaintext, &p); err != nil || p.From != from || p.To != c.id {
+			return nil, fmt.Errorf("share from %s is malformed", from)
+		}
+		received[from] = p
+
+		seed, err := agree(c.maskKey, peer.Keys.MaskKey, "pairwise mask")
+		if err != nil {
+			return nil, err
+		}
+		applyMask(masked, seed, pairSign(c.id, from))
+	}
+	applyMask(masked, c.seed, 1)
+
+	c.received = received
+	return masked, nil
+}
+
+// Unmask answers the unmask phase given the participants whose masked inputs the server
+// received. It reveals seed shares for those survivors and mask key shares for the peers that
+// dropped after the shares phase, and refuses to answer twice or for an inconsistent set.
+func (c *Client) Unmask(survivors []string) (*UnmaskResponse, error) {
+	if c.received == nil {
+		return nil, ErrWrongPhase
+	}
+	if c.unmasked {
+		return nil, ErrDuplicate
+	}
+	if len(survivors) < c.roster.Threshold {
+		return nil, ErrTooFewParticipants
+	}
+
+	alive := make(map[string]bool, len(survivors))
+	for _, id := range survivors {
+		if _, ok := c.received[id]; !ok && id != c.id {
+			return nil, fmt.Errorf("survivor %q never shared keys", id)
+		}
+		alive[id] = true
+	}
+	if !alive[c.id] {
+		return nil, errors.New("this client is not among the survivors")
+	}
+
+	resp := &UnmaskResponse{MaskKeyShares: map[string]Share{}, SeedShares: map[string]Share{c.id: c.selfSeed}}
+	for from, p := range c.received {
+		if alive[from] {
+			resp.SeedShares[from] = p.Seed
+		} else {
+			resp.MaskKeyShares[from] = p.MaskKey
+		}
+	}
+	c.unmasked = true
+	return resp, nil
+}
@@ -0,0 +1,91 @@
+package secagg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrDecrypt is returned for a share ciphertext that fails authentication
+var ErrDecrypt = errors.New("share ciphertext failed authentication")
+
+// agree derives a 32-byte key for purpose from an X25519 key agreement
+func agree(priv *ecdh.PrivateKey, peer []byte, purpose string) ([]byte, error) {
+	pub, err := ecdh.X25519().NewPublicKey(peer)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Key(sha256.New, secret, nil, "chithram secagg "+purpose, 32)
+}
+
+// seal encrypts plaintext with AES-256-GCM, binding it to aad; the nonce is prepended
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open decrypts a ciphertext made by seal
+func open(key, ciphertext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// applyMask adds (sign 1) or subtracts (sign -1) the pseudorandom mask expanded from seed to v,
+// modulo 2^64. The mask is the AES-256-CTR keystream of seed read as little-endian uint64s.
+func applyMask(v []uint64, seed []byte, sign int) {
+	block, err := aes.NewCipher(seed)
+	if err != nil {
+		panic(err) // seeds are always 32 bytes
+	}
+	stream := cipher.NewCTR(block, make([]byte, aes.BlockSize))
+
+	buf := make([]byte, 8*1024)
+	for start := 0; start < len(v); start += 1024 {
+		end := min(start+1024, len(v))
+		chunk := buf[:8*(end-start)]
+		clear(chunk)
+		stream.XORKeyStream(chunk, chunk)
+		for i := start; i < end; i++ {
+			m := binary.LittleEndian.Uint64(chunk[8*(i-start):])
+			if sign > 0 {
+				v[i] += m
+			} else {
+				v[i] -= m
+			}
+		}
+	}
+}
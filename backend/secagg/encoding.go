@@ -0,0 +1,50 @@
+package secagg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// FracBits is the fixed-point precision inputs are encoded with. Sums of up to MaxParticipants
+// values stay exact as long as each value's magnitude is below 2^31.
+const FracBits = 24
+
+// Encode converts values to fixed point in the ring of integers modulo 2^64
+func Encode(values []float32) []uint64 {
+	out := make([]uint64, len(values))
+	for i, v := range values {
+		out[i] = uint64(int64(math.Round(float64(v) * (1 << FracBits))))
+	}
+	return out
+}
+
+// Decode converts a fixed-point sum back to floats
+func Decode(sum []uint64) []float64 {
+	out := make([]float64, len(sum))
+	for i, v := range sum {
+		out[i] = float64(int64(v)) / (1 << FracBits)
+	}
+	return out
+}
+
+// MarshalVector encodes a vector as little-endian uint64s, the wire format of masked inputs
+func MarshalVector(v []uint64) []byte {
+	out := make([]byte, 0, 8*len(v))
+	for _, x := range v {
+		out = binary.LittleEndian.AppendUint64(out, x)
+	}
+	return out
+}
+
+// UnmarshalVector decodes a vector written by MarshalVector
+func UnmarshalVector(data []byte) ([]uint64, error) {
+	if len(data)%8 != 0 {
+		return nil, fmt.Errorf("%w: vector is %d bytes, not a multiple of 8", ErrInvalidMessage, len(data))
+	}
+	out := make([]uint64, len(data)/8)
+	for i := range out {
+		out[i] = binary.LittleEndian.Uint64(data[8*i:])
+	}
+	return out, nil
+}
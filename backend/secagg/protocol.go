@@ -0,0 +1,118 @@
+// Package secagg implements pairwise-masking secure aggregation in the style of Bonawitz et al.
+// (CCS 2017) for honest-but-curious servers. Every participant adds pseudorandom masks to its
+// input that cancel out in the sum. Each pair of participants derives a shared mask, and each
+// participant also adds a self mask. Mask secrets are Shamir-shared among the participants,
+// so the server can strip the masks of participants who drop out mid-round. As a result the
+// server learns only the sum of the inputs it received, never an individual input.
+//
+// A round runs in four phases, each driven by the server:
+//
+//  1. Keys: each participant advertises two X25519 public keys. One encrypts shares and
+//     the other derives the pairwise masks.
+//  2. Shares: each participant Shamir-shares its mask private key and self-mask seed. It
+//     sends every other participant one share of each, encrypted so only the recipient
+//     can read it.
+//  3. Masked: each participant uploads its input with all of its masks applied.
+//  4. Unmask: survivors reveal their shares of the self-mask seeds of participants who
+//     uploaded, and their shares of the mask keys of those who did not.
+//
+// A participant never reveals both kinds of share for the same peer, so the server can never
+// unmask an individual input.
+package secagg
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+)
+
+// Phase is a step of the protocol
+type Phase string
+
+const (
+	PhaseKeys   Phase = "keys"
+	PhaseShares Phase = "shares"
+	PhaseMasked Phase = "masked"
+	PhaseUnmask Phase = "unmask"
+	PhaseDone   Phase = "done"
+	PhaseFailed Phase = "failed"
+)
+
+var (
+	// ErrWrongPhase is returned for a message that does not belong to the current phase
+	ErrWrongPhase = errors.New("message does not belong to the current phase")
+	// ErrNotParticipant is returned for a message from someone outside the round
+	ErrNotParticipant = errors.New("not a participant in this phase")
+	// ErrDuplicate is returned for a second message from the same participant in a phase
+	ErrDuplicate = errors.New("already submitted in this phase")
+	// ErrInvalidMessage is returned for a malformed message, such as an invalid key or a vector
+	// of the wrong length
+	ErrInvalidMessage = errors.New("invalid message")
+	// ErrTooFewParticipants is returned when a phase closes with fewer participants than the
+	// threshold, after which the round cannot complete
+	ErrTooFewParticipants = errors.New("too few participants to continue")
+)
+
+// PublicKeys are the ephemeral X25519 public keys a participant advertises for one round
+type PublicKeys struct {
+	EncKey  []byte `json:"enc_key"`
+	MaskKey []byte `json:"mask_key"`
+}
+
+// Participant is an advertised participant. Participants are ordered by ID, and a
+// participant's Shamir shares are evaluated at its position in that order plus one.
+type Participant struct {
+	ID   string     `json:"id"`
+	Keys PublicKeys `json:"keys"`
+}
+
+// Roster is the outcome of the keys phase: the participants and the Shamir threshold
+type Roster struct {
+	Threshold    int           `json:"threshold"`
+	Participants []Participant `json:"participants"`
+}
+
+// index returns the position of id in the roster, or -1
+func (r *Roster) index(id string) int {
+	i, ok := slices.BinarySearchFunc(r.Participants, id, func(p Participant, id string) int {
+		return cmp.Compare(p.ID, id)
+	})
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+// UnmaskResponse is a survivor's reply in the unmask phase, keyed by the peer each share
+// belongs to
+type UnmaskResponse struct {
+	MaskKeyShares map[string]Share `json:"mask_key_shares"`
+	SeedShares    map[string]Share `json:"seed_shares"`
+}
+
+// sharePayload is the plaintext of an encrypted share
+type sharePayload struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	MaskKey Share  `json:"mask_key"`
+	Seed    Share  `json:"seed"`
+}
+
+// shareAAD binds a share ciphertext to its sender and recipient
+func shareAAD(from, to string) []byte {
+	return []byte(from + "\x00" + to)
+}
+
+// pairSign is the sign with which u applies the mask it shares with v. The lower ID adds it
+// and the higher ID subtracts it, so the masks cancel in the sum.
+func pairSign(u, v string) int {
+	if u < v {
+		return 1
+	}
+	return -1
+}
+
+// DefaultThreshold is the smallest Shamir threshold for n participants: a strict majority
+func DefaultThreshold(n int) int {
+	return n/2 + 1
+}
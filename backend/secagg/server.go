@@ -0,0 +1,367 @@
+package secagg
+
+import (
+	"cmp"
+	"crypto/ecdh"
+	"fmt"
+	"slices"
+)
+
+// Server is the aggregator's side of a round. It never holds an unmasked individual input:
+// masked inputs are folded into a running sum as they arrive. A Server is not safe for
+// concurrent use.
+type Server struct {
+	length int
+	minT   int
+	phase  Phase
+	err    error
+
+	keys      map[string]PublicKeys
+	roster    *Roster
+	shares    map[string]map[string][]byte // sender -> recipient -> ciphertext
+	sum       []uint64
+	masked    map[string]bool
+	survivors []string
+	responses map[string]*UnmaskResponse
+}
+
+// NewServer starts a round aggregating vectors of length, which completes only if at least
+// minParticipants make it through every phase
+func NewServer(length, minParticipants int) *Server {
+	return &Server{
+		length:    length,
+		minT:      max(minParticipants, 1),
+		phase:     PhaseKeys,
+		keys:      map[string]PublicKeys{},
+		shares:    map[string]map[string][]byte{},
+		masked:    map[string]bool{},
+		responses: map[string]*UnmaskResponse{},
+	}
+}
+
+// Phase returns the current phase
+func (s *Server) Phase() Phase {
+	return s.phase
+}
+
+// Err returns why the round failed, if it did
+func (s *Server) Err() error {
+	return s.err
+}
+
+// Length returns the length of the vectors being aggregated
+func (s *Server) Length() int {
+	return s.length
+}
+
+// Fail aborts the round
+func (s *Server) Fail(err error) {
+	s.phase, s.err = PhaseFailed, err
+}
+
+// Roster returns the participants and threshold once the keys phase has closed
+func (s *Server) Roster() *Roster {
+	return s.roster
+}
+
+// Survivors returns the participants whose masked inputs are in the sum, once the masked
+// phase has closed
+func (s *Server) Survivors() []string {
+	return s.survivors
+}
+
+// Joined returns how many participants advertised keys
+func (s *Server) Joined() int {
+	return len(s.keys)
+}
+
+// Pending returns the participants that still owe a message in the current phase. Phases
+// other than keys can close early once it is empty.
+func (s *Server) Pending() []string {
+	var expected []string
+	var done func(string) bool
+	switch s.phase {
+	case PhaseShares:
+		for _, p := range s.roster.Participants {
+			expected = append(expected, p.ID)
+		}
+		done = func(id string) bool { _, ok := s.shares[id]; return ok }
+	case PhaseMasked:
+		expected = s.sharers()
+		done = func(id string) bool { return s.masked[id] }
+	case PhaseUnmask:
+		expected = s.survivors
+		done = func(id string) bool { _, ok := s.responses[id]; return ok }
+	default:
+		return nil
+	}
+	var pending []string
+	for _, id := range expected {
+		if !done(id) {
+			pending = append(pending, id)
+		}
+	}
+	return pending
+}
+
+// sharers returns the participants that completed the shares phase, sorted
+func (s *Server) sharers() []string {
+	ids := make([]string, 0, len(s.shares))
+	for id := range s.shares {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// close ends the current phase, moving to next if at least the threshold took part
+func (s *Server) close(count int, next Phase) error {
+	threshold := s.minT
+	if s.roster != nil {
+		threshold = s.roster.Threshold
+	}
+	if count < threshold {
+		s.Fail(fmt.Errorf("%w: %d of %d needed in the %s phase", ErrTooFewParticipants, count, threshold, s.phase))
+		return s.err
+	}
+	s.phase = next
+	return nil
+}
+
+// AddKeys records a participant's advertised keys
+func (s *Server) AddKeys(id string, keys PublicKeys) error {
+	if s.phase != PhaseKeys {
+		return ErrWrongPhase
+	}
+	if _, ok := s.keys[id]; ok {
+		return ErrDuplicate
+	}
+	if id == "" || len(s.keys) >= MaxParticipants {
+		return ErrNotParticipant
+	}
+	if _, err := ecdh.X25519().NewPublicKey(keys.EncKey); err != nil {
+		return fmt.Errorf("%w: encryption key: %v", ErrInvalidMessage, err)
+	}
+	if _, err := ecdh.X25519().NewPublicKey(keys.MaskKey); err != nil {
+		return fmt.Errorf("%w: mask key: %v", ErrInvalidMessage, err)
+	}
+	s.keys[id] = keys
+	return nil
+}
+
+// CloseKeys ends the keys phase and fixes the roster. The threshold is a strict majority of
+// the participants, and never below the minimum the round was started with.
+func (s *Server) CloseKeys() (*Roster, error) {
+	if s.phase != PhaseKeys {
+		return nil, ErrWrongPhase
+	}
+	n := len(s.keys)
+	if n < max(s.minT, 2) {
+		s.Fail(fmt.Errorf("%w: %d advertised keys, %d needed", ErrTooFewParticipants, n, max(s.minT, 2)))
+		return nil, s.err
+	}
+	roster := &Roster{Threshold: min(max(s.minT, DefaultThreshold(n)), n)}
+	for id, keys := range s.keys {
+		roster.Participants = append(roster.Participants, Participant{ID: id, Keys: keys})
+	}
+	slices.SortFunc(roster.Participants, func(a, b Participant) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	s.roster = roster
+	s.phase = PhaseShares
+	return roster, nil
+}
+
+// AddShares records a participant's encrypted shares, which must address every other
+// participant on the roster
+func (s *Server) AddShares(from string, ciphertexts map[string][]byte) error {
+	if s.phase != PhaseShares {
+		return ErrWrongPhase
+	}
+	if s.roster.index(from) < 0 {
+		return ErrNotParticipant
+	}
+	if _, ok := s.shares[from]; ok {
+		return ErrDuplicate
+	}
+	if len(ciphertexts) != len(s.roster.Participants)-1 {
+		return fmt.Errorf("%w: expected shares for %d peers, got %d", ErrInvalidMessage, len(s.roster.Participants)-1, len(ciphertexts))
+	}
+	for to := range ciphertexts {
+		if to == from || s.roster.index(to) < 0 {
+			return fmt.Errorf("%w: share addressed to %q, who is not a peer", ErrInvalidMessage, to)
+		}
+	}
+	s.shares[from] = ciphertexts
+	return nil
+}
+
+// CloseShares ends the shares phase
+func (s *Server) CloseShares() error {
+	if s.phase != PhaseShares {
+		return ErrWrongPhase
+	}
+	return s.close(len(s.shares), PhaseMasked)
+}
+
+// SharesFor returns the ciphertexts addressed to id by the other participants that completed
+// the shares phase, keyed by sender. It is what id needs to mask its input.
+func (s *Server) SharesFor(id string) (map[string][]byte, error) {
+	if s.phase == PhaseKeys || s.phase == PhaseShares {
+		return nil, ErrWrongPhase
+	}
+	if _, ok := s.shares[id]; !ok {
+		return nil, ErrNotParticipant
+	}
+	out := make(map[string][]byte, len(s.shares)-1)
+	for from, cts := range s.shares {
+		if from != id {
+			out[from] = cts[id]
+		}
+	}
+	return out, nil
+}
+
+// AddMaskedInput folds a participant's masked input into the sum
+func (s *Server) AddMaskedInput(id string, masked []uint64) error {
+	if s.phase != PhaseMasked {
+		return ErrWrongPhase
+	}
+	if _, ok := s.shares[id]; !ok {
+		return ErrNotParticipant
+	}
+	if s.masked[id] {
+		return ErrDuplicate
+	}
+	if len(masked) != s.length {
+		return fmt.Errorf("%w: masked input has %d values, expected %d", ErrInvalidMessage, len(masked), s.length)
+	}
+	if s.sum == nil {
+		s.sum = make([]uint64, s.length)
+	}
+	for i, v := range masked {
+		s.sum[i] += v
+	}
+	s.masked[id] = true
+	return nil
+}
+
+// CloseMasked ends the masked phase and returns the survivors, whose inputs are in the sum
+func (s *Server) CloseMasked() ([]string, error) {
+	if s.phase != PhaseMasked {
+		return nil, ErrWrongPhase
+	}
+	if err := s.close(len(s.masked), PhaseUnmask); err != nil {
+		return nil, err
+	}
+	for id := range s.masked {
+		s.survivors = append(s.survivors, id)
+	}
+	slices.Sort(s.survivors)
+	return s.survivors, nil
+}
+
+// AddUnmask records a survivor's shares
+func (s *Server) AddUnmask(id string, resp *UnmaskResponse) error {
+	if s.phase != PhaseUnmask {
+		return ErrWrongPhase
+	}
+	if !s.masked[id] {
+		return ErrNotParticipant
+	}
+	if _, ok := s.responses[id]; ok {
+		return ErrDuplicate
+	}
+	s.responses[id] = resp
+	return nil
+}
+
+// Finish ends the unmask phase: it reconstructs the survivors' seeds and the dropped
+// participants' mask keys, strips every mask from the sum, and returns the sum of the
+// survivors' encoded inputs
+func (s *Server) Finish() ([]uint64, error) {
+	if s.phase != PhaseUnmask {
+		return nil, ErrWrongPhase
+	}
+	if err := s.close(len(s.responses), PhaseUnmask); err != nil {
+		return nil, err
+	}
+
+	// collect shares about peer from every responder, checking each is the responder's own
+	collect := func(peer string, pick func(*UnmaskResponse) map[string]Share) ([]Share, error) {
+		var shares []Share
+		for id, resp := range s.responses {
+			share, ok := pick(resp)[peer]
+			if !ok {
+				continue
+			}
+			if int(share.X) != s.roster.index(id)+1 {
+				return nil, fmt.Errorf("%s returned a share of %s that is not its own", id, peer)
+			}
+			shares = append(shares, share)
+		}
+		if len(shares) < s.roster.Threshold {
+			return nil, fmt.Errorf("%w: %d shares to unmask %s", ErrTooFewParticipants, len(shares), peer)
+		}
+		return shares, nil
+	}
+
+	sum := s.sum
+	for _, id := range s.survivors {
+		shares, err := collect(id, func(r *UnmaskResponse) map[string]Share { return r.SeedShares })
+		if err == nil {
+			var seed []byte
+			if seed, err = Combine(shares); err == nil && len(seed) != 32 {
+				err = fmt.Errorf("reconstructed seed of %s has %d bytes", id, len(seed))
+			}
+			if err == nil {
+				applyMask(sum, seed, -1)
+			}
+		}
+		if err != nil {
+			s.Fail(err)
+			return nil, err
+		}
+	}
+
+	for _, dropped := range s.sharers() {
+		if s.masked[dropped] {
+			continue
+		}
+		if err := s.removePairMasks(sum, dropped, collect); err != nil {
+			s.Fail(err)
+			return nil, err
+		}
+	}
+
+	s.phase = PhaseDone
+	return sum, nil
+}
+
+// removePairMasks reconstructs the mask key of a participant that dropped after sharing and
+// removes the masks the survivors applied for it
+func (s *Server) removePairMasks(sum []uint64, dropped string, collect func(string, func(*UnmaskResponse) map[string]Share) ([]Share, error)) error {
+	shares, err := collect(dropped, func(r *UnmaskResponse) map[string]Share { return r.MaskKeyShares })
+	if err != nil {
+		return err
+	}
+	secret, err := Combine(shares)
+	if err != nil {
+		return err
+	}
+	key, err := ecdh.X25519().NewPrivateKey(secret)
+	if err != nil {
+		return fmt.Errorf("reconstructed mask key of %s: %w", dropped, err)
+	}
+	if !slices.Equal(key.PublicKey().Bytes(), s.keys[dropped].MaskKey) {
+		return fmt.Errorf("reconstructed mask key of %s does not match its advertised key", dropped)
+	}
+	for _, id := range s.survivors {
+		seed, err := agree(key, s.keys[id].MaskKey, "pairwise mask")
+		if err != nil {
+			return err
+		}
+		applyMask(sum, seed, -pairSign(id, dropped))
+	}
+	return nil
+}
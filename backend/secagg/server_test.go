@@ -0,0 +1,291 @@
+package secagg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+const testLength = 16
+
+// testRound drives a Server and in-process Clients through the keys and shares phases
+type testRound struct {
+	t       *testing.T
+	server  *Server
+	clients []*Client
+	inputs  map[string][]float32
+}
+
+func newTestRound(t *testing.T, n int) *testRound {
+	t.Helper()
+	r := &testRound{t: t, server: NewServer(testLength, 2), inputs: map[string][]float32{}}
+	for i := range n {
+		c, err := NewClient(fmt.Sprintf("client-%02d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.server.AddKeys(c.ID(), c.PublicKeys()); err != nil {
+			t.Fatal(err)
+		}
+		input := make([]float32, testLength)
+		for j := range input {
+			input[j] = float32(i+1) * float32(j-testLength/2) / 8
+		}
+		r.clients = append(r.clients, c)
+		r.inputs[c.ID()] = input
+	}
+	if _, err := r.server.CloseKeys(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// share runs the shares phase for clients
+func (r *testRound) share(clients []*Client) {
+	r.t.Helper()
+	for _, c := range clients {
+		cts, err := c.ShareKeys(*r.server.Roster())
+		if err != nil {
+			r.t.Fatal(err)
+		}
+		if err := r.server.AddShares(c.ID(), cts); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+}
+
+// mask runs the masked phase for clients
+func (r *testRound) mask(clients []*Client) {
+	r.t.Helper()
+	for _, c := range clients {
+		incoming, err := r.server.SharesFor(c.ID())
+		if err != nil {
+			r.t.Fatal(err)
+		}
+		masked, err := c.MaskInput(Encode(r.inputs[c.ID()]), incoming)
+		if err != nil {
+			r.t.Fatal(err)
+		}
+		if err := r.server.AddMaskedInput(c.ID(), masked); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+}
+
+// unmask runs the unmask phase for clients and returns their responses
+func (r *testRound) unmask(clients []*Client) map[string]*UnmaskResponse {
+	r.t.Helper()
+	responses := map[string]*UnmaskResponse{}
+	for _, c := range clients {
+		resp, err := c.Unmask(r.server.Survivors())
+		if err != nil {
+			r.t.Fatal(err)
+		}
+		responses[c.ID()] = resp
+	}
+	return responses
+}
+
+// checkSum asserts that the decoded sum is the plain sum of the survivors' inputs
+func (r *testRound) checkSum(sum []uint64, survivors []*Client) {
+	r.t.Helper()
+	expected := make([]float64, testLength)
+	for _, c := range survivors {
+		for i, v := range r.inputs[c.ID()] {
+			expected[i] += float64(v)
+		}
+	}
+	for i, v := range Decode(sum) {
+		if math.Abs(v-expected[i]) > 1e-5 {
+			r.t.Fatalf("sum[%d] = %v, want %v", i, v, expected[i])
+		}
+	}
+}
+
+func TestServerFullRound(t *testing.T) {
+	r := newTestRound(t, 5)
+	r.share(r.clients)
+	if err := r.server.CloseShares(); err != nil {
+		t.Fatal(err)
+	}
+	r.mask(r.clients)
+	if _, err := r.server.CloseMasked(); err != nil {
+		t.Fatal(err)
+	}
+	for id, resp := range r.unmask(r.clients) {
+		if err := r.server.AddUnmask(id, resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum, err := r.server.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.checkSum(sum, r.clients)
+}
+
+func TestServerDropoutInSharesPhase(t *testing.T) {
+	r := newTestRound(t, 5)
+	sharers := r.clients[1:]
+	r.share(sharers)
+	if err := r.server.CloseShares(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.server.SharesFor(r.clients[0].ID()); !errors.Is(err, ErrNotParticipant) {
+		t.Fatalf("SharesFor a participant that did not share: got %v, want ErrNotParticipant", err)
+	}
+	r.mask(sharers)
+	if _, err := r.server.CloseMasked(); err != nil {
+		t.Fatal(err)
+	}
+	for id, resp := range r.unmask(sharers) {
+		if err := r.server.AddUnmask(id, resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum, err := r.server.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.checkSum(sum, sharers)
+}
+
+func TestServerDropoutInMaskedPhase(t *testing.T) {
+	r := newTestRound(t, 7)
+	r.share(r.clients)
+	if err := r.server.CloseShares(); err != nil {
+		t.Fatal(err)
+	}
+	survivors := r.clients[2:]
+	r.mask(survivors)
+	if got, _ := r.server.CloseMasked(); len(got) != len(survivors) {
+		t.Fatalf("got %d survivors, want %d", len(got), len(survivors))
+	}
+	if err := r.server.AddUnmask(r.clients[0].ID(), &UnmaskResponse{}); !errors.Is(err, ErrNotParticipant) {
+		t.Fatalf("unmask from a dropped participant: got %v, want ErrNotParticipant", err)
+	}
+	// one survivor also misses the unmask phase; the rest are still above the threshold
+	for id, resp := range r.unmask(survivors[1:]) {
+		if err := r.server.AddUnmask(id, resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum, err := r.server.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.checkSum(sum, survivors)
+}
+
+func TestServerRejectsDuplicateUnmask(t *testing.T) {
+	r := newTestRound(t, 3)
+	r.share(r.clients)
+	if err := r.server.CloseShares(); err != nil {
+		t.Fatal(err)
+	}
+	r.mask(r.clients)
+	if _, err := r.server.CloseMasked(); err != nil {
+		t.Fatal(err)
+	}
+	id := r.clients[0].ID()
+	resp := r.unmask(r.clients[:1])[id]
+	if err := r.server.AddUnmask(id, resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.server.AddUnmask(id, resp); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("second unmask: got %v, want ErrDuplicate", err)
+	}
+}
+
+func TestServerRejectsForgedShareX(t *testing.T) {
+	r := newTestRound(t, 4)
+	r.share(r.clients)
+	if err := r.server.CloseShares(); err != nil {
+		t.Fatal(err)
+	}
+	r.mask(r.clients)
+	if _, err := r.server.CloseMasked(); err != nil {
+		t.Fatal(err)
+	}
+	responses := r.unmask(r.clients)
+
+	// the first client passes off the second client's share of a seed as its own, which both
+	// forges its X and duplicates the second client's
+	forger, victim, peer := r.clients[0].ID(), r.clients[1].ID(), r.clients[2].ID()
+	responses[forger].SeedShares[peer] = responses[victim].SeedShares[peer]
+	for id, resp := range responses {
+		if err := r.server.AddUnmask(id, resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := r.server.Finish()
+	if err == nil || !strings.Contains(err.Error(), "not its own") {
+		t.Fatalf("Finish with a forged share: got %v, want a share ownership error", err)
+	}
+	if r.server.Phase() != PhaseFailed {
+		t.Fatalf("phase = %s, want %s", r.server.Phase(), PhaseFailed)
+	}
+}
+
+func TestServerFailsBelowThreshold(t *testing.T) {
+	t.Run("keys", func(t *testing.T) {
+		s := NewServer(testLength, 3)
+		for i := range 2 {
+			c, err := NewClient(fmt.Sprintf("client-%02d", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.AddKeys(c.ID(), c.PublicKeys()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := s.CloseKeys(); !errors.Is(err, ErrTooFewParticipants) {
+			t.Fatalf("CloseKeys: got %v, want ErrTooFewParticipants", err)
+		}
+	})
+
+	t.Run("shares", func(t *testing.T) {
+		r := newTestRound(t, 5)
+		r.share(r.clients[:2])
+		if err := r.server.CloseShares(); !errors.Is(err, ErrTooFewParticipants) {
+			t.Fatalf("CloseShares: got %v, want ErrTooFewParticipants", err)
+		}
+		if r.server.Phase() != PhaseFailed {
+			t.Fatalf("phase = %s, want %s", r.server.Phase(), PhaseFailed)
+		}
+	})
+
+	t.Run("masked", func(t *testing.T) {
+		r := newTestRound(t, 5)
+		r.share(r.clients)
+		if err := r.server.CloseShares(); err != nil {
+			t.Fatal(err)
+		}
+		r.mask(r.clients[:2])
+		if _, err := r.server.CloseMasked(); !errors.Is(err, ErrTooFewParticipants) {
+			t.Fatalf("CloseMasked: got %v, want ErrTooFewParticipants", err)
+		}
+	})
+
+	t.Run("unmask", func(t *testing.T) {
+		r := newTestRound(t, 5)
+		r.share(r.clients)
+		if err := r.server.CloseShares(); err != nil {
+			t.Fatal(err)
+		}
+		r.mask(r.clients)
+		if _, err := r.server.CloseMasked(); err != nil {
+			t.Fatal(err)
+		}
+		for id, resp := range r.unmask(r.clients[:2]) {
+			if err := r.server.AddUnmask(id, resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := r.server.Finish(); !errors.Is(err, ErrTooFewParticipants) {
+			t.Fatalf("Finish: got %v, want ErrTooFewParticipants", err)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package secagg
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// MaxParticipants is the most participants a round can have: shares are evaluated at the
+// nonzero elements of GF(256)
+const MaxParticipants = 255
+
+// Share is one Shamir share of a secret: the byte-wise polynomials evaluated at X
+type Share struct {
+	X byte   `json:"x"`
+	Y []byte `json:"y"`
+}
+
+// GF(256) with the AES polynomial x^8 + x^4 + x^3 + x + 1, using 3 as generator
+var gfExp, gfLog [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+		// multiply by 3: x*2 ^ x
+		hi := x & 0x80
+		x2 := x << 1
+		if hi != 0 {
+			x2 ^= 0x1b
+		}
+		x = x2 ^ x
+	}
+	gfExp[255] = gfExp[0]
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+255-int(gfLog[b]))%255]
+}
+
+// Split shares secret among n participants so that any t of the shares recover it and fewer
+// reveal nothing. Share i (0-based) is evaluated at X = i+1.
+func Split(secret []byte, n, t int) ([]Share, error) {
+	if t < 1 || t > n || n > MaxParticipants {
+		return nil, fmt.Errorf("cannot split among %d with threshold %d", n, t)
+	}
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{X: byte(i + 1), Y: make([]byte, len(secret))}
+	}
+
+	coeffs := make([]byte, t)
+	for b, s := range secret {
+		coeffs[0] = s
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			// Horner's rule at X
+			var y byte
+			for k := t - 1; k >= 0; k-- {
+				y = gfMul(y, shares[i].X) ^ coeffs[k]
+			}
+			shares[i].Y[b] = y
+		}
+	}
+	return shares, nil
+}
+
+// Combine recovers a secret from shares by Lagrange interpolation at zero. It needs at least as
+// many distinct shares as the threshold the secret was split with; fewer give a wrong result.
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares")
+	}
+	size := len(shares[0].Y)
+	seen := map[byte]bool{}
+	for _, s := range shares {
+		if s.X == 0 || seen[s.X] || len(s.Y) != size {
+			return nil, errors.New("shares are inconsistent")
+		}
+		seen[s.X] = true
+	}
+
+	secret := make([]byte, size)
+	for i, si := range shares {
+		// Lagrange basis at 0: prod x_j / (x_j - x_i); subtraction is xor in GF(2^8)
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(sj.X, sj.X^si.X))
+			}
+		}
+		for b := range secret {
+			secret[b] ^= gfMul(si.Y[b], basis)
+		}
+	}
+	return secret, nil
+}
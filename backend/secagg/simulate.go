@@ -0,0 +1,110 @@
+package secagg
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// Simulation describes an in-process round with simulated clients
+type Simulation struct {
+	Clients int
+	Length  int
+	// DropBeforeMasking clients vanish after sharing keys, so their pairwise masks must be
+	// recovered from shares
+	DropBeforeMasking int
+	// DropBeforeUnmask clients upload their masked input but do not answer the unmask phase
+	DropBeforeUnmask int
+}
+
+// SimulationResult compares the securely aggregated sum with the sum computed in the clear
+type SimulationResult struct {
+	Survivors int
+	Threshold int
+	MaxError  float64
+}
+
+// Simulate runs a full round between a Server and in-process Clients holding random inputs
+// and checks that the server recovers exactly the survivors' sum
+func Simulate(sim Simulation) (*SimulationResult, error) {
+	if sim.Clients < 2 || sim.Length < 1 || sim.DropBeforeMasking+sim.DropBeforeUnmask >= sim.Clients {
+		return nil, fmt.Errorf("invalid simulation %+v", sim)
+	}
+	rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	server := NewServer(sim.Length, 2)
+
+	clients := make([]*Client, sim.Clients)
+	for i := range clients {
+		c, err := NewClient(fmt.Sprintf("client-%03d", i))
+		if err != nil {
+			return nil, err
+		}
+		clients[i] = c
+		if err := server.AddKeys(c.ID(), c.PublicKeys()); err != nil {
+			return nil, err
+		}
+	}
+	roster, err := server.CloseKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range clients {
+		cts, err := c.ShareKeys(*roster)
+		if err != nil {
+			return nil, err
+		}
+		if err := server.AddShares(c.ID(), cts); err != nil {
+			return nil, err
+		}
+	}
+	if err := server.CloseShares(); err != nil {
+		return nil, err
+	}
+
+	expected := make([]float64, sim.Length)
+	masking := clients[sim.DropBeforeMasking:]
+	for _, c := range masking {
+		input := make([]float32, sim.Length)
+		for i := range input {
+			input[i] = float32(rng.NormFloat64())
+			// the server sums what the encoding can represent, so compare against that
+			expected[i] += math.Round(float64(input[i])*(1<<FracBits)) / (1 << FracBits)
+		}
+		incoming, err := server.SharesFor(c.ID())
+		if err != nil {
+			return nil, err
+		}
+		masked, err := c.MaskInput(Encode(input), incoming)
+		if err != nil {
+			return nil, err
+		}
+		if err := server.AddMaskedInput(c.ID(), masked); err != nil {
+			return nil, err
+		}
+	}
+	survivors, err := server.CloseMasked()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range masking[sim.DropBeforeUnmask:] {
+		resp, err := c.Unmask(survivors)
+		if err != nil {
+			return nil, err
+		}
+		if err := server.AddUnmask(c.ID(), resp); err != nil {
+			return nil, err
+		}
+	}
+	sum, err := server.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &SimulationResult{Survivors: len(survivors), Threshold: roster.Threshold}
+	for i, v := range Decode(sum) {
+		res.MaxError = max(res.MaxError, math.Abs(v-expected[i]))
+	}
+	return res, nil
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+var (
+	// AccountDeletionGrace is how long a requested deletion waits before the purge starts,
+	// during which the user can cancel it. Configured with ACCOUNT_DELETION_GRACE.
+	AccountDeletionGrace = 7 * 24 * time.Hour
+
+	// ErrDeletionPending is returned when a deletion is already scheduled for the account
+	ErrDeletionPending = errors.New("account deletion already scheduled")
+
+	deletionMu sync.Mutex
+)
+
+// deletionSteps are run in order; each is idempotent so a resumed purge can repeat the last one
+var deletionSteps = []struct {
+	name string
+	run  func(userID string, counts map[string]int64) error
+}{
+	{"shares", purgeShares},
+	{"objects", purgeObjects},
+	{"records", purgeRecords},
+	{"user", purgeUser},
+}
+
+// InitAccountDeletion reads the grace period and starts the background purge worker
+func InitAccountDeletion() {
+	if env := os.Getenv("ACCOUNT_DELETION_GRACE"); env != "" {
+		if d, err := time.ParseDuration(env); err == nil && d >= 0 {
+			AccountDeletionGrace = d
+		} else {
+			log.Printf("Warning: invalid ACCOUNT_DELETION_GRACE %q, using %s", env, AccountDeletionGrace)
+		}
+	}
+
+	go func() {
+		for {
+			RunDueAccountDeletions()
+			time.Sleep(time.Minute)
+		}
+	}()
+}
+
+// ScheduleAccountDeletion queues a user's account for deletion after the grace period
+func ScheduleAccountDeletion(userID string) (*models.AccountDeletion, error) {
+	var existing int64
+	database.DB.Model(&models.AccountDeletion{}).
+		Where("user_id = ? AND status IN ?", userID, []string{models.DeletionStatusScheduled, models.DeletionStatusRunning}).
+		Count(&existing)
+	if existing > 0 {
+		return nil, ErrDeletionPending
+	}
+
+	now := time.Now()
+	deletion := models.AccountDeletion{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Status:       models.DeletionStatusScheduled,
+		RequestedAt:  now,
+		ScheduledFor: now.Add(AccountDeletionGrace),
+	}
+	if err := database.DB.Create(&deletion).Error; err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// RunDueAccountDeletions purges every account whose grace period has passed, and resumes
+// purges that were interrupted
+func RunDueAccountDeletions() {
+	deletionMu.Lock()
+	defer deletionMu.Unlock()
+
+	var due []models.AccountDeletion
+	database.DB.Where("(status = ? AND scheduled_for <= ?) OR status = ?",
+		models.DeletionStatusScheduled, time.Now(), models.DeletionStatusRunning).
+		Order("scheduled_for ASC").
+		Find(&due)
+
+	for i := range due {
+		if err := runAccountDeletion(&due[i]); err != nil {
+			log.Printf("Account deletion %s for %s failed at step after %q: %v", due[i].ID, due[i].UserID, due[i].Step, err)
+		}
+	}
+}
+
+func runAccountDeletion(d *models.AccountDeletion) error {
+	counts := map[string]int64{}
+	if d.Counts != "" {
+		json.Unmarshal([]byte(d.Counts), &counts)
+	}
+
+	if d.Status != models.DeletionStatusRunning {
+		now := time.Now()
+		d.Status = models.DeletionStatusRunning
+		d.StartedAt = &now
+		database.DB.Model(d).Updates(map[string]interface{}{"status": d.Status, "started_at": now, "error": ""})
+	}
+
+	// Skip the steps a previous run already finished
+	start := 0
+	for i, step := range deletionSteps {
+		if step.name == d.Step {
+			start = i + 1
+		}
+	}
+
+	for _, step := range deletionSteps[start:] {
+		if err := step.run(d.UserID, counts); err != nil {
+			raw, _ := json.Marshal(counts)
+			database.DB.Model(d).Updates(map[string]interface{}{"counts": string(raw), "error": err.Error()})
+			return err
+		}
+		d.Step = step.name
+		raw, _ := json.Marshal(counts)
+		d.Counts = string(raw)
+		database.DB.Model(d).Updates(map[string]interface{}{"step": d.Step, "counts": d.Counts})
+	}
+
+	// Receipt: what was removed and when, signed so the user can prove the deletion happened
+	now := time.Now().UTC()
+	receipt := map[string]interface{}{
+		"deletion_id":  d.ID,
+		"username":     d.UserID,
+		"requested_at": d.RequestedAt.UTC(),
+		"completed_at": now,
+		"removed":      counts,
+	}
+	raw, _ := json.Marshal(receipt)
+	d.Status = models.DeletionStatusCompleted
+	d.CompletedAt = &now
+	d.Receipt = string(raw)
+	d.ReceiptSignature = SignWithServerKey(raw)
+	RecordAudit(models.AuditEvent{
+		Event:        AuditAccountDeleted,
+		ActorID:      AuditActorSystem,
+		TargetUserID: d.UserID,
+		TargetType:   "user",
+		TargetID:     d.UserID,
+	}, map[string]interface{}{"deletion_id": d.ID, "removed": counts})
+	return database.DB.Model(d).Updates(map[string]interface{}{
+		"status":            d.Status,
+		"completed_at":      now,
+		"receipt":           d.Receipt,
+		"receipt_signature": d.ReceiptSignature,
+		"error":             "",
+	}).Error
+}
+
+// purgeShares removes shares and bundles in both directions, with their ciphertexts
+func purgeShares(userID string, counts map[string]int64) error {
+	var shares []models.Share
+	if err := database.DB.Where("sender_id = ? OR receiver_id = ?", userID, userID).Find(&shares).Error; err != nil {
+		return err
+	}
+	for _, s := range shares {
+		key := "shares/" + s.ID + ".enc"
+		if err := DeleteObject(key); err != nil {
+			return err
+		}
+		ForgetObjects(key)
+		if err := database.DB.Delete(&models.Share{}, "id = ?", s.ID).Error; err != nil {
+			return err
+		}
+		counts["shares"]++
+	}
+
+	var bundles []models.ShareBundle
+	if err := database.DB.Where("sender_id = ? OR receiver_id = ?", userID, userID).Find(&bundles).Error; err != nil {
+		return err
+	}
+	for _, b := range bundles {
+		var itemIDs []string
+		database.DB.Model(&models.ShareBundleItem{}).Where("bundle_id = ?", b.ID).Pluck("id", &itemIDs)
+		for _, id := range itemIDs {
+			key := "shares/bundles/" + b.ID + "/" + id + ".enc"
+			if err := DeleteObject(key); err != nil {
+				return err
+			}
+			ForgetObjects(key)
+		}
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("bundle_id = ?", b.ID).Delete(&models.ShareBundleItem{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.ShareBundle{}, "id = ?", b.ID).Error
+		})
+		if err != nil {
+			return err
+		}
+		counts["share_bundles"]++
+	}
+	return nil
+}
+
+// purgeObjects removes every object under the user's prefix: originals, thumbnails and metadata blobs
+func purgeObjects(userID string, counts map[string]int64) error {
+	objects, err := ListObjectInfos(userID + "/")
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := DeleteObject(obj.Key); err != nil {
+			return err
+		}
+		if strings.Contains(obj.Key, "/metadata/") {
+			counts["metadata_blobs"]++
+		}
+		counts["objects"]++
+		counts["bytes"] += obj.Size
+	}
+	return nil
+}
+
+// purgeRecords removes every row that belongs to the user, and any export archives and FL update
+// files left on disk. Evaluation jobs are not touched: they evaluate aggregated model versions
+// and hold nothing about the users whose updates went into them.
+func purgeRecords(userID string, counts map[string]int64) error {
+	var files []string
+	var jobs []models.ArchiveJob
+	database.DB.Where("user_id = ?", userID).Find(&jobs)
+	for _, job := range jobs {
+		files = append(files, job.FilePath)
+	}
+	var updates []models.FLUpdate
+	database.DB.Where("user_id = ?", userID).Find(&updates)
+	for _, update := range updates {
+		files = append(files, update.FilePath)
+	}
+	for _, path := range files {
+		if path != "" {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	// Queued and sent emails are addressed by email, which may have changed over the account's life.
+	// The user row also names the invite they redeemed; a single-use one describes only them.
+	var user models.User
+	database.DB.Unscoped().Where("username = ?", userID).First(&user)
+	var emails []string
+	database.DB.Model(&models.EmailVerification{}).Where("user_id = ?", userID).Distinct().Pluck("email", &emails)
+	if user.Email != "" {
+		emails = append(emails, user.Email)
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// Updates still waiting in a round no longer count towards it
+		for _, update := range updates {
+			if update.Status != models.FLUpdateAccepted {
+				continue
+			}
+			if err := tx.Model(&models.FLRound{}).
+				Where("id = ? AND status IN ? AND updates > 0", update.RoundID, []string{models.FLRoundOpen, models.FLRoundCollecting}).
+				Update("updates", gorm.Expr("updates - 1")).Error; err != nil {
+				return err
+			}
+		}
+
+		deletes := []struct {
+			name  string
+			model interface{}
+			where string
+			args  []interface{}
+		}{
+			{"images", &models.Image{}, "user_id = ?", []interface{}{userID}},
+			{"contacts", &models.Contact{}, "requester_id = ? OR addressee_id = ?", []interface{}{userID, userID}},
+			{"blocks", &models.BlockedUser{}, "user_id = ? OR blocked_id = ?", []interface{}{userID, userID}},
+			{"pins", &models.PinnedKey{}, "user_id = ?", []interface{}{userID}},
+			{"sessions", &models.Session{}, "user_id = ?", []interface{}{userID}},
+			{"devices", &models.Device{}, "user_id = ?", []interface{}{userID}},
+			{"keys", &models.UserKey{}, "user_id = ?", []interface{}{userID}},
+			{"archive_jobs", &models.ArchiveJob{}, "user_id = ?", []interface{}{userID}},
+			{"ledger_entries", &models.StorageObject{}, "user_id = ?", []interface{}{userID}},
+			{"fl_updates", &models.FLUpdate{}, "user_id = ?", []interface{}{userID}},
+			{"invites", &models.Invite{}, "created_by = ? OR (code = ? AND max_uses = 1)", []interface{}{userID, user.InviteCode}},
+			{"email_verifications", &models.EmailVerification{}, "user_id = ?", []interface{}{userID}},
+			{"emails", &models.OutboxMessage{}, "\"to\" IN ?", []interface{}{emails}},
+		}
+		for _, d := range deletes {
+			result := tx.Where(d.where, d.args...).Delete(d.model)
+			if result.Error != nil {
+				return result.Error
+			}
+			counts[d.name] += result.RowsAffected
+		}
+		return nil
+	})
+}
+
+// purgeUser records the deletion in the key log and removes the user row itself
+func purgeUser(userID string, counts map[string]int64) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Unscoped().Where("username = ?", userID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		var last models.KeyLogEntry
+		tx.Where("user_id = ?", userID).Order("seq DESC").First(&last)
+		if last.Action != models.KeyLogActionDeleted {
+			if _, err := AppendKeyLog(tx, userID, last.KeyVersion, "", models.KeyLogActionDeleted); err != nil {
+				return err
+			}
+		}
+
+		// Hard delete, so the username and email are free again
+		if err := tx.Unscoped().Delete(&user).Error; err != nil {
+			return err
+		}
+		counts["user"] = 1
+		return nil
+	})
+}
@@ -0,0 +1,730 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+// ExportsDir is where export archives and uploaded import archives are kept. Configured with EXPORTS_DIR.
+var ExportsDir = "./exports"
+
+// Archive format written into manifest.json
+const (
+	ArchiveFormat        = "chithram-account-archive"
+	ArchiveFormatVersion = 1
+)
+
+var (
+	// ErrAccountNotFresh is returned when importing into an account that already has content
+	ErrAccountNotFresh = errors.New("imports are only allowed into an account with no images or shares")
+	// ErrArchiveInvalid is returned for archives that are not a readable account archive
+	ErrArchiveInvalid = errors.New("not a valid account archive")
+)
+
+// ArchiveManifest describes the contents of an account archive. Objects are listed with
+// their sha256 so an import can verify every ciphertext it restores.
+type ArchiveManifest struct {
+	Format       string          `json:"format"`
+	Version      int             `json:"version"`
+	Username     string          `json:"username"`
+	CreatedAt    time.Time       `json:"created_at"`
+	Images       int             `json:"images"`
+	Albums       int             `json:"albums"`
+	Shares       int             `json:"shares"`
+	ShareBundles int             `json:"share_bundles"`
+	Objects      []ArchiveObject `json:"objects"`
+}
+
+// ArchiveObject is one encrypted object stored in the archive
+type ArchiveObject struct {
+	Path   string `json:"path"` // path inside the archive, under objects/
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ArchiveAccount is the account's key material. Everything secret is still wrapped by the
+// password-derived KEK or the master key, exactly as the server stores it.
+type ArchiveAccount struct {
+	Username            string `json:"username"`
+	KEKSalt             string `json:"kek_salt"`
+	EncryptedMasterKey  string `json:"encrypted_master_key"`
+	MasterKeyNonce      string `json:"master_key_nonce"`
+	PublicKey           string `json:"public_key"`
+	EncryptedPrivateKey string `json:"encrypted_private_key"`
+	PrivateKeyNonce     string `json:"private_key_nonce"`
+	PeopleVersion       int    `json:"people_version"`
+	SemanticVersion     int    `json:"semantic_version"`
+	Discoverability     string `json:"discoverability"`
+}
+
+// ArchiveAlbum is an album name with the number of images in it
+type ArchiveAlbum struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ArchiveShare is a share record including the wrapped key material the API hides
+type ArchiveShare struct {
+	models.Share
+	EncryptedShareKey string `json:"encrypted_share_key"`
+	SenderPublicKey   string `json:"sender_public_key"`
+}
+
+// ArchiveShareBundle is a share bundle record with its items
+type ArchiveShareBundle struct {
+	models.ShareBundle
+	Items []models.ShareBundleItem `json:"items"`
+}
+
+// ArchiveKeyOverride replaces the archive's wrapped master key on import, for clients that
+// re-wrapped it under the importing account's password
+type ArchiveKeyOverride struct {
+	KEKSalt            string
+	EncryptedMasterKey string
+	MasterKeyNonce     string
+}
+
+// InitArchives prepares the exports directory and fails jobs interrupted by a restart
+func InitArchives() {
+	if env := os.Getenv("EXPORTS_DIR"); env != "" {
+		ExportsDir = env
+	}
+	if err := os.MkdirAll(ExportsDir, 0700); err != nil {
+		log.Printf("Warning: could not create exports directory %s: %v", ExportsDir, err)
+	}
+
+	now := time.Now()
+	database.DB.Model(&models.ArchiveJob{}).
+		Where("status IN ?", []string{models.ArchiveStatusPending, models.ArchiveStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":       models.ArchiveStatusFailed,
+			"error":        "interrupted by server restart",
+			"completed_at": now,
+		})
+}
+
+// archiveObjectPath maps a bucket key to its path inside an archive of userID's account
+func archiveObjectPath(userID, key string) string {
+	if strings.HasPrefix(key, "shares/") {
+		return "objects/" + key
+	}
+	return "objects/user/" + strings.TrimPrefix(key, userID+"/")
+}
+
+// bucketObjectKey maps an archive object path back to a bucket key for userID
+func bucketObjectKey(userID, path string) (string, bool) {
+	if rest, ok := strings.CutPrefix(path, "objects/user/"); ok && rest != "" && !strings.Contains(rest, "..") {
+		return userID + "/" + rest, true
+	}
+	if rest, ok := strings.CutPrefix(path, "objects/shares/"); ok && rest != "" && !strings.Contains(rest, "..") {
+		return "shares/" + rest, true
+	}
+	return "", false
+}
+
+func newArchiveJob(userID, kind string) (*models.ArchiveJob, error) {
+	job := models.ArchiveJob{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Kind:      kind,
+		Status:    models.ArchiveStatusPending,
+		CreatedAt: time.Now(),
+	}
+	if err := database.DB.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func saveArchiveProgress(job *models.ArchiveJob) {
+	database.DB.Model(job).Updates(map[string]interface{}{
+		"status":            job.Status,
+		"total_objects":     job.TotalObjects,
+		"processed_objects": job.ProcessedObjects,
+		"total_bytes":       job.TotalBytes,
+		"processed_bytes":   job.ProcessedBytes,
+		"file_path":         job.FilePath,
+	})
+}
+
+func finishArchiveJob(job *models.ArchiveJob, summary map[string]int, err error) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":            models.ArchiveStatusCompleted,
+		"processed_objects": job.ProcessedObjects,
+		"processed_bytes":   job.ProcessedBytes,
+		"file_path":         job.FilePath,
+		"completed_at":      now,
+	}
+	if summary != nil {
+		raw, _ := json.Marshal(summary)
+		updates["summary"] = string(raw)
+	}
+	if err != nil {
+		log.Printf("Archive %s %s for %s failed: %v", job.Kind, job.ID, job.UserID, err)
+		updates["status"] = models.ArchiveStatusFailed
+		updates["error"] = err.Error()
+	}
+	database.DB.Model(job).Updates(updates)
+}
+
+// StartExport creates an export job for a user and builds the archive in the background
+func StartExport(userID string) (*models.ArchiveJob, error) {
+	job, err := newArchiveJob(userID, models.ArchiveKindExport)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		summary, err := writeExport(job)
+		finishArchiveJob(job, summary, err)
+	}()
+	return job, nil
+}
+
+func writeExport(job *models.ArchiveJob) (map[string]int, error) {
+	userID := job.UserID
+
+	var user models.User
+	if err := database.DB.Where("username = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	var images []models.Image
+	database.DB.Where("user_id = ? AND is_deleted = ?", userID, false).Order("created_at ASC").Find(&images)
+
+	albums := []ArchiveAlbum{}
+	database.DB.Model(&models.Image{}).
+		Select("album AS name, COUNT(*) AS count").
+		Where("user_id = ? AND is_deleted = ? AND album <> ''", userID, false).
+		Group("album").
+		Scan(&albums)
+
+	var shareRows []models.Share
+	database.DB.Where("sender_id = ? OR receiver_id = ?", userID, userID).Order("created_at ASC").Find(&shareRows)
+	shares := make([]ArchiveShare, 0, len(shareRows))
+	for _, s := range shareRows {
+		shares = append(shares, ArchiveShare{Share: s, EncryptedShareKey: s.EncryptedShareKey, SenderPublicKey: s.SenderPublicKey})
+	}
+
+	var bundleRows []models.ShareBundle
+	database.DB.Where("sender_id = ? OR receiver_id = ?", userID, userID).Order("created_at ASC").Find(&bundleRows)
+	bundles := make([]ArchiveShareBundle, 0, len(bundleRows))
+	for _, b := range bundleRows {
+		var items []models.ShareBundleItem
+		database.DB.Where("bundle_id = ?", b.ID).Order("position ASC").Find(&items)
+		bundles = append(bundles, ArchiveShareBundle{ShareBundle: b, Items: items})
+	}
+
+	// Every object under the user's prefix, plus the ciphertexts of shares they sent
+	objects, err := ListObjectInfos(userID + "/")
+	if err != nil {
+		return nil, err
+	}
+	type exportObject struct {
+		key  string
+		size int64
+	}
+	var toExport []exportObject
+	for _, obj := range objects {
+		toExport = append(toExport, exportObject{obj.Key, obj.Size})
+	}
+	var sentKeys []string
+	for _, s := range shares {
+		if s.SenderID == userID && s.Status != models.ShareStatusDeclined {
+			sentKeys = append(sentKeys, "shares/"+s.ID+".enc")
+		}
+	}
+	for _, b := range bundles {
+		if b.SenderID != userID || b.Status == models.ShareStatusDeclined {
+			continue
+		}
+		for _, item := range b.Items {
+			sentKeys = append(sentKeys, "shares/bundles/"+b.ID+"/"+item.ID+".enc")
+		}
+	}
+	for _, key := range sentKeys {
+		if size, err := StatObjectSize(key); err == nil {
+			toExport = append(toExport, exportObject{key, size})
+		}
+	}
+
+	job.Status = models.ArchiveStatusRunning
+	job.TotalObjects = len(toExport)
+	for _, obj := range toExport {
+		job.TotalBytes += obj.size
+	}
+	saveArchiveProgress(job)
+
+	// Stream everything into a zip next to its final name, then move it into place
+	finalPath := filepath.Join(ExportsDir, job.ID+".zip")
+	tmpPath := finalPath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+	zw := zip.NewWriter(f)
+
+	writeJSON := func(name string, v interface{}) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	account := ArchiveAccount{
+		Username:            user.Username,
+		KEKSalt:             user.KEKSalt,
+		EncryptedMasterKey:  user.EncryptedMasterKey,
+		MasterKeyNonce:      user.MasterKeyNonce,
+		PublicKey:           user.PublicKey,
+		EncryptedPrivateKey: user.EncryptedPrivateKey,
+		PrivateKeyNonce:     user.PrivateKeyNonce,
+		PeopleVersion:       user.PeopleVersion,
+		SemanticVersion:     user.SemanticVersion,
+		Discoverability:     user.Discoverability,
+	}
+	for name, v := range map[string]interface{}{
+		"account.json":       account,
+		"images.json":        images,
+		"albums.json":        albums,
+		"shares.json":        shares,
+		"share_bundles.json": bundles,
+	} {
+		if err := writeJSON(name, v); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	manifest := ArchiveManifest{
+		Format:       ArchiveFormat,
+		Version:      ArchiveFormatVersion,
+		Username:     userID,
+		CreatedAt:    time.Now().UTC(),
+		Images:       len(images),
+		Albums:       len(albums),
+		Shares:       len(shares),
+		ShareBundles: len(bundles),
+		Objects:      []ArchiveObject{},
+	}
+
+	for _, obj := range toExport {
+		entry, err := exportObjectTo(zw, archiveObjectPath(userID, obj.key), obj.key)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("export %s: %w", obj.key, err)
+		}
+		manifest.Objects = append(manifest.Objects, *entry)
+		job.ProcessedObjects++
+		job.ProcessedBytes += entry.Size
+		saveArchiveProgress(job)
+	}
+
+	if err := writeJSON("manifest.json", manifest); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		return nil, err
+	}
+	job.FilePath = finalPath
+
+	return map[string]int{
+		"images":        len(images),
+		"albums":        len(albums),
+		"shares":        len(shares),
+		"share_bundles": len(bundles),
+		"objects":       len(manifest.Objects),
+	}, nil
+}
+
+// exportObjectTo copies one object from MinIO into the zip, stored without compression
+// since ciphertext doesn't compress
+func exportObjectTo(zw *zip.Writer, path, key string) (*ArchiveObject, error) {
+	obj, err := DownloadFromMinio(key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: path, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, hash), obj)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveObject{Path: path, Size: n, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// importPlan is what an import will restore, worked out before anything is written
+type importPlan struct {
+	manifest ArchiveManifest
+	account  ArchiveAccount
+	images   []models.Image
+	shares   []ArchiveShare
+	bundles  []ArchiveShareBundle
+	objects  map[string]string // archive path -> bucket key
+	skipped  int               // share records that can't be restored here
+}
+
+func readArchiveJSON(zr *zip.ReadCloser, name string, v interface{}) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return fmt.Errorf("%w: missing %s", ErrArchiveInvalid, name)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrArchiveInvalid, name, err)
+	}
+	return nil
+}
+
+// planImport validates an archive against the importing account and this server
+func planImport(zr *zip.ReadCloser, userID string) (*importPlan, error) {
+	plan := importPlan{objects: map[string]string{}}
+	if err := readArchiveJSON(zr, "manifest.json", &plan.manifest); err != nil {
+		return nil, err
+	}
+	if plan.manifest.Format != ArchiveFormat || plan.manifest.Version > ArchiveFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported format %q version %d", ErrArchiveInvalid, plan.manifest.Format, plan.manifest.Version)
+	}
+	for name, v := range map[string]interface{}{
+		"account.json":       &plan.account,
+		"images.json":        &plan.images,
+		"shares.json":        &plan.shares,
+		"share_bundles.json": &plan.bundles,
+	} {
+		if err := readArchiveJSON(zr, name, v); err != nil {
+			return nil, err
+		}
+	}
+	if plan.account.PublicKey == "" || plan.account.EncryptedMasterKey == "" {
+		return nil, fmt.Errorf("%w: account key material missing", ErrArchiveInvalid)
+	}
+
+	// Only into a fresh account, and only if the archived IDs are free on this server
+	var existing int64
+	database.DB.Model(&models.Image{}).Where("user_id = ?", userID).Count(&existing)
+	if existing > 0 {
+		return nil, ErrAccountNotFresh
+	}
+	database.DB.Model(&models.Share{}).Where("sender_id = ?", userID).Count(&existing)
+	if existing > 0 {
+		return nil, ErrAccountNotFresh
+	}
+	database.DB.Model(&models.ShareBundle{}).Where("sender_id = ?", userID).Count(&existing)
+	if existing > 0 {
+		return nil, ErrAccountNotFresh
+	}
+
+	if len(plan.images) > 0 {
+		ids := make([]string, 0, len(plan.images))
+		for _, img := range plan.images {
+			ids = append(ids, img.ImageID)
+		}
+		database.DB.Model(&models.Image{}).Where("image_id IN ?", ids).Count(&existing)
+		if existing > 0 {
+			return nil, fmt.Errorf("%d archived images already exist on this server", existing)
+		}
+	}
+
+	// Sent shares come back when the receiver has an account here and hasn't blocked the importer;
+	// received shares are kept in the archive for reference only since their ciphertexts belong to
+	// the sender. Archives aren't signed, so restored shares land in the receiver's inbox as pending
+	// and are tied to the receiver's current key rather than whatever the archive claims.
+	origin := plan.manifest.Username
+	var shares []ArchiveShare
+	for _, s := range plan.shares {
+		if s.SenderID != origin || s.Status == models.ShareStatusDeclined || !userExists(s.ReceiverID) ||
+			isBlockedBy(s.ReceiverID, userID) || recordExists(&models.Share{}, s.ID) {
+			plan.skipped++
+			continue
+		}
+		key, err := CurrentUserKey(s.ReceiverID)
+		if err != nil {
+			plan.skipped++
+			continue
+		}
+		s.Status = models.ShareStatusPending
+		s.RespondedAt = nil
+		s.ReceiverKeyVersion = key.Version
+		s.ReceiverKeyFingerprint = key.Fingerprint
+		shares = append(shares, s)
+	}
+	plan.shares = shares
+
+	var bundles []ArchiveShareBundle
+	for _, b := range plan.bundles {
+		if b.SenderID != origin || b.Status == models.ShareStatusDeclined || !userExists(b.ReceiverID) ||
+			isBlockedBy(b.ReceiverID, userID) || recordExists(&models.ShareBundle{}, b.ID) {
+			plan.skipped++
+			continue
+		}
+		key, err := CurrentUserKey(b.ReceiverID)
+		if err != nil {
+			plan.skipped++
+			continue
+		}
+		b.Status = models.ShareStatusPending
+		b.RespondedAt = nil
+		b.ReceiverKeyFingerprint = key.Fingerprint
+		for i := range b.Items {
+			b.Items[i].ReceiverKeyVersion = key.Version
+		}
+		bundles = append(bundles, b)
+	}
+	plan.bundles = bundles
+
+	restoredShares := map[string]bool{}
+	for _, s := range plan.shares {
+		restoredShares["shares/"+s.ID+".enc"] = true
+	}
+	for _, b := range plan.bundles {
+		for _, item := range b.Items {
+			restoredShares["shares/bundles/"+b.ID+"/"+item.ID+".enc"] = true
+		}
+	}
+
+	var total int64
+	for _, obj := range plan.manifest.Objects {
+		key, ok := bucketObjectKey(userID, obj.Path)
+		if !ok {
+			return nil, fmt.Errorf("%w: unexpected object path %s", ErrArchiveInvalid, obj.Path)
+		}
+		if strings.HasPrefix(key, "shares/") && !restoredShares[key] {
+			continue
+		}
+		plan.objects[obj.Path] = key
+		total += obj.Size
+	}
+	if err := CheckQuota(userID, total); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func userExists(username string) bool {
+	var count int64
+	database.DB.Model(&models.User{}).Where("username = ?", username).Count(&count)
+	return count > 0
+}
+
+// isBlockedBy reports whether receiverID has blocked senderID
+func isBlockedBy(receiverID, senderID string) bool {
+	var count int64
+	database.DB.Model(&models.BlockedUser{}).Where("user_id = ? AND blocked_id = ?", receiverID, senderID).Count(&count)
+	return count > 0
+}
+
+func recordExists(model interface{}, id string) bool {
+	var count int64
+	database.DB.Model(model).Where("id = ?", id).Count(&count)
+	return count > 0
+}
+
+// StartImport validates an uploaded archive for a fresh account and restores it in the background.
+// The archive file is removed once the job finishes.
+func StartImport(userID, archivePath string, override *ArchiveKeyOverride) (*models.ArchiveJob, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		os.Remove(archivePath)
+		return nil, ErrArchiveInvalid
+	}
+	plan, err := planImport(zr, userID)
+	if err != nil {
+		zr.Close()
+		os.Remove(archivePath)
+		return nil, err
+	}
+
+	job, err := newArchiveJob(userID, models.ArchiveKindImport)
+	if err != nil {
+		zr.Close()
+		os.Remove(archivePath)
+		return nil, err
+	}
+	go func() {
+		defer os.Remove(archivePath)
+		defer zr.Close()
+		summary, err := runImport(job, zr, plan, override)
+		finishArchiveJob(job, summary, err)
+	}()
+	return job, nil
+}
+
+func runImport(job *models.ArchiveJob, zr *zip.ReadCloser, plan *importPlan, override *ArchiveKeyOverride) (map[string]int, error) {
+	userID := job.UserID
+
+	expected := map[string]ArchiveObject{}
+	for _, obj := range plan.manifest.Objects {
+		expected[obj.Path] = obj
+	}
+
+	job.Status = models.ArchiveStatusRunning
+	job.TotalObjects = len(plan.objects)
+	for path := range plan.objects {
+		job.TotalBytes += expected[path].Size
+	}
+	saveArchiveProgress(job)
+
+	// Restore ciphertexts first, verifying each against the manifest
+	var uploaded []string
+	cleanup := func() {
+		for _, key := range uploaded {
+			DeleteObject(key)
+		}
+		ForgetObjects(uploaded...)
+	}
+	for _, f := range zr.File {
+		key, ok := plan.objects[f.Name]
+		if !ok {
+			continue
+		}
+		want := expected[f.Name]
+		if err := importObject(f, key, want); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("import %s: %w", f.Name, err)
+		}
+		uploaded = append(uploaded, key)
+		RecordObjectSize(userID, key, want.Size)
+		job.ProcessedObjects++
+		job.ProcessedBytes += want.Size
+		saveArchiveProgress(job)
+	}
+	if job.ProcessedObjects != job.TotalObjects {
+		cleanup()
+		return nil, fmt.Errorf("%w: %d objects listed in the manifest are missing", ErrArchiveInvalid, job.TotalObjects-job.ProcessedObjects)
+	}
+
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where("username = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+
+		account := plan.account
+		if override != nil {
+			account.KEKSalt = override.KEKSalt
+			account.EncryptedMasterKey = override.EncryptedMasterKey
+			account.MasterKeyNonce = override.MasterKeyNonce
+		}
+		keyChanged := user.PublicKey != account.PublicKey
+		user.KEKSalt = account.KEKSalt
+		user.EncryptedMasterKey = account.EncryptedMasterKey
+		user.MasterKeyNonce = account.MasterKeyNonce
+		user.PublicKey = account.PublicKey
+		user.EncryptedPrivateKey = account.EncryptedPrivateKey
+		user.PrivateKeyNonce = account.PrivateKeyNonce
+		user.PeopleVersion = account.PeopleVersion
+		user.SemanticVersion = account.SemanticVersion
+		if account.Discoverability != "" {
+			user.Discoverability = account.Discoverability
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if keyChanged {
+			if _, err := AddUserKey(tx, &user, models.KeyLogActionRotated); err != nil {
+				return err
+			}
+		}
+
+		for i := range plan.images {
+			img := plan.images[i]
+			img.UserID = userID
+			img.ModifiedAt = now
+			img.ObjectsMissing = false
+			if err := tx.Create(&img).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, s := range plan.shares {
+			share := s.Share
+			share.SenderID = userID
+			share.EncryptedShareKey = s.EncryptedShareKey
+			share.SenderPublicKey = s.SenderPublicKey
+			if err := tx.Create(&share).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, b := range plan.bundles {
+			bundle := b.ShareBundle
+			bundle.SenderID = userID
+			if err := tx.Create(&bundle).Error; err != nil {
+				return err
+			}
+			for i := range b.Items {
+				item := b.Items[i]
+				item.BundleID = bundle.ID
+				if err := tx.Create(&item).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	return map[string]int{
+		"images":        len(plan.images),
+		"shares":        len(plan.shares),
+		"share_bundles": len(plan.bundles),
+		"skipped":       plan.skipped,
+		"objects":       job.ProcessedObjects,
+	}, nil
+}
+
+// importObject uploads one archived object to MinIO and checks its hash against the manifest
+func importObject(f *zip.File, key string, want ArchiveObject) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	hash := sha256.New()
+	if _, err := UploadToMinio(key, io.TeeReader(rc, hash), int64(f.UncompressedSize64), "application/octet-stream"); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want.SHA256 || int64(f.UncompressedSize64) != want.Size {
+		DeleteObject(key)
+		return errors.New("checksum mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+// Audit event types
+const (
+	AuditLoginSuccess             = "login_success"
+	AuditLoginFailure             = "login_failure"
+	AuditLogout                   = "logout"
+	AuditPasswordChanged          = "password_changed"
+	AuditDeviceRemoved            = "device_removed"
+	AuditImagesDeleted            = "images_deleted"
+	AuditAccountDeletionRequested = "account_deletion_requested"
+	AuditAccountDeletionCancelled = "account_deletion_cancelled"
+	AuditAccountDeleted           = "account_deleted"
+	AuditShareCreated             = "share_created"
+	AuditShareViewed              = "share_viewed"
+	AuditShareRevoked             = "share_revoked"
+	AuditShareBundleCreated       = "share_bundle_created"
+	AuditShareBundleViewed        = "share_bundle_viewed"
+	AuditShareBundleRevoked       = "share_bundle_revoked"
+	AuditModelPromoted            = "model_promoted"
+	AuditAdminPrefix              = "admin."
+	AuditActorSystem              = "system"
+	auditMaxPage                  = 200
+)
+
+// RecordAudit appends an event to the audit log. Failures are logged, never returned, so
+// auditing can't break the action being audited.
+func RecordAudit(event models.AuditEvent, details interface{}) {
+	if details != nil {
+		raw, _ := json.Marshal(details)
+		event.Details = string(raw)
+	}
+	event.ID = 0
+	event.CreatedAt = time.Now()
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Printf("Failed to record audit event %s: %v", event.Event, err)
+	}
+}
+
+// BackfillAdminActions moves the rows of the admin_actions table, which the audit log replaced,
+// into the audit log as "admin.<action>" events and drops the table
+func BackfillAdminActions() {
+	if !database.DB.Migrator().HasTable("admin_actions") {
+		return
+	}
+	var moved int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(`INSERT INTO audit_events (event, actor_id, target_user_id, target_type, target_id, details, created_at)
+			SELECT ? || action, admin_id, target_user_id,
+				CASE WHEN target_user_id <> '' THEN 'user' ELSE '' END, target_user_id, details, created_at
+			FROM admin_actions ORDER BY id`, AuditAdminPrefix)
+		if result.Error != nil {
+			return result.Error
+		}
+		moved = result.RowsAffected
+		return tx.Migrator().DropTable("admin_actions")
+	})
+	if err != nil {
+		log.Printf("Failed to move admin actions into the audit log: %v", err)
+		return
+	}
+	log.Printf("Moved %d admin actions into the audit log", moved)
+}
+
+// AuditFilter selects audit events. User matches events the user performed or that targeted them.
+// Event is an exact event type, or a prefix when it ends in "*" (e.g. "admin.*").
+// Before is the ID cursor returned by the previous page.
+type AuditFilter struct {
+	User   string
+	Event  string
+	Since  *time.Time
+	Before uint
+	Limit  int
+}
+
+// QueryAudit returns matching events newest first, plus the cursor of the next page (0 when done)
+func QueryAudit(f AuditFilter) ([]models.AuditEvent, uint, error) {
+	if f.Limit <= 0 || f.Limit > auditMaxPage {
+		f.Limit = auditMaxPage
+	}
+
+	query := database.DB.Model(&models.AuditEvent{}).Order("id DESC")
+	if f.User != "" {
+		query = query.Where("actor_id = ? OR target_user_id = ?", f.User, f.User)
+	}
+	if f.Event != "" {
+		if prefix, ok := strings.CutSuffix(f.Event, "*"); ok {
+			query = query.Where("event LIKE ?", prefix+"%")
+		} else {
+			query = query.Where("event = ?", f.Event)
+		}
+	}
+	if f.Since != nil {
+		query = query.Where("created_at >= ?", *f.Since)
+	}
+	if f.Before > 0 {
+		query = query.Where("id < ?", f.Before)
+	}
+
+	var events []models.AuditEvent
+	if err := query.Limit(f.Limit + 1).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var next uint
+	if len(events) > f.Limit {
+		events = events[:f.Limit]
+		next = events[len(events)-1].ID
+	}
+	return events, next, nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+// Backup health of a device as shown on the settings page
+const (
+	BackupHealthOK      = "ok"      // backed up recently, nothing waiting
+	BackupHealthPending = "pending" // backed up recently, uploads still waiting
+	BackupHealthStale   = "stale"   // no successful backup within BackupStaleAfter
+	BackupHealthNever   = "never"   // never reported a successful backup
+)
+
+var (
+	// BackupStaleAfter is how long a device can go without a successful backup before it is
+	// reported stale. Configured with DEVICE_BACKUP_STALE_AFTER.
+	BackupStaleAfter = 7 * 24 * time.Hour
+
+	// DevicePlatforms are the accepted platform values
+	DevicePlatforms = []string{"android", "ios", "windows", "macos", "linux", "web"}
+
+	// ErrInvalidDevice is returned for a missing or malformed device ID or platform
+	ErrInvalidDevice = errors.New("invalid device")
+)
+
+const maxDeviceField = 128
+
+// DeviceInfo is what a client tells the server about itself when it registers
+type DeviceInfo struct {
+	DeviceID          string `json:"device_id"`
+	Name              string `json:"name"`
+	Platform          string `json:"platform"`
+	AppVersion        string `json:"app_version"`
+	SourceIDNamespace string `json:"source_id_namespace"`
+}
+
+// DeviceStatus is the backup progress a client reports
+type DeviceStatus struct {
+	LastSyncCursor *string    `json:"last_sync_cursor"`
+	LastBackupAt   *time.Time `json:"last_backup_at"`
+	PendingUploads *int       `json:"pending_uploads"`
+}
+
+// InitDevices reads the device configuration from the environment
+func InitDevices() {
+	if env := os.Getenv("DEVICE_BACKUP_STALE_AFTER"); env != "" {
+		if d, err := time.ParseDuration(env); err == nil && d > 0 {
+			BackupStaleAfter = d
+		} else {
+			log.Printf("Warning: invalid DEVICE_BACKUP_STALE_AFTER %q, using %s", env, BackupStaleAfter)
+		}
+	}
+}
+
+// normalizeDevice trims the client-supplied fields and checks them before they are stored
+func normalizeDevice(info DeviceInfo) (DeviceInfo, bool) {
+	info.DeviceID = strings.TrimSpace(info.DeviceID)
+	info.Platform = strings.ToLower(strings.TrimSpace(info.Platform))
+	if info.DeviceID == "" || len(info.DeviceID) > maxDeviceField {
+		return info, false
+	}
+	for _, f := range []string{info.Name, info.AppVersion, info.SourceIDNamespace} {
+		if len(f) > maxDeviceField {
+			return info, false
+		}
+	}
+	if info.Platform == "" {
+		return info, true
+	}
+	for _, p := range DevicePlatforms {
+		if info.Platform == p {
+			return info, true
+		}
+	}
+	return info, false
+}
+
+// ValidDeviceInfo reports whether RegisterDevice would accept info
+func ValidDeviceInfo(info DeviceInfo) bool {
+	_, ok := normalizeDevice(info)
+	return ok
+}
+
+// RegisterDevice creates or refreshes a user's device and links the session to it
+func RegisterDevice(userID string, info DeviceInfo, sessionID uint) (*models.Device, error) {
+	info, ok := normalizeDevice(info)
+	if !ok {
+		return nil, ErrInvalidDevice
+	}
+
+	now := time.Now()
+	var device models.Device
+	err := database.DB.Where("user_id = ? AND device_id = ?", userID, info.DeviceID).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		device = models.Device{UserID: userID, DeviceID: info.DeviceID, CreatedAt: now}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Empty fields keep what the device reported before
+	if info.Name != "" {
+		device.Name = info.Name
+	}
+	if info.Platform != "" {
+		device.Platform = info.Platform
+	}
+	if info.AppVersion != "" {
+		device.AppVersion = info.AppVersion
+	}
+	if info.SourceIDNamespace != "" {
+		device.SourceIDNamespace = info.SourceIDNamespace
+	}
+	device.LastSeenAt = now
+	if err := database.DB.Save(&device).Error; err != nil {
+		return nil, err
+	}
+
+	if sessionID != 0 {
+		database.DB.Model(&models.Session{}).Where("id = ?", sessionID).Update("device_id", device.DeviceID)
+	}
+	return &device, nil
+}
+
+// ReportDeviceStatus stores the backup progress a device reports; nil fields are left unchanged
+func ReportDeviceStatus(userID, deviceID string, status DeviceStatus) (*models.Device, error) {
+	var device models.Device
+	if err := database.DB.Where("user_id = ? AND device_id = ?", userID, deviceID).First(&device).Error; err != nil {
+		return nil, err
+	}
+	if status.PendingUploads != nil && *status.PendingUploads < 0 {
+		return nil, ErrInvalidDevice
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status_reported_at": now, "last_seen_at": now}
+	if status.LastSyncCursor != nil {
+		updates["last_sync_cursor"] = *status.LastSyncCursor
+	}
+	if status.LastBackupAt != nil {
+		// A device can't have backed up in the future; clamp clock skew
+		backupAt := *status.LastBackupAt
+		if backupAt.After(now) {
+			backupAt = now
+		}
+		updates["last_backup_at"] = backupAt
+	}
+	if status.PendingUploads != nil {
+		updates["pending_uploads"] = *status.PendingUploads
+	}
+	if err := database.DB.Model(&device).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// BackupHealth summarises how well a device is keeping up with backups
+func BackupHealth(d *models.Device) string {
+	switch {
+	case d.LastBackupAt == nil:
+		return BackupHealthNever
+	case time.Since(*d.LastBackupAt) > BackupStaleAfter:
+		return BackupHealthStale
+	case d.PendingUploads > 0:
+		return BackupHealthPending
+	default:
+		return BackupHealthOK
+	}
+}
+
+// RemoveDevice forgets a device and signs out its sessions
+func RemoveDevice(userID, deviceID string) (int64, error) {
+	result := database.DB.Where("user_id = ? AND device_id = ?", userID, deviceID).Delete(&models.Device{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	revoked := database.DB.Model(&models.Session{}).
+		Where("user_id = ? AND device_id = ? AND revoked_at IS NULL", userID, deviceID).
+		Update("revoked_at", time.Now())
+	return revoked.RowsAffected, nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"time"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+// EmailVerificationTTL is how long a verification link stays valid
+var EmailVerificationTTL = 48 * time.Hour
+
+// ErrInvalidVerification is returned for unknown, used or expired verification links
+var ErrInvalidVerification = errors.New("invalid or expired verification link")
+
+// SendVerificationEmail creates a verification link for the user's current email and queues it
+func SendVerificationEmail(user *models.User) error {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	verification := models.EmailVerification{
+		TokenHash: hashToken(token),
+		UserID:    user.Username,
+		Email:     user.Email,
+		ExpiresAt: now.Add(EmailVerificationTTL),
+		CreatedAt: now,
+	}
+	if err := database.DB.Create(&verification).Error; err != nil {
+		return err
+	}
+
+	return QueueMail(user.Email, MailVerifyEmail, map[string]interface{}{
+		"Username":  user.Username,
+		"Link":      PublicBaseURL + "/verify-email?token=" + url.QueryEscape(token),
+		"ExpiresIn": EmailVerificationTTL.String(),
+	})
+}
+
+// VerifyEmail consumes a verification token and marks the user's email verified, as long as
+// the email hasn't changed since the link was sent
+func VerifyEmail(token string) (*models.User, error) {
+	var verification models.EmailVerification
+	if err := database.DB.Where("token_hash = ?", hashToken(token)).First(&verification).Error; err != nil {
+		return nil, ErrInvalidVerification
+	}
+	now := time.Now()
+	if verification.UsedAt != nil || now.After(verification.ExpiresAt) {
+		return nil, ErrInvalidVerification
+	}
+
+	var user models.User
+	if err := database.DB.Where("username = ?", verification.UserID).First(&user).Error; err != nil || user.Email != verification.Email {
+		return nil, ErrInvalidVerification
+	}
+
+	database.DB.Model(&verification).Update("used_at", now)
+	if err := database.DB.Model(&user).Update("email_verified_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -1,10 +1,12 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"math/rand/v2"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -14,6 +16,7 @@ import (
 
 	"chithram/database"
 	"chithram/models"
+	"chithram/tensors"
 )
 
 var (
@@ -21,18 +24,38 @@ var (
 	PendingUpdatesDir = "./fl_updates/pending"
 	// AggregatedModelsDir is where we store the aggregated models
 	AggregatedModelsDir = "./fl_models"
-	// CurrentGlobalModelPath points to the latest aggregated model
-	CurrentGlobalModelPath string
 	// AggregationInterval is how often we check for updates
 	AggregationInterval = 1 * time.Minute
 	// Min updates required before aggregation
 	MinUpdatesRequired = 2
 
 	mu sync.Mutex
+
+	// lastRuns holds the outcome of each model's most recent aggregation, for the admin API
+	lastRuns   = map[string]AggregationRun{}
+	lastRunsMu sync.Mutex
 )
 
+// AggregationRun is the outcome of one aggregation of a model's round
+type AggregationRun struct {
+	At              time.Time `json:"at"`
+	RoundID         uint      `json:"round_id"`
+	AggregatedModel string    `json:"aggregated_model,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// recordRun remembers the outcome of an aggregation
+func recordRun(modelName string, run AggregationRun) {
+	run.At = time.Now()
+	lastRunsMu.Lock()
+	lastRuns[modelName] = run
+	lastRunsMu.Unlock()
+}
+
 // InitFLService ensures directories exist and starts the background worker
 func InitFLService() {
+	initFLRounds()
+
 	// Create directories
 	if _, err := os.Stat(PendingUpdatesDir); os.IsNotExist(err) {
 		os.MkdirAll(PendingUpdatesDir, 0755)
@@ -49,248 +72,352 @@ func InitFLService() {
 		}
 	}()
 
-	// Initial check for latest global model
-	files, _ := ioutil.ReadDir(AggregatedModelsDir)
-	var latestFile string
-	var latestTime time.Time
+	// Evaluate key models on startup in the background; the dashboard shows the job queue
+	initEvalJobs()
+	enqueueStartupEvaluations()
+}
 
-	for _, f := range files {
-		if !f.IsDir() && filepath.Ext(f.Name()) == ".onnx" {
-			if f.ModTime().After(latestTime) {
-				latestTime = f.ModTime()
-				latestFile = f.Name()
-			}
-		}
+// evaluateModel runs a model's evaluator on a model file and stores the result as the metrics of
+// version. The evaluator's output is returned too, since it explains failures.
+func evaluateModel(ctx context.Context, m *FLModel, modelPath, version string) (*models.ModelMetric, []byte, error) {
+	cmd := exec.CommandContext(ctx, pythonExecutable(), m.Evaluator, "--model", modelPath)
+	cmd.WaitDelay = 10 * time.Second // children of a killed evaluator may keep its output open
+	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, output, fmt.Errorf("%s timed out", m.Evaluator)
 	}
-
-	if latestFile != "" {
-		CurrentGlobalModelPath = filepath.Join(AggregatedModelsDir, latestFile)
-		log.Printf("Found existing global model: %s", latestFile)
-	} else {
-		// Default to the main model if no fl_models exist yet
-		CurrentGlobalModelPath = "./models/face-detection.onnx"
+	if err != nil {
+		return nil, output, fmt.Errorf("%s: %v", m.Evaluator, err)
+	}
+	res, err := parseEvalResult(output)
+	if err != nil {
+		return nil, output, err
 	}
 
-	// PROACTIVE: Evaluate key models on startup and print to console
-	go func() {
-		pythonExe := "python"
-		if _, err := os.Stat("../.venv/Scripts/python.exe"); err == nil {
-			pythonExe, _ = filepath.Abs("../.venv/Scripts/python.exe")
-		}
-
-		evalModel := func(modelPath string, label string) {
-			fileInfo, err := os.Stat(modelPath)
-			if err != nil {
-				log.Printf("SKIP: Model %s not found", modelPath)
-				return
-			}
-
-			// Check Database to skip redundant evaluations
-			var lastMetric models.ModelMetric
-			// Assuming database connection guarantees initialized DB object
-			if err := database.DB.Where("version = ?", label).Order("created_at desc").First(&lastMetric).Error; err == nil {
-				if lastMetric.CreatedAt.After(fileInfo.ModTime()) {
-					log.Printf("SKIP: Model %s (%s) already evaluated on %v. Returning cached metrics.", label, modelPath, lastMetric.CreatedAt.Format(time.RFC3339))
-					return
-				}
-			}
-			log.Printf("--- EVALUATING %s (%s) ---", label, modelPath)
-			evalCmd := exec.Command(pythonExe, "./scripts/evaluate_model.py", "--model", modelPath)
-			evalOutput, err := evalCmd.CombinedOutput()
-			if err != nil {
-				log.Printf("ERROR: Evaluation failed: %v\nOutput: %s", err, string(evalOutput))
-				return
-			}
-
-			fmt.Printf("\n>>> EVALUATION RESULT [%s] <<<\n%s\n", label, string(evalOutput))
-
-			type EvalResult struct {
-				Accuracy float64 `json:"accuracy"`
-				Loss     float64 `json:"loss"`
-			}
-			var res EvalResult
-			rawStr := string(evalOutput)
-			startIndex := strings.Index(rawStr, "{")
-			if startIndex != -1 {
-				jsonPart := rawStr[startIndex:]
-				if err := json.Unmarshal([]byte(jsonPart), &res); err == nil {
-					metric := models.ModelMetric{
-						ModelName: "face-detection",
-						Version:   label,
-						Accuracy:  res.Accuracy,
-						Loss:      res.Loss,
-						CreatedAt: time.Now(),
-					}
-					database.DB.Create(&metric)
-				}
-			}
-		}
-
-		// 1. Evaluate the Original Baseline
-		evalModel("./models/yolov8n-face.onnx", "original_baseline")
-
-		// 2. Evaluate the Current Live Model
-		evalModel("./models/face-detection.onnx", "current_live")
-
-		// 3. Backfill history if empty (keeping original logic for other models)
-		var count int64
-		database.DB.Model(&models.ModelMetric{}).Count(&count)
-		if count <= 2 { // Only original and current exist
-			log.Println("Dashboard history is empty. Attempting to backfill from fl_models...")
-			files, _ := ioutil.ReadDir(AggregatedModelsDir)
-			for _, f := range files {
-				if !f.IsDir() && filepath.Ext(f.Name()) == ".onnx" {
-					evalModel(filepath.Join(AggregatedModelsDir, f.Name()), f.Name())
-				}
-			}
-		}
-		log.Println("Startup Evaluation and Backfill complete.")
-	}()
+	metric := models.ModelMetric{
+		ModelName: m.Name,
+		Version:   version,
+		Accuracy:  res.Accuracy,
+		Loss:      res.Loss,
+		CreatedAt: time.Now(),
+	}
+	if err := database.DB.Create(&metric).Error; err != nil {
+		return nil, output, err
+	}
+	return &metric, output, nil
 }
 
-// AggregateModels checks the pending updates folder and runs the aggregation script if enough updates are present
+// AggregateModels advances the current round of every FL model
 func AggregateModels() {
 	mu.Lock()
 	defer mu.Unlock()
 
-	files, err := ioutil.ReadDir(PendingUpdatesDir)
+	for _, m := range FLModels() {
+		aggregateRound(m)
+	}
+}
+
+// aggregateRound advances a model's current round. Once the round reaches its target participant
+// count, or its deadline with enough updates, its updates are aggregated into a new registered
+// version, which is evaluated and promoted according to the model's policy.
+func aggregateRound(m *FLModel) {
+	round, err := CurrentRound(m)
+	if errors.Is(err, ErrPrivacyBudgetExhausted) {
+		return
+	}
 	if err != nil {
-		log.Printf("Error reading pending updates dir: %v", err)
+		log.Printf("Error loading current FL round of %s: %v", m.Name, err)
+		return
+	}
+	if round.SecureAggregation {
+		aggregateSecureRound(m, round)
+		return
+	}
+
+	var updates []models.FLUpdate
+	database.DB.Where("round_id = ? AND status = ?", round.ID, models.FLUpdateAccepted).Order("id ASC").Find(&updates)
+
+	ready, reject := roundReady(round, len(updates))
+	if reject != "" {
+		closeRound(round, models.FLRoundRejected, reject)
+		return
+	}
+	if !ready {
 		return
 	}
 
-	// Count only valid model files
 	var modelFiles []string
-	for _, f := range files {
-		if !f.IsDir() && filepath.Ext(f.Name()) == ".pth" {
-			modelFiles = append(modelFiles, filepath.Join(PendingUpdatesDir, f.Name()))
-		}
+	var weights []float64
+	for _, u := range updates {
+		modelFiles = append(modelFiles, u.FilePath)
+		weights = append(weights, u.Weight)
 	}
 
-	if len(modelFiles) < MinUpdatesRequired {
-		log.Printf("Not enough updates to aggregate. Pending: %d, Required: %d", len(modelFiles), MinUpdatesRequired)
+	aggregation := parseAggregation(round.StrategyParams)
+	privacy := parsePrivacy(round.Privacy)
+	if privacy != nil && checkPrivacyBudget(m.Name, privacy) != nil {
+		closeRound(round, models.FLRoundRejected, "privacy budget exhausted")
 		return
 	}
-
-	log.Printf("Aggregating %d models...", len(modelFiles))
+	setRoundStatus(round, models.FLRoundAggregating)
+	log.Printf("Aggregating %d %s models for FL round %d with %s...", len(modelFiles), m.Name, round.ID, aggregation)
 
 	// Define output path for the new global model
-	newGlobalModelName := fmt.Sprintf("global_model_%d.onnx", time.Now().Unix())
+	newGlobalModelName := fmt.Sprintf("%s_%d.onnx", m.Name, time.Now().Unix())
 	outputPath := filepath.Join(AggregatedModelsDir, newGlobalModelName)
 
-	// Call Python script to perform weighted averaging (FedAvg)
-	// We'll pass the list of models as arguments along with the output path
-	// Use the project's virtual environment if available, otherwise fallback to system python
-	pythonExe := "python"
-	if _, err := os.Stat("../.venv/Scripts/python.exe"); err == nil {
-		pythonExe, _ = filepath.Abs("../.venv/Scripts/python.exe")
-	} else if _, err := os.Stat("../.venv/bin/python"); err == nil {
-		pythonExe, _ = filepath.Abs("../.venv/bin/python")
+	// Merge the updates in-process and write them into a copy of the round's base model.
+	// Updates are weighted by the sample count their clients trained on.
+	selected, err := aggregateUpdates(aggregation, privacy, modelFiles, weights, roundBasePath(round), outputPath)
+	if err != nil {
+		log.Printf("Error aggregating FL round %d: %v", round.ID, err)
+		recordRun(m.Name, AggregationRun{RoundID: round.ID, Error: err.Error()})
+		closeRound(round, models.FLRoundRejected, "aggregation failed: "+err.Error())
+		return
 	}
 
-	cmd := exec.Command(pythonExe, "./scripts/aggregate_models.py", "--output", outputPath, "--base_model", "./models/face-detection.onnx")
-	cmd.Args = append(cmd.Args, modelFiles...)
+	if selected != nil {
+		var ids []uint
+		for _, i := range selected {
+			ids = append(ids, updates[i].ID)
+		}
+		log.Printf("%s kept updates %v of round %d", aggregation.Strategy, ids, round.ID)
+	}
+	publishAggregate(m, round, privacy, newGlobalModelName, outputPath)
+}
 
-	// Capture output for debugging
-	output, err := cmd.CombinedOutput()
+// publishAggregate registers the aggregate a round produced at outputPath as a candidate version,
+// then evaluates and promotes it according to the model's policy and closes the round
+func publishAggregate(m *FLModel, round *models.FLRound, privacy *PrivacyConfig, newGlobalModelName, outputPath string) {
+	log.Printf("Aggregation successful! New global model: %s", newGlobalModelName)
+	recordRun(m.Name, AggregationRun{RoundID: round.ID, AggregatedModel: newGlobalModelName})
+	round.ResultModel = newGlobalModelName
+
+	// The noisy aggregate now exists, so its privacy cost counts whatever happens to it
+	if privacy != nil {
+		round.PrivacyRho = privacy.RoundRho()
+		database.DB.Model(round).Update("privacy_rho", round.PrivacyRho)
+		epsilon := PrivacySpent(m.Name, privacy)
+		round.Epsilon = &epsilon
+		database.DB.Model(round).Update("epsilon", epsilon)
+		log.Printf("FL round %d spent privacy: %s is at ε=%.4f of %g (δ=%g)", round.ID, m.Name, epsilon, privacy.EpsilonBudget, privacy.Delta)
+	}
+
+	// Register the aggregate as a new version descending from the round's base
+	roundID := round.ID
+	candidate, err := RegisterModelVersion(models.ModelVersion{
+		ModelName:     m.Name,
+		ParentVersion: round.BaseVersion,
+		RoundID:       &roundID,
+		Source:        models.ModelSourceFLRound,
+		Epsilon:       round.Epsilon,
+	}, outputPath)
+	if err == nil {
+		err = SetCandidate(candidate)
+	}
 	if err != nil {
-		log.Printf("Error running aggregation script: %v\nOutput: %s", err, string(output))
+		log.Printf("Failed to register aggregated model: %v", err)
+		closeRound(round, models.FLRoundRejected, "failed to register aggregated model: "+err.Error())
 		return
 	}
+	round.ResultVersion = candidate.Version
 
-	log.Printf("Aggregation successful! New global model: %s", newGlobalModelName)
-	CurrentGlobalModelPath = outputPath
+	if m.Promotion == PromoteManual {
+		log.Printf("Published %s version %s on the candidate channel for manual promotion", m.Name, candidate.Version)
+		closeRound(round, models.FLRoundPublished, "")
+		return
+	}
+	// The evaluation workers read the candidate back from the round
+	round.Status = models.FLRoundEvaluating
+	database.DB.Model(round).Updates(map[string]interface{}{
+		"status":         round.Status,
+		"result_model":   round.ResultModel,
+		"result_version": round.ResultVersion,
+	})
 
 	// --- EVALUATION PASS ---
-	// Evaluate Old vs New Model Accuracy
-	oldModelPath := "./models/face-detection.onnx"
-
-	evalModel := func(modelPath string, versionName string) {
-		log.Printf("Evaluating model: %s", modelPath)
-		evalCmd := exec.Command(pythonExe, "./scripts/evaluate_model.py", "--model", modelPath)
-		evalOutput, evalErr := evalCmd.CombinedOutput()
-		if evalErr == nil {
-			type EvalResult struct {
-				Accuracy float64 `json:"accuracy"`
-				Loss     float64 `json:"loss"`
-			}
-			var res EvalResult
-			rawStr := string(evalOutput)
-			startIndex := strings.Index(rawStr, "{")
-			if startIndex != -1 {
-				jsonPart := rawStr[startIndex:]
-				if err := json.Unmarshal([]byte(jsonPart), &res); err == nil {
-					metric := models.ModelMetric{
-						ModelName: "face-detection",
-						Version:   versionName,
-						Accuracy:  res.Accuracy,
-						Loss:      res.Loss,
-						CreatedAt: time.Now(),
-					}
-					database.DB.Create(&metric)
-					log.Printf("Stored metrics for %s: Acc=%.4f, Loss=%.4f", versionName, res.Accuracy, res.Loss)
-				} else {
-					log.Printf("Failed to unmarshal JSON from eval output: %v\nRaw: %s", err, rawStr)
-				}
-			} else {
-				log.Printf("No JSON found in eval output: %s", rawStr)
-			}
-		} else {
-			log.Printf("Failed to evaluate model %s: %v\nOutput: %s", modelPath, evalErr, string(evalOutput))
+	// Evaluate the stable version (once) and the candidate so the promotion gate can compare them.
+	// The evaluation workers run outside the aggregation lock and decide the round once both finish.
+	if stable, serr := ChannelVersion(m.Name, models.ModelChannelStable); serr == nil && VersionMetric(m.Name, stable.Version) == nil {
+		_, err = EnqueueEvaluation(m.Name, stable.Version, stable.FilePath, &roundID)
+	}
+	if err == nil {
+		_, err = EnqueueEvaluation(m.Name, candidate.Version, candidate.FilePath, &roundID)
+	}
+	if err != nil {
+		log.Printf("Failed to queue evaluation of %s version %s: %v", m.Name, candidate.Version, err)
+		closeRound(round, models.FLRoundRejected, "failed to queue evaluation: "+err.Error())
+	}
+}
+
+// aggregateUpdates combines the safetensors updates at paths with the given strategy and writes
+// the result into a copy of the base model at basePath, saved as outputPath. With privacy set the
+// updates are clipped and averaged with equal weight, and noise is added to the result instead.
+// For Krum it returns the indices of the updates that were kept.
+func aggregateUpdates(cfg AggregationConfig, privacy *PrivacyConfig, paths []string, weights []float64, basePath, outputPath string) ([]int, error) {
+	updates := make([]tensors.Weights, 0, len(paths))
+	for _, p := range paths {
+		w, err := tensors.ReadSafetensorsFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", filepath.Base(p), err)
 		}
+		updates = append(updates, w)
 	}
 
-	// Evaluate Old (if exists)
-	if _, err := os.Stat(oldModelPath); err == nil {
-		evalModel(oldModelPath, "previous")
+	base, err := os.ReadFile(basePath)
+	if err != nil {
+		return nil, err
 	}
-	// Evaluate New
-	evalModel(outputPath, newGlobalModelName)
 
-	// Step: Rotate face-detection.onnx in backend/models
-	if _, err := os.Stat(oldModelPath); err == nil {
-		oldModelName := fmt.Sprintf("face-detection_old_%d.onnx", time.Now().Unix())
-		archivePath := filepath.Join("./models", oldModelName)
+	var merged tensors.Weights
+	var selected []int
+	if privacy != nil {
+		merged, err = privateMean(updates, base, privacy)
+	} else {
+		merged, selected, err = cfg.Aggregate(updates, weights)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return selected, writeAggregate(base, merged, outputPath)
+}
 
-		// Some OS systems don't handle Rename well across drives/partitions, so we copy then delete
-		oldData, err := ioutil.ReadFile(oldModelPath)
-		if err == nil {
-			if err := ioutil.WriteFile(archivePath, oldData, 0644); err != nil {
-				log.Printf("Failed to archive old face-detection.onnx: %v", err)
-			} else {
-				os.Remove(oldModelPath)
-				log.Printf("Archived old model to %s", oldModelName)
-			}
+// writeAggregate writes merged into a copy of the base model, saved as outputPath
+func writeAggregate(base []byte, merged tensors.Weights, outputPath string) error {
+	if len(merged) == 0 {
+		return fmt.Errorf("updates contain no tensors")
+	}
+	patched, n, err := tensors.PatchONNXInitializers(base, merged)
+	if err != nil {
+		return err
+	}
+	if n != len(merged) {
+		return fmt.Errorf("only %d of %d aggregated tensors matched base model initializers", n, len(merged))
+	}
+	log.Printf("Wrote %d aggregated tensors into %s", n, filepath.Base(outputPath))
+	return os.WriteFile(outputPath, patched, 0644)
+}
+
+// privateMean is the differentially private average of updates: each update's change to the base
+// model is clipped to ClipNorm, the clipped updates are averaged with equal weight (a client-reported
+// sample count would otherwise scale its influence), and Gaussian noise calibrated to the mean's
+// sensitivity ClipNorm / n is added
+func privateMean(updates []tensors.Weights, baseModel []byte, p *PrivacyConfig) (tensors.Weights, error) {
+	base, err := tensors.ReadONNXInitializers(baseModel)
+	if err != nil {
+		return nil, err
+	}
+
+	clipped := make([]tensors.Weights, len(updates))
+	for i, u := range updates {
+		var norm float64
+		clipped[i], norm = tensors.ClipToBase(u, base, p.ClipNorm)
+		if norm > p.ClipNorm {
+			log.Printf("Clipped update %d from norm %.4f to %g", i, norm, p.ClipNorm)
 		}
 	}
 
-	// Step: Move/Copy the new aggregated model to backend/models/face-detection.onnx
-	inputData, err := ioutil.ReadFile(outputPath)
-	if err == nil {
-		if err := ioutil.WriteFile(oldModelPath, inputData, 0644); err != nil {
-			log.Printf("Failed to write new face-detection.onnx: %v", err)
-		} else {
-			log.Printf("Successfully replaced backend/models/face-detection.onnx")
-
-			// Update Database Metadata
-			var meta models.ModelMetadata
-			dbName := "face-detection"
-			if err := database.DB.Where("name = ?", dbName).First(&meta).Error; err == nil {
-				meta.Version = time.Now().Format("20060102150405")
-				meta.Size = int64(len(inputData))
-				meta.UpdatedAt = time.Now()
-				database.DB.Save(&meta)
-				log.Printf("Updated database metadata for %s to version %s", dbName, meta.Version)
+	mean, err := tensors.FedAvg(clipped, nil)
+	if err != nil {
+		return nil, err
+	}
+	tensors.AddGaussianNoise(mean, p.NoiseMultiplier*p.ClipNorm/float64(len(updates)), rand.New(rand.NewChaCha8(noiseSeed())))
+	return mean, nil
+}
+
+// evalResult is what an evaluator script reports for a model
+type evalResult struct {
+	Accuracy float64 `json:"accuracy"`
+	Loss     float64 `json:"loss"`
+	Error    string  `json:"error"`
+}
+
+// parseEvalResult reads the last JSON object line of the evaluator's output; anything the script
+// or its libraries log before it is ignored
+func parseEvalResult(output []byte) (evalResult, error) {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var res evalResult
+		if err := json.Unmarshal([]byte(line), &res); err == nil {
+			if res.Error != "" {
+				return res, fmt.Errorf("evaluator failed: %s", res.Error)
 			}
+			return res, nil
 		}
-	} else {
-		log.Printf("Failed to read the newly aggregated model for copying: %v", err)
 	}
+	return evalResult{}, fmt.Errorf("no JSON result in evaluator output")
+}
 
-	// Cleanup pending updates
-	for _, f := range modelFiles {
-		if err := os.Remove(f); err != nil {
-			log.Printf("Warning: Failed to delete processed update %s: %v", f, err)
+// pythonExecutable returns the python used for model evaluation: the project's virtualenv when
+// there is one, else python3 or python from PATH
+func pythonExecutable() string {
+	for _, p := range []string{"../.venv/Scripts/python.exe", "../.venv/bin/python"} {
+		if _, err := os.Stat(p); err == nil {
+			abs, _ := filepath.Abs(p)
+			return abs
+		}
+	}
+	if p, err := exec.LookPath("python3"); err == nil {
+		return p
+	}
+	return "python"
+}
+
+// FLStatus reports the state of federated aggregation: the settings and, per model, the current
+// round and the last run
+func FLStatus() map[string]interface{} {
+	lastRunsMu.Lock()
+	runs := make(map[string]AggregationRun, len(lastRuns))
+	for name, run := range lastRuns {
+		runs[name] = run
+	}
+	lastRunsMu.Unlock()
+
+	var list []map[string]interface{}
+	for _, m := range FLModels() {
+		var round models.FLRound
+		database.DB.Where("model_name = ? AND status IN ?", m.Name, []string{models.FLRoundOpen, models.FLRoundCollecting, models.FLRoundAggregating, models.FLRoundEvaluating}).
+			Order("id DESC").First(&round)
+
+		entry := map[string]interface{}{
+			"name":             m.Name,
+			"aggregation":      m.Aggregation.String(),
+			"evaluator":        m.Evaluator,
+			"promotion":        m.Promotion,
+			"promotion_margin": m.PromotionMargin,
+			"served_model":     ServedModelPath(m.Name),
+			"pending_updates":  round.Updates,
 		}
+		if round.ID != 0 {
+			entry["current_round"] = round
+		}
+		if run, ok := runs[m.Name]; ok {
+			entry["last_aggregation"] = run
+		}
+		if m.Privacy != nil {
+			entry["privacy"] = m.Privacy
+			entry["epsilon_spent"] = PrivacySpent(m.Name, m.Privacy)
+			entry["privacy_budget_exhausted"] = checkPrivacyBudget(m.Name, m.Privacy) != nil
+		}
+		if m.SecureAggregation {
+			entry["secure_aggregation"] = secAggProgress(round.ID)
+		}
+		list = append(list, entry)
+	}
+
+	return map[string]interface{}{
+		"default_model":        DefaultFLModel,
+		"min_updates_required": MinUpdatesRequired,
+		"aggregation_interval": AggregationInterval.String(),
+		"round_target":         FLRoundTarget,
+		"round_duration":       FLRoundDuration.String(),
+		"eval_workers":         EvalWorkers,
+		"eval_timeout":         EvalTimeout.String(),
+		"eval_jobs":            evalJobCounts(),
+		"models":               list,
 	}
 }
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+var (
+	// EvalWorkers is how many evaluations run at once. Configured with FL_EVAL_WORKERS.
+	EvalWorkers = 1
+
+	// EvalTimeout bounds one evaluation attempt. Configured with FL_EVAL_TIMEOUT.
+	EvalTimeout = 30 * time.Minute
+
+	// MaxEvalAttempts is how often a job is tried before it is marked failed. Configured with
+	// FL_EVAL_ATTEMPTS.
+	MaxEvalAttempts = 3
+
+	// EvalPollInterval is how often idle workers look for due jobs, such as retries whose backoff
+	// has passed
+	EvalPollInterval = 15 * time.Second
+
+	// maxEvalOutput is how much of the evaluator's output a job keeps, from the end
+	maxEvalOutput = 16 << 10
+
+	// evalWake nudges an idle worker when a job is queued
+	evalWake = make(chan struct{}, 1)
+)
+
+// initEvalJobs reads the worker configuration, requeues jobs interrupted by a restart and starts
+// the workers
+func initEvalJobs() {
+	if env := os.Getenv("FL_EVAL_WORKERS"); env != "" {
+		if n, err := strconv.Atoi(env); err == nil && n > 0 {
+			EvalWorkers = n
+		} else {
+			log.Printf("Warning: invalid FL_EVAL_WORKERS %q, using %d", env, EvalWorkers)
+		}
+	}
+	if env := os.Getenv("FL_EVAL_TIMEOUT"); env != "" {
+		if d, err := time.ParseDuration(env); err == nil && d > 0 {
+			EvalTimeout = d
+		} else {
+			log.Printf("Warning: invalid FL_EVAL_TIMEOUT %q, using %s", env, EvalTimeout)
+		}
+	}
+	if env := os.Getenv("FL_EVAL_ATTEMPTS"); env != "" {
+		if n, err := strconv.Atoi(env); err == nil && n > 0 {
+			MaxEvalAttempts = n
+		} else {
+			log.Printf("Warning: invalid FL_EVAL_ATTEMPTS %q, using %d", env, MaxEvalAttempts)
+		}
+	}
+
+	database.DB.Model(&models.EvalJob{}).
+		Where("status = ?", models.EvalJobRunning).
+		Updates(map[string]interface{}{"status": models.EvalJobQueued, "error": "interrupted by restart", "next_attempt_at": time.Now()})
+
+	for i := 0; i < EvalWorkers; i++ {
+		go evalWorker()
+	}
+}
+
+// EnqueueEvaluation queues an evaluation of the model file at path as version of modelName.
+// A version already waiting for evaluation is not queued twice; its existing job is returned.
+func EnqueueEvaluation(modelName, version, path string, roundID *uint) (*models.EvalJob, error) {
+	var job models.EvalJob
+	err := database.DB.Where("model_name = ? AND version = ? AND status IN ?", modelName, version, []string{models.EvalJobQueued, models.EvalJobRunning}).
+		First(&job).Error
+	if err == nil {
+		return &job, nil
+	}
+
+	job = models.EvalJob{
+		ModelName:     modelName,
+		Version:       version,
+		ModelPath:     path,
+		RoundID:       roundID,
+		Status:        models.EvalJobQueued,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+	if err := database.DB.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	select {
+	case evalWake <- struct{}{}:
+	default:
+	}
+	return &job, nil
+}
+
+// evalWorker runs due jobs one at a time until there are none, then waits for more
+func evalWorker() {
+	ticker := time.NewTicker(EvalPollInterval)
+	defer ticker.Stop()
+	for {
+		for job := claimEvalJob(); job != nil; job = claimEvalJob() {
+			runEvalJob(job)
+		}
+		select {
+		case <-evalWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimEvalJob marks the oldest due job running and returns it, or nil when none is due
+func claimEvalJob() *models.EvalJob {
+	for {
+		var job models.EvalJob
+		err := database.DB.Where("status = ? AND next_attempt_at <= ?", models.EvalJobQueued, time.Now()).
+			Order("next_attempt_at ASC, id ASC").First(&job).Error
+		if err != nil {
+			return nil
+		}
+
+		now := time.Now()
+		result := database.DB.Model(&models.EvalJob{}).
+			Where("id = ? AND status = ?", job.ID, models.EvalJobQueued).
+			Updates(map[string]interface{}{"status": models.EvalJobRunning, "attempts": gorm.Expr("attempts + 1"), "started_at": now})
+		if result.Error != nil {
+			return nil
+		}
+		if result.RowsAffected == 1 {
+			job.Status = models.EvalJobRunning
+			job.Attempts++
+			job.StartedAt = &now
+			return &job
+		}
+		// another worker claimed it first
+	}
+}
+
+// runEvalJob runs one attempt of a job. Jobs that can never succeed, because the model is no
+// longer trained or its file is gone, fail without retrying.
+func runEvalJob(job *models.EvalJob) {
+	m, err := GetFLModel(job.ModelName)
+	if err == nil && m.Evaluator == "" {
+		err = fmt.Errorf("model %s has no evaluator", m.Name)
+	}
+	if err == nil {
+		_, err = os.Stat(job.ModelPath)
+	}
+	if err != nil {
+		finishEvalJob(job, nil, nil, err, false)
+		return
+	}
+
+	log.Printf("Evaluating %s version %s (job %d, attempt %d of %d)", job.ModelName, job.Version, job.ID, job.Attempts, MaxEvalAttempts)
+	ctx, cancel := context.WithTimeout(context.Background(), EvalTimeout)
+	metric, output, err := evaluateModel(ctx, m, job.ModelPath, job.Version)
+	cancel()
+	finishEvalJob(job, metric, output, err, true)
+}
+
+// finishEvalJob records the outcome of an attempt. A failed attempt is retried with exponential
+// backoff until MaxEvalAttempts. Once a job is done, rounds waiting on it may be decided.
+func finishEvalJob(job *models.EvalJob, metric *models.ModelMetric, output []byte, err error, retry bool) {
+	if len(output) > maxEvalOutput {
+		output = output[len(output)-maxEvalOutput:]
+	}
+	job.Output = string(output)
+	now := time.Now()
+
+	switch {
+	case err == nil:
+		job.Status = models.EvalJobSucceeded
+		job.Accuracy, job.Loss = &metric.Accuracy, &metric.Loss
+		job.Error = ""
+		job.FinishedAt = &now
+		log.Printf("Evaluated %s version %s: accuracy=%.4f loss=%.4f", job.ModelName, job.Version, metric.Accuracy, metric.Loss)
+	case retry && job.Attempts < MaxEvalAttempts:
+		job.Status = models.EvalJobQueued
+		job.Error = err.Error()
+		job.NextAttemptAt = now.Add(time.Minute << (job.Attempts - 1))
+		log.Printf("Evaluation job %d of %s version %s failed, retrying at %s: %v", job.ID, job.ModelName, job.Version, job.NextAttemptAt.Format(time.RFC3339), err)
+	default:
+		job.Status = models.EvalJobFailed
+		job.Error = err.Error()
+		job.FinishedAt = &now
+		log.Printf("Giving up on evaluation job %d of %s version %s after %d attempts: %v", job.ID, job.ModelName, job.Version, job.Attempts, err)
+	}
+	database.DB.Save(job)
+
+	if job.Status != models.EvalJobQueued {
+		finishEvaluatingRounds(job.ModelName)
+	}
+}
+
+// finishEvaluatingRounds promotes or rejects the model's rounds whose evaluations have all
+// finished. Promotion runs under the aggregation lock, since it changes the version new rounds
+// train from.
+func finishEvaluatingRounds(modelName string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var rounds []models.FLRound
+	database.DB.Where("model_name = ? AND status = ?", modelName, models.FLRoundEvaluating).Order("id ASC").Find(&rounds)
+	for i := range rounds {
+		finishEvaluatingRound(&rounds[i])
+	}
+}
+
+// finishEvaluatingRound decides a round once its candidate, and the stable version it is compared
+// with, are no longer waiting for evaluation
+func finishEvaluatingRound(round *models.FLRound) {
+	waiting := []string{round.ResultVersion}
+	if stable, err := ChannelVersion(round.ModelName, models.ModelChannelStable); err == nil {
+		waiting = append(waiting, stable.Version)
+	}
+	var pending int64
+	database.DB.Model(&models.EvalJob{}).
+		Where("model_name = ? AND version IN ? AND status IN ?", round.ModelName, waiting, []string{models.EvalJobQueued, models.EvalJobRunning}).
+		Count(&pending)
+	if pending > 0 {
+		return
+	}
+
+	m, err := GetFLModel(round.ModelName)
+	if err != nil {
+		closeRound(round, models.FLRoundRejected, "model is no longer trained by federated learning")
+		return
+	}
+	candidate, err := GetModelVersion(round.ModelName, round.ResultVersion)
+	if err != nil {
+		closeRound(round, models.FLRoundRejected, "candidate version is missing: "+err.Error())
+		return
+	}
+
+	if VersionMetric(m.Name, candidate.Version) == nil {
+		var job models.EvalJob
+		if err := database.DB.Where("model_name = ? AND version = ?", m.Name, candidate.Version).Order("id DESC").First(&job).Error; err != nil {
+			// a round left evaluating before evaluations were queued
+			roundID := round.ID
+			if _, err := EnqueueEvaluation(m.Name, candidate.Version, candidate.FilePath, &roundID); err != nil {
+				log.Printf("Failed to queue evaluation of %s version %s: %v", m.Name, candidate.Version, err)
+			}
+			return
+		}
+		closeRound(round, models.FLRoundRejected, "evaluation failed: "+job.Error)
+		return
+	}
+
+	// Only a candidate that beats the stable version is served; the rest stay in the registry
+	promoted, reason, err := PromoteCandidate(candidate, m.PromotionMargin)
+	if err != nil {
+		log.Printf("Failed to promote %s version %s: %v", m.Name, candidate.Version, err)
+		closeRound(round, models.FLRoundRejected, "failed to install aggregated model: "+err.Error())
+		return
+	}
+	if !promoted {
+		log.Printf("Held back %s version %s: %s", m.Name, candidate.Version, reason)
+		closeRound(round, models.FLRoundRejected, "not promoted: "+reason)
+		return
+	}
+
+	// Closing the round also removes its update files
+	closeRound(round, models.FLRoundPromoted, "")
+}
+
+// enqueueStartupEvaluations queues evaluations of the versions the dashboard and promotion gate
+// compare against, skipping files evaluated since they last changed
+func enqueueStartupEvaluations() {
+	enqueue := func(m *FLModel, modelPath, label string) {
+		fileInfo, err := os.Stat(modelPath)
+		if err != nil {
+			log.Printf("SKIP: Model %s not found", modelPath)
+			return
+		}
+		var lastMetric models.ModelMetric
+		if err := database.DB.Where("model_name = ? AND version = ?", m.Name, label).Order("created_at desc").First(&lastMetric).Error; err == nil {
+			if lastMetric.CreatedAt.After(fileInfo.ModTime()) {
+				return
+			}
+		}
+		if _, err := EnqueueEvaluation(m.Name, label, modelPath, nil); err != nil {
+			log.Printf("Failed to queue evaluation of %s %s: %v", m.Name, label, err)
+		}
+	}
+
+	for _, m := range FLModels() {
+		if m.Evaluator == "" {
+			continue
+		}
+
+		// 1. Evaluate the Original Baseline the default model was trained from
+		if m.Name == DefaultFLModel {
+			enqueue(m, "./models/yolov8n-face.onnx", "original_baseline")
+		}
+
+		// 2. Evaluate the stable version, the baseline for promoting new candidates
+		if stable, err := ChannelVersion(m.Name, models.ModelChannelStable); err == nil {
+			enqueue(m, stable.FilePath, stable.Version)
+		}
+
+		// 3. Backfill history if empty from the registered versions
+		var count int64
+		database.DB.Model(&models.ModelMetric{}).Where("model_name = ?", m.Name).Count(&count)
+		if count <= 2 { // Only original and current exist
+			versions, _ := ListModelVersions(m.Name)
+			for _, v := range versions {
+				if v.Metrics == nil {
+					enqueue(m, v.FilePath, v.Version)
+				}
+			}
+		}
+
+		// Rounds may have been waiting on evaluations that finished before a restart
+		finishEvaluatingRounds(m.Name)
+	}
+}
+
+// evalJobCounts returns how many evaluation jobs are in each status
+func evalJobCounts() map[string]int64 {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	database.DB.Model(&models.EvalJob{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows)
+	counts := map[string]int64{}
+	for _, r := range rows {
+		counts[r.Status] = r.Count
+	}
+	return counts
+}
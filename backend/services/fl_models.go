@@ -0,0 +1,158 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+// Promotion policies for the candidates a model's rounds produce
+const (
+	PromoteOnImprovement = "improvement" // install when the evaluator shows it beats stable by the margin
+	PromoteManual        = "manual"      // stay on the candidate channel until an admin installs it
+)
+
+// FLModel is a model trained by federated rounds. Each has its own rounds and update queue,
+// trains from its own stable version, and has its own aggregation, evaluator and promotion policy.
+//
+// With SecureAggregation the server only ever sees the sum of a round's updates. Updates are
+// averaged with equal weight and cannot be validated individually, so robust strategies and
+// differential privacy, which both need individual updates, are unavailable.
+type FLModel struct {
+	Name              string            `json:"name"`
+	Aggregation       AggregationConfig `json:"aggregation"`
+	Evaluator         string            `json:"evaluator,omitempty"` // script run as `python <evaluator> --model <path>`
+	Promotion         string            `json:"promotion"`
+	PromotionMargin   float64           `json:"promotion_margin"`
+	Privacy           *PrivacyConfig    `json:"privacy,omitempty"` // differential privacy; nil trains without
+	SecureAggregation bool              `json:"secure_aggregation,omitempty"`
+}
+
+var (
+	// DefaultFLModel is the model for clients that don't name one
+	DefaultFLModel = "face-detection"
+
+	// FLModelsConfig is a JSON array of FLModel entries. Configured with FL_MODELS_CONFIG; without
+	// the file only DefaultFLModel is trained.
+	FLModelsConfig = "./fl_models.json"
+
+	// ErrUnknownFLModel is returned for a model that isn't trained by federated learning
+	ErrUnknownFLModel = errors.New("model is not trained by federated learning")
+
+	flModels = map[string]*FLModel{}
+)
+
+// Validate checks the aggregation settings and the promotion policy
+func (m *FLModel) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("model name is required")
+	}
+	if err := m.Aggregation.Validate(); err != nil {
+		return err
+	}
+	switch m.Promotion {
+	case PromoteOnImprovement:
+		if m.Evaluator == "" {
+			return fmt.Errorf("promotion %q needs an evaluator", m.Promotion)
+		}
+	case PromoteManual:
+	default:
+		return fmt.Errorf("unknown promotion policy %q", m.Promotion)
+	}
+	if m.PromotionMargin < 0 {
+		return fmt.Errorf("promotion_margin must not be negative, got %g", m.PromotionMargin)
+	}
+	if m.Privacy != nil {
+		if err := m.Privacy.Validate(); err != nil {
+			return err
+		}
+		// The noise is calibrated to an equally weighted mean of clipped updates
+		if m.Aggregation.Strategy != StrategyFedAvg {
+			return fmt.Errorf("privacy requires the %s strategy, got %s", StrategyFedAvg, m.Aggregation.Strategy)
+		}
+	}
+	if m.SecureAggregation {
+		if m.Privacy != nil {
+			return fmt.Errorf("privacy cannot be combined with secure aggregation: clipping needs individual updates")
+		}
+		if m.Aggregation.Strategy != StrategyFedAvg {
+			return fmt.Errorf("secure aggregation requires the %s strategy, got %s", StrategyFedAvg, m.Aggregation.Strategy)
+		}
+	}
+	return nil
+}
+
+// initFLModels loads the trained models from FLModelsConfig. Unset fields default to the
+// environment-wide aggregation and promotion margin; models without an evaluator are promoted manually.
+func initFLModels() {
+	if env := os.Getenv("FL_MODELS_CONFIG"); env != "" {
+		FLModelsConfig = env
+	}
+
+	var entries []json.RawMessage
+	if data, err := os.ReadFile(FLModelsConfig); err == nil {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			log.Printf("Warning: invalid FL models config %s (%v), training %s only", FLModelsConfig, err, DefaultFLModel)
+			entries = nil
+		}
+	} else if !os.IsNotExist(err) {
+		log.Printf("Warning: could not read FL models config %s: %v", FLModelsConfig, err)
+	}
+	if len(entries) == 0 {
+		entries = []json.RawMessage{json.RawMessage(fmt.Sprintf(`{"name": %q, "evaluator": "./scripts/evaluate_model.py"}`, DefaultFLModel))}
+	}
+
+	configured := map[string]*FLModel{}
+	for _, raw := range entries {
+		m := &FLModel{Aggregation: DefaultAggregation, PromotionMargin: PromotionMargin}
+		if err := json.Unmarshal(raw, m); err != nil {
+			log.Printf("Warning: invalid FL model entry %s: %v", raw, err)
+			continue
+		}
+		if m.Promotion == "" {
+			m.Promotion = PromoteManual
+			if m.Evaluator != "" {
+				m.Promotion = PromoteOnImprovement
+			}
+		}
+		if err := m.Validate(); err != nil {
+			log.Printf("Warning: skipping FL model %q: %v", m.Name, err)
+			continue
+		}
+		configured[m.Name] = m
+		log.Printf("FL model %s: %s, promotion %s (margin %g)", m.Name, m.Aggregation, m.Promotion, m.PromotionMargin)
+		if p := m.Privacy; p != nil {
+			log.Printf("FL model %s: differential privacy with clip %g, noise multiplier %g, budget ε=%g at δ=%g",
+				m.Name, p.ClipNorm, p.NoiseMultiplier, p.EpsilonBudget, p.Delta)
+		}
+		if m.SecureAggregation {
+			log.Printf("FL model %s: secure aggregation", m.Name)
+		}
+	}
+	flModels = configured
+}
+
+// GetFLModel returns a trained model by name; an empty name selects DefaultFLModel
+func GetFLModel(name string) (*FLModel, error) {
+	if name == "" {
+		name = DefaultFLModel
+	}
+	m, ok := flModels[name]
+	if !ok {
+		return nil, ErrUnknownFLModel
+	}
+	return m, nil
+}
+
+// FLModels returns the trained models sorted by name
+func FLModels() []*FLModel {
+	list := make([]*FLModel, 0, len(flModels))
+	for _, m := range flModels {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
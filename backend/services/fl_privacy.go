@@ -0,0 +1,97 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+// ErrPrivacyBudgetExhausted is returned when another round would take a model past its epsilon budget
+var ErrPrivacyBudgetExhausted = errors.New("privacy budget exhausted")
+
+// PrivacyConfig enables differential privacy for a model's rounds. Each update's change to the
+// round's base is clipped to an L2 norm of ClipNorm, the clipped updates are averaged with equal
+// weight, and Gaussian noise with standard deviation NoiseMultiplier * ClipNorm / n is added to
+// the average, so no single participant's update can be singled out from the result.
+type PrivacyConfig struct {
+	ClipNorm        float64 `json:"clip_norm"`
+	NoiseMultiplier float64 `json:"noise_multiplier"`
+	Delta           float64 `json:"delta"`          // δ of the reported (ε, δ) guarantee
+	EpsilonBudget   float64 `json:"epsilon_budget"` // no rounds open once cumulative ε would exceed this
+}
+
+// Validate checks that every parameter is in range
+func (p *PrivacyConfig) Validate() error {
+	if !(p.ClipNorm > 0) || math.IsInf(p.ClipNorm, 0) {
+		return fmt.Errorf("clip_norm must be positive, got %g", p.ClipNorm)
+	}
+	if !(p.NoiseMultiplier > 0) || math.IsInf(p.NoiseMultiplier, 0) {
+		return fmt.Errorf("noise_multiplier must be positive, got %g", p.NoiseMultiplier)
+	}
+	if !(p.Delta > 0 && p.Delta < 1) {
+		return fmt.Errorf("delta must be in (0, 1), got %g", p.Delta)
+	}
+	if !(p.EpsilonBudget > 0) {
+		return fmt.Errorf("epsilon_budget must be positive, got %g", p.EpsilonBudget)
+	}
+	return nil
+}
+
+// RoundRho is the privacy cost of one noisy aggregate. The Gaussian mechanism with noise
+// NoiseMultiplier times its sensitivity is 1/(2z²)-zCDP, and zCDP costs add up across rounds.
+func (p *PrivacyConfig) RoundRho() float64 {
+	return 1 / (2 * p.NoiseMultiplier * p.NoiseMultiplier)
+}
+
+// EpsilonFor converts a cumulative zCDP cost rho into the epsilon of an (ε, δ) guarantee
+func EpsilonFor(rho, delta float64) float64 {
+	if rho <= 0 {
+		return 0
+	}
+	return rho + 2*math.Sqrt(rho*math.Log(1/delta))
+}
+
+// spentRho is the privacy cost of every noisy aggregate released for a model so far
+func spentRho(modelName string) float64 {
+	var rho float64
+	database.DB.Model(&models.FLRound{}).Where("model_name = ?", modelName).
+		Select("COALESCE(SUM(privacy_rho), 0)").Scan(&rho)
+	return rho
+}
+
+// PrivacySpent returns the cumulative epsilon a model's training data has spent under p
+func PrivacySpent(modelName string, p *PrivacyConfig) float64 {
+	return EpsilonFor(spentRho(modelName), p.Delta)
+}
+
+// checkPrivacyBudget fails once one more round under p would take the model past its budget
+func checkPrivacyBudget(modelName string, p *PrivacyConfig) error {
+	if EpsilonFor(spentRho(modelName)+p.RoundRho(), p.Delta) > p.EpsilonBudget {
+		return ErrPrivacyBudgetExhausted
+	}
+	return nil
+}
+
+// parsePrivacy reads the privacy settings stored with a round; nil when the round had none
+func parsePrivacy(raw string) *PrivacyConfig {
+	if raw == "" {
+		return nil
+	}
+	var p PrivacyConfig
+	if err := json.Unmarshal([]byte(raw), &p); err != nil || p.Validate() != nil {
+		return nil
+	}
+	return &p
+}
+
+// noiseSeed returns a fresh random seed for the noise generator; predictable noise could be subtracted
+func noiseSeed() [32]byte {
+	var seed [32]byte
+	rand.Read(seed[:])
+	return seed
+}
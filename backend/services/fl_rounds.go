@@ -0,0 +1,337 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+var (
+	// FLRoundTarget is how many updates close a round early. Configured with FL_ROUND_TARGET.
+	FLRoundTarget = 10
+
+	// FLRoundDuration is how long a round collects updates. Configured with FL_ROUND_DURATION.
+	FLRoundDuration = 24 * time.Hour
+
+	// FLMaxSampleWeight caps the sample count an update is weighted by, so one client can't
+	// outweigh the rest by claiming a huge dataset. Configured with FL_MAX_SAMPLE_WEIGHT.
+	FLMaxSampleWeight = 5000
+
+	// ErrRoundStale is returned for updates to a round that no longer accepts them, or trained
+	// from a different base version than the round's
+	ErrRoundStale = errors.New("round is closed or update was trained from another base version")
+
+	// ErrRoundModelMismatch is returned for an update naming a different model than its round's
+	ErrRoundModelMismatch = errors.New("round belongs to another model")
+
+	// ErrDuplicateUpdate is returned when a participant submits twice to the same round
+	ErrDuplicateUpdate = errors.New("an update was already submitted to this round")
+
+	// ErrInvalidUpdateMetadata is returned for training metadata that is out of range
+	ErrInvalidUpdateMetadata = errors.New("invalid update metadata")
+
+	// ErrSecureAggregationRequired is returned for a plain update to a round that only accepts
+	// masked updates through the secure aggregation protocol
+	ErrSecureAggregationRequired = errors.New("round requires secure aggregation")
+)
+
+// FLUpdateMetadata is the training metadata a client submits with an update
+type FLUpdateMetadata struct {
+	SampleCount     int      `json:"sample_count"`
+	Epochs          int      `json:"epochs"`
+	LocalLoss       *float64 `json:"local_loss"`
+	LocalAccuracy   *float64 `json:"local_accuracy"`
+	BaseVersion     string   `json:"base_version"`
+	AppVersion      string   `json:"app_version"`
+	TrainingSeconds float64  `json:"training_seconds"`
+}
+
+// Validate rejects negative counts, non-finite metrics and accuracies outside [0, 1]
+func (m *FLUpdateMetadata) Validate() error {
+	if m.SampleCount < 0 || m.Epochs < 0 || m.TrainingSeconds < 0 || math.IsNaN(m.TrainingSeconds) || math.IsInf(m.TrainingSeconds, 0) {
+		return ErrInvalidUpdateMetadata
+	}
+	if m.LocalLoss != nil && (math.IsNaN(*m.LocalLoss) || math.IsInf(*m.LocalLoss, 0)) {
+		return ErrInvalidUpdateMetadata
+	}
+	if m.LocalAccuracy != nil && !(*m.LocalAccuracy >= 0 && *m.LocalAccuracy <= 1) {
+		return ErrInvalidUpdateMetadata
+	}
+	if len(m.AppVersion) > 64 {
+		return ErrInvalidUpdateMetadata
+	}
+	return nil
+}
+
+// UpdateWeight is the FedAvg weight of an update: its sample count, at least 1 for clients that
+// don't report one, and at most FLMaxSampleWeight
+func UpdateWeight(sampleCount int) float64 {
+	if sampleCount < 1 {
+		return 1
+	}
+	if sampleCount > FLMaxSampleWeight {
+		return float64(FLMaxSampleWeight)
+	}
+	return float64(sampleCount)
+}
+
+// initFLRounds reads the round configuration and resumes rounds interrupted by a restart
+func initFLRounds() {
+	initFLValidator()
+	initAggregation()
+	initFLModels()
+	initSecAgg()
+
+	if env := os.Getenv("FL_ROUND_TARGET"); env != "" {
+		if n, err := strconv.Atoi(env); err == nil && n > 0 {
+			FLRoundTarget = n
+		} else {
+			log.Printf("Warning: invalid FL_ROUND_TARGET %q, using %d", env, FLRoundTarget)
+		}
+	}
+	if env := os.Getenv("FL_MIN_UPDATES"); env != "" {
+		if n, err := strconv.Atoi(env); err == nil && n > 0 {
+			MinUpdatesRequired = n
+		} else {
+			log.Printf("Warning: invalid FL_MIN_UPDATES %q, using %d", env, MinUpdatesRequired)
+		}
+	}
+	if env := os.Getenv("FL_MAX_SAMPLE_WEIGHT"); env != "" {
+		if n, err := strconv.Atoi(env); err == nil && n > 0 {
+			FLMaxSampleWeight = n
+		} else {
+			log.Printf("Warning: invalid FL_MAX_SAMPLE_WEIGHT %q, using %d", env, FLMaxSampleWeight)
+		}
+	}
+	if env := os.Getenv("FL_ROUND_DURATION"); env != "" {
+		if d, err := time.ParseDuration(env); err == nil && d > 0 {
+			FLRoundDuration = d
+		} else {
+			log.Printf("Warning: invalid FL_ROUND_DURATION %q, using %s", env, FLRoundDuration)
+		}
+	}
+
+	// The protocol state of secure rounds lived in memory, so their masked updates can't be unmasked
+	database.DB.Model(&models.FLRound{}).
+		Where("secure_aggregation = ? AND status IN ?", true, []string{models.FLRoundOpen, models.FLRoundCollecting, models.FLRoundAggregating}).
+		Updates(map[string]interface{}{"status": models.FLRoundRejected, "error": "secure aggregation state lost on restart", "closed_at": time.Now()})
+
+	// Rounds that were aggregating when the server stopped still have their updates; retry them.
+	// Evaluating rounds resume with their queued evaluation jobs.
+	database.DB.Model(&models.FLRound{}).
+		Where("status = ?", models.FLRoundAggregating).
+		Updates(map[string]interface{}{"status": models.FLRoundCollecting, "error": "interrupted by restart"})
+}
+
+// RoundUpdatesDir is where the updates of one round are stored until it is aggregated.
+// Each model queues its updates in its own directory.
+func RoundUpdatesDir(round *models.FLRound) string {
+	return filepath.Join(PendingUpdatesDir, round.ModelName, fmt.Sprintf("round_%d", round.ID))
+}
+
+// currentBaseVersion is the stable version of a model that new rounds train from
+func currentBaseVersion(name string) string {
+	if v, err := ChannelVersion(name, models.ModelChannelStable); err == nil {
+		return v.Version
+	}
+	var meta models.ModelMetadata
+	if err := database.DB.Where("name = ?", name).First(&meta).Error; err != nil {
+		return ""
+	}
+	return meta.Version
+}
+
+// roundBasePath is the model file a round's updates were trained from: its base version in the
+// registry, or the served file for a model that predates the registry
+func roundBasePath(round *models.FLRound) string {
+	if v, err := GetModelVersion(round.ModelName, round.BaseVersion); err == nil {
+		return v.FilePath
+	}
+	return ServedModelPath(round.ModelName)
+}
+
+// CurrentRound returns the model's round accepting updates, opening a new one when there is none
+func CurrentRound(m *FLModel) (*models.FLRound, error) {
+	var round models.FLRound
+	err := database.DB.Where("model_name = ? AND status IN ?", m.Name, []string{models.FLRoundOpen, models.FLRoundCollecting}).
+		Order("id DESC").First(&round).Error
+	if err == nil {
+		return &round, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Differentially private models stop training once their epsilon budget is spent
+	var privacy []byte
+	if m.Privacy != nil {
+		if err := checkPrivacyBudget(m.Name, m.Privacy); err != nil {
+			return nil, err
+		}
+		privacy, _ = json.Marshal(m.Privacy)
+	}
+
+	aggregation := m.Aggregation
+	params, _ := json.Marshal(aggregation)
+	minParticipants := MinUpdatesRequired
+	if n := aggregation.MinUpdates(); n > minParticipants {
+		minParticipants = n
+	}
+	targetParticipants := FLRoundTarget
+	if targetParticipants < minParticipants {
+		targetParticipants = minParticipants
+	}
+
+	round = models.FLRound{
+		ModelName:          m.Name,
+		BaseVersion:        currentBaseVersion(m.Name),
+		TargetParticipants: targetParticipants,
+		MinParticipants:    minParticipants,
+		Strategy:           aggregation.Strategy,
+		StrategyParams:     string(params),
+		Privacy:            string(privacy),
+		SecureAggregation:  m.SecureAggregation,
+		Deadline:           time.Now().Add(FLRoundDuration),
+		Status:             models.FLRoundOpen,
+		CreatedAt:          time.Now(),
+	}
+	if err := database.DB.Create(&round).Error; err != nil {
+		return nil, err
+	}
+	log.Printf("Opened FL round %d for %s (base version %q, %s)", round.ID, round.ModelName, round.BaseVersion, aggregation)
+	return &round, nil
+}
+
+// CheckRoundUpdate makes sure an update of modelName for roundID trained from baseVersion is still wanted
+func CheckRoundUpdate(roundID uint, modelName, baseVersion, userID string) (*models.FLRound, error) {
+	var round models.FLRound
+	if err := database.DB.First(&round, roundID).Error; err != nil {
+		return nil, ErrRoundStale
+	}
+	if round.ModelName != modelName {
+		return nil, ErrRoundModelMismatch
+	}
+	if round.SecureAggregation {
+		return nil, ErrSecureAggregationRequired
+	}
+	if round.Status != models.FLRoundOpen && round.Status != models.FLRoundCollecting {
+		return nil, ErrRoundStale
+	}
+	if baseVersion != round.BaseVersion || time.Now().After(round.Deadline) {
+		return nil, ErrRoundStale
+	}
+	if hasAcceptedUpdate(round.ID, userID) {
+		return nil, ErrDuplicateUpdate
+	}
+	return &round, nil
+}
+
+// hasAcceptedUpdate reports whether the user already has an accepted update in the round
+func hasAcceptedUpdate(roundID uint, userID string) bool {
+	var count int64
+	database.DB.Model(&models.FLUpdate{}).Where("round_id = ? AND user_id = ? AND status = ?", roundID, userID, models.FLUpdateAccepted).Count(&count)
+	return count > 0
+}
+
+// RecordRoundUpdate registers a stored update file and its metadata with its round. The round must
+// still be accepting updates, so an update racing the round's close is refused.
+func RecordRoundUpdate(round *models.FLRound, userID, path string, size int64, meta FLUpdateMetadata) (*models.FLUpdate, error) {
+	update := models.FLUpdate{
+		RoundID:         round.ID,
+		UserID:          userID,
+		FilePath:        path,
+		BaseVersion:     round.BaseVersion,
+		Size:            size,
+		CreatedAt:       time.Now(),
+		SampleCount:     meta.SampleCount,
+		Epochs:          meta.Epochs,
+		LocalLoss:       meta.LocalLoss,
+		LocalAccuracy:   meta.LocalAccuracy,
+		AppVersion:      meta.AppVersion,
+		TrainingSeconds: meta.TrainingSeconds,
+		Weight:          UpdateWeight(meta.SampleCount),
+		Status:          models.FLUpdateAccepted,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.FLRound{}).
+			Where("id = ? AND status IN ?", round.ID, []string{models.FLRoundOpen, models.FLRoundCollecting}).
+			Updates(map[string]interface{}{"status": models.FLRoundCollecting, "updates": gorm.Expr("updates + 1")})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoundStale
+		}
+		return tx.Create(&update).Error
+	})
+	if err != nil {
+		// A concurrent upload from the same user loses on the unique index
+		if hasAcceptedUpdate(round.ID, userID) {
+			return nil, ErrDuplicateUpdate
+		}
+		return nil, err
+	}
+	return &update, nil
+}
+
+// setRoundStatus moves a round to its next state
+func setRoundStatus(round *models.FLRound, status string) {
+	round.Status = status
+	database.DB.Model(round).Update("status", status)
+}
+
+// closeRound promotes or rejects a round and removes its update files
+func closeRound(round *models.FLRound, status, reason string) {
+	now := time.Now()
+	round.Status = status
+	round.Error = reason
+	round.ClosedAt = &now
+	database.DB.Model(round).Updates(map[string]interface{}{
+		"status":         status,
+		"error":          reason,
+		"closed_at":      now,
+		"result_model":   round.ResultModel,
+		"result_version": round.ResultVersion,
+	})
+	if reason != "" {
+		log.Printf("FL round %d %s: %s", round.ID, status, reason)
+	} else {
+		log.Printf("FL round %d %s", round.ID, status)
+	}
+
+	forgetSecAgg(round.ID)
+	if err := os.RemoveAll(RoundUpdatesDir(round)); err != nil {
+		log.Printf("Warning: Failed to delete updates of round %d: %v", round.ID, err)
+	}
+}
+
+// roundReady reports whether a round with n updates should be aggregated now. A round past
+// its deadline without any update gets a new deadline instead of being closed.
+func roundReady(round *models.FLRound, n int) (ready bool, reject string) {
+	if n >= round.TargetParticipants && n >= round.MinParticipants {
+		return true, ""
+	}
+	if time.Now().Before(round.Deadline) {
+		return false, ""
+	}
+	if n == 0 {
+		round.Deadline = time.Now().Add(FLRoundDuration)
+		database.DB.Model(round).Update("deadline", round.Deadline)
+		return false, ""
+	}
+	if n < round.MinParticipants {
+		return false, fmt.Sprintf("deadline passed with %d of %d required updates", n, round.MinParticipants)
+	}
+	return true, ""
+}
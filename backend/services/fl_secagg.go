@@ -0,0 +1,347 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/secagg"
+	"chithram/tensors"
+)
+
+var (
+	// SecAggPhaseTimeout is how long each secure aggregation phase after the keys phase waits for
+	// participants that haven't answered. Configured with FL_SECAGG_PHASE_TIMEOUT.
+	SecAggPhaseTimeout = 10 * time.Minute
+
+	// ErrNotSecureRound is returned for protocol messages to a round without secure aggregation
+	ErrNotSecureRound = errors.New("round does not use secure aggregation")
+
+	// secaggRounds holds the protocol state of open secure rounds. It is never persisted, so the
+	// server cannot be made to unmask anything after a restart.
+	secaggRounds = map[uint]*secaggRound{}
+	secaggMu     sync.Mutex
+)
+
+// SecAggTensor is one tensor of the vector participants mask: a round's vector is every float
+// initializer of its base model, sorted by name, flattened and concatenated
+type SecAggTensor struct {
+	Name  string  `json:"name"`
+	Shape []int64 `json:"shape"`
+}
+
+// secaggRound is the protocol state of one secure round
+type secaggRound struct {
+	server       *secagg.Server
+	layout       []SecAggTensor
+	phaseStarted time.Time
+}
+
+// SecAggStatus is what a participant needs to take part in the current phase of a secure round
+type SecAggStatus struct {
+	RoundID   uint              `json:"round_id"`
+	Phase     secagg.Phase      `json:"phase"`
+	Joined    int               `json:"joined"`
+	FracBits  int               `json:"frac_bits"`
+	Length    int               `json:"length"`
+	Tensors   []SecAggTensor    `json:"tensors"`
+	Roster    *secagg.Roster    `json:"roster,omitempty"`    // from the shares phase on
+	Shares    map[string][]byte `json:"shares,omitempty"`    // ciphertexts addressed to the caller, from the masked phase on
+	Survivors []string          `json:"survivors,omitempty"` // in the unmask phase
+	Error     string            `json:"error,omitempty"`
+}
+
+func initSecAgg() {
+	if env := os.Getenv("FL_SECAGG_PHASE_TIMEOUT"); env != "" {
+		if d, err := time.ParseDuration(env); err == nil && d > 0 {
+			SecAggPhaseTimeout = d
+		} else {
+			log.Printf("Warning: invalid FL_SECAGG_PHASE_TIMEOUT %q, using %s", env, SecAggPhaseTimeout)
+		}
+	}
+}
+
+// loadSecAgg returns a secure round still running the protocol and its state, starting the
+// protocol when the round has just opened. The caller must hold secaggMu.
+func loadSecAgg(roundID uint) (*models.FLRound, *secaggRound, error) {
+	var round models.FLRound
+	if err := database.DB.First(&round, roundID).Error; err != nil {
+		return nil, nil, ErrRoundStale
+	}
+	if !round.SecureAggregation {
+		return nil, nil, ErrNotSecureRound
+	}
+	if round.Status != models.FLRoundOpen && round.Status != models.FLRoundCollecting {
+		return nil, nil, ErrRoundStale
+	}
+	if st, ok := secaggRounds[round.ID]; ok {
+		return &round, st, nil
+	}
+	if round.Status != models.FLRoundOpen {
+		// participants joined a protocol whose state is gone; the worker rejects the round
+		return nil, nil, ErrRoundStale
+	}
+
+	base, err := os.ReadFile(roundBasePath(&round))
+	if err != nil {
+		return nil, nil, err
+	}
+	weights, err := tensors.ReadONNXInitializers(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	st := &secaggRound{phaseStarted: time.Now()}
+	length := 0
+	for _, name := range weights.Names() {
+		st.layout = append(st.layout, SecAggTensor{Name: name, Shape: weights[name].Shape})
+		length += len(weights[name].Data)
+	}
+	st.server = secagg.NewServer(length, round.MinParticipants)
+	secaggRounds[round.ID] = st
+	return &round, st, nil
+}
+
+// forgetSecAgg drops the protocol state of a closed round
+func forgetSecAgg(roundID uint) {
+	secaggMu.Lock()
+	delete(secaggRounds, roundID)
+	secaggMu.Unlock()
+}
+
+// advance closes the current phase once every participant answered it or it timed out. The keys
+// phase closes like a plain round collecting updates: at the target participant count, or at
+// the deadline with enough participants. The unmask phase is finished by the aggregation worker.
+func (st *secaggRound) advance(round *models.FLRound) {
+	s := st.server
+	var err error
+	switch s.Phase() {
+	case secagg.PhaseKeys:
+		ready, reject := roundReady(round, s.Joined())
+		if reject != "" {
+			s.Fail(errors.New(reject))
+			return
+		}
+		if !ready {
+			return
+		}
+		var roster *secagg.Roster
+		if roster, err = s.CloseKeys(); err == nil {
+			log.Printf("Secure FL round %d: %d participants, threshold %d", round.ID, len(roster.Participants), roster.Threshold)
+		}
+	case secagg.PhaseShares, secagg.PhaseMasked:
+		if len(s.Pending()) > 0 && time.Since(st.phaseStarted) < SecAggPhaseTimeout {
+			return
+		}
+		if s.Phase() == secagg.PhaseShares {
+			err = s.CloseShares()
+		} else {
+			var survivors []string
+			if survivors, err = s.CloseMasked(); err == nil {
+				log.Printf("Secure FL round %d: %d masked updates, unmasking", round.ID, len(survivors))
+			}
+		}
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("Secure FL round %d failed: %v", round.ID, err)
+		return
+	}
+	st.phaseStarted = time.Now()
+}
+
+// secAggCall runs fn against a secure round's protocol state and advances the protocol
+func secAggCall(roundID uint, fn func(*models.FLRound, *secaggRound) error) error {
+	secaggMu.Lock()
+	defer secaggMu.Unlock()
+	round, st, err := loadSecAgg(roundID)
+	if err != nil {
+		return err
+	}
+	if err := fn(round, st); err != nil {
+		return err
+	}
+	st.advance(round)
+	return nil
+}
+
+// SecAggState returns the protocol state of a secure round as seen by userID
+func SecAggState(roundID uint, userID string) (*SecAggStatus, error) {
+	var status *SecAggStatus
+	err := secAggCall(roundID, func(round *models.FLRound, st *secaggRound) error {
+		s := st.server
+		status = &SecAggStatus{
+			RoundID:  round.ID,
+			Phase:    s.Phase(),
+			Joined:   s.Joined(),
+			FracBits: secagg.FracBits,
+			Length:   s.Length(),
+			Tensors:  st.layout,
+			Roster:   s.Roster(),
+		}
+		if s.Phase() == secagg.PhaseMasked {
+			status.Shares, _ = s.SharesFor(userID)
+		}
+		if s.Phase() == secagg.PhaseUnmask {
+			status.Survivors = s.Survivors()
+		}
+		if err := s.Err(); err != nil {
+			status.Error = err.Error()
+		}
+		return nil
+	})
+	return status, err
+}
+
+// SecAggSubmitKeys joins userID to a secure round with its public keys. The round must have been
+// opened from baseVersion, the model the participant will train from.
+func SecAggSubmitKeys(roundID uint, userID, baseVersion string, keys secagg.PublicKeys) error {
+	return secAggCall(roundID, func(round *models.FLRound, st *secaggRound) error {
+		if baseVersion != round.BaseVersion {
+			return ErrRoundStale
+		}
+		if err := st.server.AddKeys(userID, keys); err != nil {
+			return err
+		}
+		if round.Status == models.FLRoundOpen {
+			setRoundStatus(round, models.FLRoundCollecting)
+		}
+		return nil
+	})
+}
+
+// SecAggSubmitShares records userID's encrypted shares, keyed by recipient
+func SecAggSubmitShares(roundID uint, userID string, ciphertexts map[string][]byte) error {
+	return secAggCall(roundID, func(round *models.FLRound, st *secaggRound) error {
+		return st.server.AddShares(userID, ciphertexts)
+	})
+}
+
+// SecAggSubmitMasked folds userID's masked update into the round's sum and records it with its
+// training metadata. The masked values are uniformly random to the server, so unlike plain
+// updates they are neither validated nor stored.
+func SecAggSubmitMasked(roundID uint, userID string, data []byte, meta FLUpdateMetadata) (*models.FLUpdate, error) {
+	var update *models.FLUpdate
+	err := secAggCall(roundID, func(round *models.FLRound, st *secaggRound) error {
+		if meta.BaseVersion != round.BaseVersion {
+			return ErrRoundStale
+		}
+		masked, err := secagg.UnmarshalVector(data)
+		if err != nil {
+			return err
+		}
+		if err := st.server.AddMaskedInput(userID, masked); err != nil {
+			return err
+		}
+		update, err = RecordRoundUpdate(round, userID, "", int64(len(data)), meta)
+		return err
+	})
+	return update, err
+}
+
+// SecAggSubmitUnmask records a survivor's shares for removing the masks
+func SecAggSubmitUnmask(roundID uint, userID string, resp *secagg.UnmaskResponse) error {
+	return secAggCall(roundID, func(round *models.FLRound, st *secaggRound) error {
+		return st.server.AddUnmask(userID, resp)
+	})
+}
+
+// secAggProgress summarizes a secure round for the admin status, or nil when it isn't running
+func secAggProgress(roundID uint) map[string]interface{} {
+	secaggMu.Lock()
+	defer secaggMu.Unlock()
+	st, ok := secaggRounds[roundID]
+	if !ok {
+		return nil
+	}
+	progress := map[string]interface{}{
+		"phase":         st.server.Phase(),
+		"joined":        st.server.Joined(),
+		"pending":       len(st.server.Pending()),
+		"phase_started": st.phaseStarted,
+	}
+	if err := st.server.Err(); err != nil {
+		progress["error"] = err.Error()
+	}
+	return progress
+}
+
+// secureSum advances a secure round and, once every survivor answered the unmask phase or it
+// timed out, removes the masks. It returns the mean of the survivors' updates, or nil while
+// the protocol is still running.
+func secureSum(round *models.FLRound) (tensors.Weights, int, error) {
+	secaggMu.Lock()
+	defer secaggMu.Unlock()
+
+	st, ok := secaggRounds[round.ID]
+	if !ok {
+		if round.Status == models.FLRoundOpen {
+			return nil, 0, nil
+		}
+		return nil, 0, errors.New("secure aggregation state lost")
+	}
+	s := st.server
+	if s.Phase() != secagg.PhaseUnmask {
+		// survivors get a full phase to answer, so the unmask phase never finishes as it opens
+		st.advance(round)
+		return nil, 0, s.Err()
+	}
+	if len(s.Pending()) > 0 && time.Since(st.phaseStarted) < SecAggPhaseTimeout {
+		return nil, 0, nil
+	}
+
+	sum, err := s.Finish()
+	if err != nil {
+		return nil, 0, err
+	}
+	n := len(s.Survivors())
+	values := secagg.Decode(sum)
+	mean := tensors.Weights{}
+	offset := 0
+	for _, t := range st.layout {
+		tensor := &tensors.Tensor{Shape: t.Shape}
+		tensor.Data = make([]float32, tensor.NumElements())
+		for i := range tensor.Data {
+			tensor.Data[i] = float32(values[offset+i] / float64(n))
+		}
+		offset += len(tensor.Data)
+		mean[t.Name] = tensor
+	}
+	return mean, n, nil
+}
+
+// aggregateSecureRound advances a secure round and publishes the mean of its updates once the
+// protocol has removed the masks
+func aggregateSecureRound(m *FLModel, round *models.FLRound) {
+	mean, n, err := secureSum(round)
+	if err != nil {
+		recordRun(m.Name, AggregationRun{RoundID: round.ID, Error: err.Error()})
+		closeRound(round, models.FLRoundRejected, "secure aggregation failed: "+err.Error())
+		return
+	}
+	if mean == nil {
+		return
+	}
+	setRoundStatus(round, models.FLRoundAggregating)
+	log.Printf("Securely aggregated %d %s updates for FL round %d", n, m.Name, round.ID)
+
+	newGlobalModelName := fmt.Sprintf("%s_%d.onnx", m.Name, time.Now().Unix())
+	outputPath := filepath.Join(AggregatedModelsDir, newGlobalModelName)
+	base, err := os.ReadFile(roundBasePath(round))
+	if err == nil {
+		err = writeAggregate(base, mean, outputPath)
+	}
+	if err != nil {
+		log.Printf("Error aggregating FL round %d: %v", round.ID, err)
+		recordRun(m.Name, AggregationRun{RoundID: round.ID, Error: err.Error()})
+		closeRound(round, models.FLRoundRejected, "aggregation failed: "+err.Error())
+		return
+	}
+	publishAggregate(m, round, nil, newGlobalModelName, outputPath)
+}
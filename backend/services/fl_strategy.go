@@ -0,0 +1,158 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"chithram/tensors"
+)
+
+// Aggregation strategies. FedAvg is the sample-weighted mean; the others tolerate a minority of
+// buggy or malicious updates.
+const (
+	StrategyFedAvg      = "fedavg"
+	StrategyMedian      = "median"
+	StrategyTrimmedMean = "trimmed_mean"
+	StrategyKrum        = "krum"
+	StrategyMultiKrum   = "multi_krum"
+)
+
+// AggregationConfig selects how a model's updates are combined
+type AggregationConfig struct {
+	Strategy   string  `json:"strategy"`
+	TrimRatio  float64 `json:"trim_ratio,omitempty"`   // trimmed_mean: fraction dropped from each end
+	ByzantineF int     `json:"byzantine_f,omitempty"`  // krum, multi_krum: faulty updates tolerated
+	MultiKrumM int     `json:"multi_krum_m,omitempty"` // multi_krum: updates averaged, 0 = n - f
+}
+
+// DefaultAggregation applies to FL models that don't configure their own. Configured with
+// FL_AGGREGATION_STRATEGY, FL_TRIM_RATIO, FL_BYZANTINE_F and FL_MULTI_KRUM_M.
+var DefaultAggregation = AggregationConfig{Strategy: StrategyFedAvg}
+
+// Validate checks the strategy name and its parameters
+func (a AggregationConfig) Validate() error {
+	switch a.Strategy {
+	case StrategyFedAvg, StrategyMedian:
+	case StrategyTrimmedMean:
+		if a.TrimRatio <= 0 || a.TrimRatio >= 0.5 {
+			return fmt.Errorf("trim_ratio must be in (0, 0.5), got %g", a.TrimRatio)
+		}
+	case StrategyKrum, StrategyMultiKrum:
+		if a.ByzantineF < 1 {
+			return fmt.Errorf("byzantine_f must be at least 1, got %d", a.ByzantineF)
+		}
+		if a.MultiKrumM < 0 {
+			return fmt.Errorf("multi_krum_m must not be negative, got %d", a.MultiKrumM)
+		}
+	default:
+		return fmt.Errorf("unknown aggregation strategy %q", a.Strategy)
+	}
+	return nil
+}
+
+// MinUpdates is the fewest updates the strategy can aggregate meaningfully
+func (a AggregationConfig) MinUpdates() int {
+	switch a.Strategy {
+	case StrategyKrum, StrategyMultiKrum:
+		return 2*a.ByzantineF + 3
+	case StrategyTrimmedMean:
+		// Keep at least one update after trimming from both ends
+		n := 1
+		for n-2*int(float64(n)*a.TrimRatio) < 1 || int(float64(n)*a.TrimRatio) < 1 {
+			n++
+		}
+		return n
+	case StrategyMedian:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// String describes the strategy with its parameters, e.g. "trimmed_mean(trim_ratio=0.1)"
+func (a AggregationConfig) String() string {
+	switch a.Strategy {
+	case StrategyTrimmedMean:
+		return fmt.Sprintf("%s(trim_ratio=%g)", a.Strategy, a.TrimRatio)
+	case StrategyKrum:
+		return fmt.Sprintf("%s(f=%d)", a.Strategy, a.ByzantineF)
+	case StrategyMultiKrum:
+		return fmt.Sprintf("%s(f=%d, m=%d)", a.Strategy, a.ByzantineF, a.MultiKrumM)
+	default:
+		return a.Strategy
+	}
+}
+
+// Aggregate combines updates with this strategy. weights are the FedAvg sample weights, in update
+// order. For Krum and multi-Krum it also returns the indices of the updates that were averaged.
+func (a AggregationConfig) Aggregate(updates []tensors.Weights, weights []float64) (tensors.Weights, []int, error) {
+	switch a.Strategy {
+	case StrategyFedAvg:
+		result, err := tensors.FedAvg(updates, weights)
+		return result, nil, err
+	case StrategyMedian:
+		result, err := tensors.Median(updates)
+		return result, nil, err
+	case StrategyTrimmedMean:
+		result, err := tensors.TrimmedMean(updates, a.TrimRatio)
+		return result, nil, err
+	case StrategyKrum, StrategyMultiKrum:
+		m := 1
+		if a.Strategy == StrategyMultiKrum {
+			m = a.MultiKrumM
+			if m == 0 {
+				m = len(updates) - a.ByzantineF
+			}
+		}
+		selected, err := tensors.Krum(updates, a.ByzantineF, m)
+		if err != nil {
+			return nil, nil, err
+		}
+		chosen := make([]tensors.Weights, len(selected))
+		var chosenWeights []float64
+		for i, idx := range selected {
+			chosen[i] = updates[idx]
+			if weights != nil {
+				chosenWeights = append(chosenWeights, weights[idx])
+			}
+		}
+		result, err := tensors.FedAvg(chosen, chosenWeights)
+		return result, selected, err
+	}
+	return nil, nil, fmt.Errorf("unknown aggregation strategy %q", a.Strategy)
+}
+
+// parseAggregation reads a strategy stored with a round, falling back to FedAvg
+func parseAggregation(raw string) AggregationConfig {
+	cfg := AggregationConfig{Strategy: StrategyFedAvg}
+	if raw != "" {
+		json.Unmarshal([]byte(raw), &cfg)
+	}
+	return cfg
+}
+
+// initAggregation reads the default strategy from the environment
+func initAggregation() {
+	cfg := AggregationConfig{Strategy: StrategyFedAvg, TrimRatio: 0.1, ByzantineF: 1}
+	if env := os.Getenv("FL_AGGREGATION_STRATEGY"); env != "" {
+		cfg.Strategy = env
+	}
+	if env := os.Getenv("FL_TRIM_RATIO"); env != "" {
+		cfg.TrimRatio, _ = strconv.ParseFloat(env, 64)
+	}
+	if env := os.Getenv("FL_BYZANTINE_F"); env != "" {
+		cfg.ByzantineF, _ = strconv.Atoi(env)
+	}
+	if env := os.Getenv("FL_MULTI_KRUM_M"); env != "" {
+		cfg.MultiKrumM, _ = strconv.Atoi(env)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Printf("Warning: invalid aggregation config (%v), using %s", err, StrategyFedAvg)
+		cfg = AggregationConfig{Strategy: StrategyFedAvg}
+	}
+	DefaultAggregation = cfg
+	log.Printf("FL aggregation strategy: %s", cfg)
+}
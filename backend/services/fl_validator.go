@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"chithram/database"
+	"chithram/models"
+	"chithram/tensors"
+)
+
+// Reasons an FL update is quarantined
+const (
+	RejectTooLarge         = "too_large"
+	RejectFormatNotAllowed = "format_not_allowed"
+	RejectNotACheckpoint   = "not_a_checkpoint"
+	RejectTensorMismatch   = "tensor_mismatch"
+	RejectNonFinite        = "non_finite"
+	RejectNormExceeded     = "norm_exceeded"
+)
+
+var (
+	// QuarantineDir holds rejected updates for inspection
+	QuarantineDir = "./fl_updates/quarantine"
+
+	// MaxUpdateBytes is the largest accepted update upload. Configured with FL_MAX_UPDATE_BYTES.
+	MaxUpdateBytes int64 = 64 << 20
+
+	// AllowedUpdateFormats are the accepted update file extensions. Configured with
+	// FL_UPDATE_FORMATS (comma-separated); only formats the aggregator reads can be enabled.
+	AllowedUpdateFormats = []string{".safetensors"}
+
+	// MaxUpdateNormRatio bounds ||update - base|| / ||base||. Configured with FL_MAX_UPDATE_NORM_RATIO.
+	MaxUpdateNormRatio = 1.0
+
+	// ErrValidatorUnavailable is returned when the validator itself could not run
+	ErrValidatorUnavailable = errors.New("update validator unavailable")
+
+	// supportedUpdateFormats are the weight formats the aggregator can load
+	supportedUpdateFormats = []string{".safetensors"}
+)
+
+// UpdateRejection explains why an update was quarantined
+type UpdateRejection struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+func (r *UpdateRejection) Error() string {
+	return r.Code + ": " + r.Reason
+}
+
+// initFLValidator reads the validation limits from the environment
+func initFLValidator() {
+	os.MkdirAll(QuarantineDir, 0755)
+
+	if env := os.Getenv("FL_MAX_UPDATE_BYTES"); env != "" {
+		if n, err := strconv.ParseInt(env, 10, 64); err == nil && n > 0 {
+			MaxUpdateBytes = n
+		} else {
+			log.Printf("Warning: invalid FL_MAX_UPDATE_BYTES %q, using %d", env, MaxUpdateBytes)
+		}
+	}
+	if env := os.Getenv("FL_MAX_UPDATE_NORM_RATIO"); env != "" {
+		if f, err := strconv.ParseFloat(env, 64); err == nil && f > 0 {
+			MaxUpdateNormRatio = f
+		} else {
+			log.Printf("Warning: invalid FL_MAX_UPDATE_NORM_RATIO %q, using %g", env, MaxUpdateNormRatio)
+		}
+	}
+	if env := os.Getenv("FL_UPDATE_FORMATS"); env != "" {
+		var formats []string
+		for _, f := range strings.Split(env, ",") {
+			f = strings.ToLower(strings.TrimSpace(f))
+			if !strings.HasPrefix(f, ".") {
+				f = "." + f
+			}
+			if containsString(supportedUpdateFormats, f) {
+				formats = append(formats, f)
+			} else {
+				log.Printf("Warning: FL_UPDATE_FORMATS entry %q is not an aggregatable format, ignoring", f)
+			}
+		}
+		if len(formats) > 0 {
+			AllowedUpdateFormats = formats
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckUpdateFormat rejects file names outside AllowedUpdateFormats
+func CheckUpdateFormat(filename string) *UpdateRejection {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !containsString(AllowedUpdateFormats, ext) {
+		return &UpdateRejection{RejectFormatNotAllowed, fmt.Sprintf("%q is not an accepted update format (allowed: %s)", ext, strings.Join(AllowedUpdateFormats, ", "))}
+	}
+	return nil
+}
+
+// loadBaseWeights reads the float initializers of the model a round's updates are trained from
+func loadBaseWeights(path string) (tensors.Weights, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return tensors.ReadONNXInitializers(data)
+}
+
+// ValidateUpdate checks a stored update against its round's base model: a safetensors file whose tensors
+// are initializers of the base model with the same shapes, with finite values and a bounded
+// distance from the base weights. Initializers the client didn't train may be left out.
+// It returns a rejection for a bad update, or ErrValidatorUnavailable if the check couldn't run.
+func ValidateUpdate(round *models.FLRound, path string) (*UpdateRejection, error) {
+	update, err := tensors.ReadSafetensorsFile(path)
+	if errors.Is(err, tensors.ErrInvalidSafetensors) {
+		return &UpdateRejection{RejectNotACheckpoint, err.Error()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(update) == 0 {
+		return &UpdateRejection{RejectNotACheckpoint, "update contains no tensors"}, nil
+	}
+
+	basePath := roundBasePath(round)
+	base, err := loadBaseWeights(basePath)
+	if err != nil {
+		log.Printf("Update validator could not load base model %s: %v", basePath, err)
+		return nil, fmt.Errorf("%w: %v", ErrValidatorUnavailable, err)
+	}
+
+	var unexpected []string
+	for _, name := range update.Names() {
+		ref, ok := base[name]
+		if !ok {
+			unexpected = append(unexpected, name)
+			continue
+		}
+		if !update[name].SameShape(ref) {
+			return &UpdateRejection{RejectTensorMismatch, fmt.Sprintf("%s has shape %v, expected %v", name, update[name].Shape, ref.Shape)}, nil
+		}
+	}
+	if len(unexpected) > 0 {
+		if len(unexpected) > 3 {
+			unexpected = unexpected[:3]
+		}
+		return &UpdateRejection{RejectTensorMismatch, fmt.Sprintf("update has tensors the base model doesn't (e.g. %s)", strings.Join(unexpected, ", "))}, nil
+	}
+
+	if name := update.NonFinite(); name != "" {
+		return &UpdateRejection{RejectNonFinite, name + " contains NaN or Inf values"}, nil
+	}
+	if ratio := update.DistanceRatio(base); ratio > MaxUpdateNormRatio {
+		return &UpdateRejection{RejectNormExceeded, fmt.Sprintf("update norm is %.4f of the base norm, limit is %g", ratio, MaxUpdateNormRatio)}, nil
+	}
+	return nil, nil
+}
+
+// QuarantineUpdate moves a rejected update out of its round into QuarantineDir and records why.
+// path may be empty when the upload was refused before it was stored.
+func QuarantineUpdate(round *models.FLRound, userID, path string, size int64, meta FLUpdateMetadata, rej *UpdateRejection) (*models.FLUpdate, error) {
+	update := models.FLUpdate{
+		RoundID:         round.ID,
+		UserID:          userID,
+		BaseVersion:     meta.BaseVersion,
+		Size:            size,
+		CreatedAt:       time.Now(),
+		SampleCount:     meta.SampleCount,
+		Epochs:          meta.Epochs,
+		LocalLoss:       meta.LocalLoss,
+		LocalAccuracy:   meta.LocalAccuracy,
+		AppVersion:      meta.AppVersion,
+		TrainingSeconds: meta.TrainingSeconds,
+		Status:          models.FLUpdateQuarantined,
+		RejectCode:      rej.Code,
+		RejectReason:    rej.Reason,
+	}
+
+	if path != "" {
+		dir := filepath.Join(QuarantineDir, fmt.Sprintf("round_%d", round.ID))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		dest := filepath.Join(dir, filepath.Base(path))
+		if err := os.Rename(path, dest); err != nil {
+			return nil, err
+		}
+		update.FilePath = dest
+	}
+
+	if err := database.DB.Create(&update).Error; err != nil {
+		return nil, err
+	}
+	log.Printf("Quarantined FL update %d for round %d: %s", update.ID, round.ID, rej.Error())
+	return &update, nil
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+var (
+	// KeyLogSigningKeyPath is where the server's ed25519 key-log signing seed is kept
+	// when KEYLOG_SIGNING_KEY (base64 seed) is not set
+	KeyLogSigningKeyPath = "./keys/keylog_ed25519.seed"
+
+	keyLogPrivateKey ed25519.PrivateKey
+	keyLogMu         sync.Mutex
+)
+
+// InitKeyLog loads (or creates) the server key used to sign the public key log
+func InitKeyLog() {
+	seed, err := loadKeyLogSeed()
+	if err != nil {
+		log.Fatalf("Failed to load key log signing key: %v", err)
+	}
+	keyLogPrivateKey = ed25519.NewKeyFromSeed(seed)
+	log.Printf("Key log signing key: %s", KeyLogPublicKey())
+}
+
+func loadKeyLogSeed() ([]byte, error) {
+	if env := os.Getenv("KEYLOG_SIGNING_KEY"); env != "" {
+		seed, err := base64.StdEncoding.DecodeString(env)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("KEYLOG_SIGNING_KEY must be a base64 encoded 32 byte seed")
+		}
+		return seed, nil
+	}
+
+	if data, err := os.ReadFile(KeyLogSigningKeyPath); err == nil {
+		seed, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%s does not contain a valid seed", KeyLogSigningKeyPath)
+		}
+		return seed, nil
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(KeyLogSigningKeyPath), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(KeyLogSigningKeyPath, []byte(base64.StdEncoding.EncodeToString(seed)), 0600); err != nil {
+		return nil, err
+	}
+	log.Printf("Generated new key log signing key at %s", KeyLogSigningKeyPath)
+	return seed, nil
+}
+
+// KeyLogPublicKey returns the base64 ed25519 public key clients use to verify key log signatures
+func KeyLogPublicKey() string {
+	return base64.StdEncoding.EncodeToString(keyLogPrivateKey.Public().(ed25519.PublicKey))
+}
+
+// Fingerprint returns the hex sha256 of a base64 public key's raw bytes
+// (or of the string itself if it isn't valid base64)
+func Fingerprint(publicKey string) string {
+	raw, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		raw = []byte(publicKey)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyLogEntryHash computes the chained hash of a key log entry:
+// sha256("seq|user_id|key_version|fingerprint|action|created_at_unix_nano|prev_hash")
+func KeyLogEntryHash(e *models.KeyLogEntry) string {
+	payload := fmt.Sprintf("%d|%s|%d|%s|%s|%d|%s", e.Seq, e.UserID, e.KeyVersion, e.Fingerprint, e.Action, e.CreatedAt.UnixNano(), e.PrevHash)
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// AppendKeyLog chains, signs and stores a new key log entry inside tx
+func AppendKeyLog(tx *gorm.DB, userID string, keyVersion int, fingerprint, action string) (*models.KeyLogEntry, error) {
+	keyLogMu.Lock()
+	defer keyLogMu.Unlock()
+
+	entry := models.KeyLogEntry{
+		Seq:         1,
+		UserID:      userID,
+		KeyVersion:  keyVersion,
+		Fingerprint: fingerprint,
+		Action:      action,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	var last models.KeyLogEntry
+	if err := tx.Order("seq DESC").First(&last).Error; err == nil {
+		entry.Seq = last.Seq + 1
+		entry.PrevHash = last.EntryHash
+	}
+
+	entry.EntryHash = KeyLogEntryHash(&entry)
+	hash, _ := hex.DecodeString(entry.EntryHash)
+	entry.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(keyLogPrivateKey, hash))
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// SignWithServerKey signs data with the server key, so clients can verify server-issued
+// documents such as deletion receipts with KeyLogPublicKey
+func SignWithServerKey(data []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(keyLogPrivateKey, data))
+}
+
+// CurrentUserKey returns the newest key version of a user
+func CurrentUserKey(userID string) (*models.UserKey, error) {
+	var key models.UserKey
+	if err := database.DB.Where("user_id = ?", userID).Order("version DESC").First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// AddUserKey records the user's current keypair as a new key version, retires the previous one
+// and appends the change to the key log, inside tx
+func AddUserKey(tx *gorm.DB, user *models.User, action string) (*models.UserKey, error) {
+	now := time.Now()
+	key := models.UserKey{
+		UserID:              user.Username,
+		Version:             1,
+		PublicKey:           user.PublicKey,
+		Fingerprint:         Fingerprint(user.PublicKey),
+		CreatedAt:           now,
+		EncryptedPrivateKey: user.EncryptedPrivateKey,
+		PrivateKeyNonce:     user.PrivateKeyNonce,
+	}
+
+	var latest models.UserKey
+	if err := tx.Where("user_id = ?", user.Username).Order("version DESC").First(&latest).Error; err == nil {
+		key.Version = latest.Version + 1
+		if err := tx.Model(&latest).Update("retired_at", now).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Create(&key).Error; err != nil {
+		return nil, err
+	}
+	if _, err := AppendKeyLog(tx, user.Username, key.Version, key.Fingerprint, action); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// BackfillUserKeys gives every user that predates the key history a version 1 entry
+func BackfillUserKeys() {
+	var users []models.User
+	database.DB.Where("username NOT IN (?)", database.DB.Model(&models.UserKey{}).Select("user_id")).Find(&users)
+
+	for i := range users {
+		u := &users[i]
+		if u.PublicKey == "" {
+			continue
+		}
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			_, err := AddUserKey(tx, u, models.KeyLogActionAdded)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to backfill key history for %s: %v", u.Username, err)
+		}
+	}
+	if len(users) > 0 {
+		log.Printf("Backfilled key history for %d users", len(users))
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// mailTemplate is a subject and a plain-text body, both rendered with text/template
+type mailTemplate struct {
+	subject string
+	body    string
+}
+
+// Mail template names
+const (
+	MailVerifyEmail     = "verify_email"
+	MailNewDeviceLogin  = "new_device_login"
+	MailPasswordChanged = "password_changed"
+	MailShareReceived   = "share_received"
+)
+
+var mailTemplates = map[string]mailTemplate{
+	MailVerifyEmail: {
+		subject: "Confirm your email address",
+		body: `Hi {{.Username}},
+
+Please confirm this email address for your Chithram account by opening the link below:
+
+{{.Link}}
+
+The link expires in {{.ExpiresIn}}. If you didn't create an account, you can ignore this message.
+`,
+	},
+	MailNewDeviceLogin: {
+		subject: "New sign-in to your account",
+		body: `Hi {{.Username}},
+
+Your Chithram account was just signed in to from a device we haven't seen before:
+
+  Device: {{.UserAgent}}
+  IP address: {{.IP}}
+  Time: {{.Time}}
+
+If this was you, there's nothing to do. If not, change your password right away.
+`,
+	},
+	MailPasswordChanged: {
+		subject: "Your password was changed",
+		body: `Hi {{.Username}},
+
+The password for your Chithram account was changed at {{.Time}}. All other sessions have been signed out.
+
+If you didn't do this, contact your server administrator immediately.
+`,
+	},
+	MailShareReceived: {
+		subject: "{{.Sender}} shared {{.Count}} photo{{if ne .Count 1}}s{{end}} with you",
+		body: `Hi {{.Username}},
+
+{{.Sender}} shared {{.Count}} photo{{if ne .Count 1}}s{{end}} with you.{{if .Caption}}
+
+  "{{.Caption}}"{{end}}
+
+Open Chithram to accept or decline.
+`,
+	},
+}
+
+// RenderMail renders a named template with data into a subject and body
+func RenderMail(name string, data map[string]interface{}) (string, string, error) {
+	tmpl, ok := mailTemplates[name]
+	if !ok {
+		return "", "", fmt.Errorf("unknown mail template %q", name)
+	}
+
+	render := func(text string) (string, error) {
+		t, err := template.New(name).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	subject, err := render(tmpl.subject)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := render(tmpl.body)
+	if err != nil {
+		return "", "", err
+	}
+	return subject, body, nil
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+// Mail is one rendered email
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers rendered emails
+type Mailer interface {
+	Send(m Mail) error
+}
+
+// SMTPMailer sends through an SMTP relay, with PLAIN auth when a username is set
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers one message over SMTP
+func (s *SMTPMailer) Send(m Mail) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	header := strings.NewReplacer("\r", "", "\n", "")
+	msg := "From: " + header.Replace(s.From) + "\r\n" +
+		"To: " + header.Replace(m.To) + "\r\n" +
+		"Subject: " + header.Replace(m.Subject) + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + strings.ReplaceAll(m.Body, "\n", "\r\n")
+	return smtp.SendMail(net.JoinHostPort(s.Host, s.Port), auth, s.From, []string{m.To}, []byte(msg))
+}
+
+// FileMailer is the development sink: it writes each message to Dir as a .eml file,
+// or just logs it when Dir is empty
+type FileMailer struct {
+	Dir string
+}
+
+// Send writes or logs one message
+func (f *FileMailer) Send(m Mail) error {
+	if f.Dir == "" {
+		log.Printf("Mail to %s: %s\n%s", m.To, m.Subject, m.Body)
+		return nil
+	}
+	if err := os.MkdirAll(f.Dir, 0700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(m.To))
+	content := "To: " + m.To + "\nSubject: " + m.Subject + "\n\n" + m.Body
+	return os.WriteFile(filepath.Join(f.Dir, name), []byte(content), 0600)
+}
+
+var (
+	// ActiveMailer delivers the outbox. Chosen with MAIL_DRIVER: smtp, file or log (default).
+	ActiveMailer Mailer = &FileMailer{}
+
+	// PublicBaseURL prefixes links in emails. Configured with PUBLIC_BASE_URL.
+	PublicBaseURL = "http://localhost:8080"
+
+	// MaxMailAttempts is how often a message is tried before it is marked failed
+	MaxMailAttempts = 8
+
+	// MailPollInterval is how often the outbox worker looks for due messages
+	MailPollInterval = 30 * time.Second
+)
+
+// InitMailer configures the mailer from the environment and starts the outbox worker
+func InitMailer() {
+	if env := os.Getenv("PUBLIC_BASE_URL"); env != "" {
+		PublicBaseURL = strings.TrimSuffix(env, "/")
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		ActiveMailer = &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "./mail"
+		}
+		ActiveMailer = &FileMailer{Dir: dir}
+	case "", "log":
+		ActiveMailer = &FileMailer{}
+	default:
+		log.Printf("Warning: unknown MAIL_DRIVER %q, logging mail instead", driver)
+	}
+
+	go func() {
+		for {
+			DeliverOutbox()
+			time.Sleep(MailPollInterval)
+		}
+	}()
+}
+
+// QueueMail renders a template and stores it in the outbox for the worker to send
+func QueueMail(to, template string, data map[string]interface{}) error {
+	subject, body, err := RenderMail(template, data)
+	if err != nil {
+		return err
+	}
+	msg := models.OutboxMessage{
+		To:            to,
+		Template:      template,
+		Subject:       subject,
+		Body:          body,
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+	return database.DB.Create(&msg).Error
+}
+
+// DeliverOutbox sends every due message, backing off exponentially on failure
+func DeliverOutbox() {
+	var due []models.OutboxMessage
+	database.DB.Where("status = ? AND next_attempt_at <= ?", models.OutboxStatusPending, time.Now()).
+		Order("next_attempt_at ASC").
+		Limit(100).
+		Find(&due)
+
+	for i := range due {
+		msg := &due[i]
+		err := ActiveMailer.Send(Mail{To: msg.To, Subject: msg.Subject, Body: msg.Body})
+		msg.Attempts++
+		now := time.Now()
+		if err == nil {
+			msg.Status = models.OutboxStatusSent
+			msg.SentAt = &now
+			msg.LastError = ""
+		} else {
+			msg.LastError = err.Error()
+			if msg.Attempts >= MaxMailAttempts {
+				msg.Status = models.OutboxStatusFailed
+				log.Printf("Giving up on mail %d to %s after %d attempts: %v", msg.ID, msg.To, msg.Attempts, err)
+			} else {
+				msg.NextAttemptAt = now.Add(time.Minute << (msg.Attempts - 1))
+			}
+		}
+		database.DB.Save(msg)
+	}
+}
+
+// NotifyUser queues a notification to a user's email, if they have verified it
+func NotifyUser(username, template string, data map[string]interface{}) {
+	var user models.User
+	if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		return
+	}
+	if user.EmailVerifiedAt == nil || user.Email == "" {
+		return
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["Username"] = user.Username
+	if err := QueueMail(user.Email, template, data); err != nil {
+		log.Printf("Failed to queue %s mail for %s: %v", template, username, err)
+	}
+}
@@ -113,6 +113,26 @@ func ListFiles(prefix string) ([]string, error) {
 	return files, nil
 }
 
+// ListObjectInfos returns key, size and modification time of every object under a raw
+// prefix ("" lists the whole bucket)
+func ListObjectInfos(prefix string) ([]minio.ObjectInfo, error) {
+	ctx := context.Background()
+	var objects []minio.ObjectInfo
+
+	objectCh := MinioClient.ListObjects(ctx, BucketName, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+
+	for object := range objectCh {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
 // GetPresignedURL generates a presigned GET URL using the public client so the
 // hostname in the signature matches what clients will actually connect to.
 func GetPresignedURL(objectName string, expiry time.Duration) (string, error) {
@@ -150,3 +170,29 @@ func DownloadFromMinio(objectName string) (*minio.Object, error) {
 	ctx := context.Background()
 	return MinioClient.GetObject(ctx, BucketName, objectName, minio.GetObjectOptions{})
 }
+
+// CopyObject performs a server-side copy of an object within the bucket, so
+// ciphertext can be reused without passing through the backend or the client
+func CopyObject(srcObjectName, dstObjectName string) error {
+	ctx := context.Background()
+	src := minio.CopySrcOptions{
+		Bucket: BucketName,
+		Object: srcObjectName,
+	}
+	dst := minio.CopyDestOptions{
+		Bucket: BucketName,
+		Object: dstObjectName,
+	}
+	_, err := MinioClient.CopyObject(ctx, dst, src)
+	return err
+}
+
+// StatObjectSize returns the size in bytes of an object in the bucket
+func StatObjectSize(objectName string) (int64, error) {
+	ctx := context.Background()
+	info, err := MinioClient.StatObject(ctx, BucketName, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"chithram/database"
+	"chithram/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ModelRegistryDir holds one immutable file per registered model version
+	ModelRegistryDir = "./model_registry"
+
+	// ServedModelsDir holds the stable version of each model, as downloaded by clients
+	ServedModelsDir = "./models"
+
+	// PromotionMargin is how much a candidate's accuracy must exceed the stable version's before it
+	// is promoted, for FL models that don't set their own. Configured with MODEL_PROMOTION_MARGIN.
+	PromotionMargin = 0.0
+
+	// ErrUnknownModelVersion is returned for a version that isn't in the registry
+	ErrUnknownModelVersion = errors.New("unknown model version")
+
+	// ErrModelChecksum is returned when a registry file no longer matches its recorded sha256
+	ErrModelChecksum = errors.New("model file does not match its checksum")
+
+	registryMu sync.Mutex
+)
+
+// InitModelRegistry reads the promotion margin and registers served models that predate the
+// registry as the stable version of their model
+func InitModelRegistry() {
+	os.MkdirAll(ModelRegistryDir, 0755)
+
+	if env := os.Getenv("MODEL_PROMOTION_MARGIN"); env != "" {
+		if f, err := strconv.ParseFloat(env, 64); err == nil && f >= 0 {
+			PromotionMargin = f
+		} else {
+			log.Printf("Warning: invalid MODEL_PROMOTION_MARGIN %q, using %g", env, PromotionMargin)
+		}
+	}
+
+	files, _ := os.ReadDir(ServedModelsDir)
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".onnx" {
+			continue
+		}
+		name := f.Name()[:len(f.Name())-5]
+		if _, err := ChannelVersion(name, models.ModelChannelStable); err == nil {
+			continue
+		}
+
+		var meta models.ModelMetadata
+		version := ""
+		if database.DB.Where("name = ?", name).First(&meta).Error == nil {
+			version = meta.Version
+		}
+		v, err := RegisterModelVersion(models.ModelVersion{ModelName: name, Version: version, Source: models.ModelSourceSeed}, filepath.Join(ServedModelsDir, f.Name()))
+		if err == nil {
+			err = setChannel(name, models.ModelChannelStable, v.Version, AuditActorSystem)
+		}
+		if err != nil {
+			log.Printf("Warning: could not register served model %s: %v", f.Name(), err)
+			continue
+		}
+		log.Printf("Registered served model %s as stable version %s", name, v.Version)
+	}
+}
+
+// ServedModelPath is where the stable version of a model is installed for download
+func ServedModelPath(name string) string {
+	return filepath.Join(ServedModelsDir, name+".onnx")
+}
+
+// fileSHA256 returns the hex sha256 and size of a file
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// RegisterModelVersion copies a model file into the registry as a new immutable version described
+// by v: its model name, source and lineage. An empty version is assigned from the current time.
+// Registering a file whose checksum is already in the registry returns the existing version instead.
+func RegisterModelVersion(v models.ModelVersion, srcPath string) (*models.ModelVersion, error) {
+	name, version := v.ModelName, v.Version
+	sum, size, err := fileSHA256(srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.ModelVersion
+	if database.DB.Where("model_name = ? AND sha256 = ?", name, sum).First(&existing).Error == nil {
+		return &existing, nil
+	}
+
+	if version == "" {
+		version = time.Now().Format("20060102150405")
+	}
+	// Versions are unique per model; disambiguate two registrations within the same second
+	base := version
+	for i := 2; ; i++ {
+		var count int64
+		database.DB.Model(&models.ModelVersion{}).Where("model_name = ? AND version = ?", name, version).Count(&count)
+		if count == 0 {
+			break
+		}
+		version = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	dir := filepath.Join(ModelRegistryDir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	dest := filepath.Join(dir, version+".onnx")
+	if err := copyNewFile(srcPath, dest); err != nil {
+		return nil, err
+	}
+
+	v.ID = 0
+	v.Version = version
+	v.SHA256 = sum
+	v.Size = size
+	v.FilePath = dest
+	v.CreatedAt = time.Now()
+	if err := database.DB.Create(&v).Error; err != nil {
+		os.Remove(dest)
+		return nil, err
+	}
+	return &v, nil
+}
+
+// copyNewFile copies src to dest, failing if dest exists, and makes the copy read-only
+func copyNewFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0444)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	return out.Close()
+}
+
+// GetModelVersion loads a registered version
+func GetModelVersion(name, version string) (*models.ModelVersion, error) {
+	var v models.ModelVersion
+	err := database.DB.Where("model_name = ? AND version = ?", name, version).First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnknownModelVersion
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ChannelVersion returns the version a channel of a model points at
+func ChannelVersion(name, channel string) (*models.ModelVersion, error) {
+	var ch models.ModelChannel
+	err := database.DB.Where("model_name = ? AND channel = ?", name, channel).First(&ch).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnknownModelVersion
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetModelVersion(name, ch.Version)
+}
+
+// setChannel points a channel of a model at a version
+func setChannel(name, channel, version, actor string) error {
+	return database.DB.Save(&models.ModelChannel{
+		ModelName: name,
+		Channel:   channel,
+		Version:   version,
+		UpdatedBy: actor,
+		UpdatedAt: time.Now(),
+	}).Error
+}
+
+// SetCandidate publishes a version on the candidate channel
+func SetCandidate(v *models.ModelVersion) error {
+	return setChannel(v.ModelName, models.ModelChannelCandidate, v.Version, AuditActorSystem)
+}
+
+// VersionMetric returns the most recent evaluation of a version, or nil if it was never evaluated
+func VersionMetric(name, version string) *models.ModelMetric {
+	var m models.ModelMetric
+	if err := database.DB.Where("model_name = ? AND version = ?", name, version).Order("created_at DESC").First(&m).Error; err != nil {
+		return nil
+	}
+	return &m
+}
+
+// installStable verifies a version's checksum, installs it as the served model and points the
+// stable channel at it. The caller holds registryMu.
+func installStable(v *models.ModelVersion, actor string) error {
+	sum, size, err := fileSHA256(v.FilePath)
+	if err != nil {
+		return err
+	}
+	if sum != v.SHA256 {
+		return fmt.Errorf("%w: %s %s", ErrModelChecksum, v.ModelName, v.Version)
+	}
+
+	// Write beside the served file and rename over it, so clients never download a partial model
+	served := ServedModelPath(v.ModelName)
+	tmp := served + ".tmp"
+	os.Remove(tmp)
+	if err := copyNewFile(v.FilePath, tmp); err != nil {
+		return err
+	}
+	os.Chmod(tmp, 0644)
+	if err := os.Rename(tmp, served); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := setChannel(v.ModelName, models.ModelChannelStable, v.Version, actor); err != nil {
+		return err
+	}
+	meta := models.ModelMetadata{Name: v.ModelName, Version: v.Version, Size: size, UpdatedAt: time.Now()}
+	if err := database.DB.Save(&meta).Error; err != nil {
+		return err
+	}
+	log.Printf("Installed %s version %s as stable", v.ModelName, v.Version)
+	return nil
+}
+
+// PromoteCandidate makes v the stable version if its accuracy beats the stable version's by more
+// than margin. The comparison is only skipped when there is no stable version yet; a stable
+// version without metrics holds the candidate back. It returns why the version was held back
+// when it wasn't promoted.
+func PromoteCandidate(v *models.ModelVersion, margin float64) (bool, string, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	candidate := VersionMetric(v.ModelName, v.Version)
+	if candidate == nil {
+		return false, "candidate has no evaluation metrics", nil
+	}
+	stable, err := ChannelVersion(v.ModelName, models.ModelChannelStable)
+	if err != nil && !errors.Is(err, ErrUnknownModelVersion) {
+		return false, "", err
+	}
+	if stable != nil {
+		current := VersionMetric(v.ModelName, stable.Version)
+		if current == nil {
+			return false, fmt.Sprintf("stable %s has no evaluation metrics to compare against", stable.Version), nil
+		}
+		if candidate.Accuracy <= current.Accuracy+margin {
+			return false, fmt.Sprintf("accuracy %.4f does not beat stable %s (%.4f) by more than %g",
+				candidate.Accuracy, stable.Version, current.Accuracy, margin), nil
+		}
+	}
+
+	if err := installStable(v, AuditActorSystem); err != nil {
+		return false, "", err
+	}
+	details := map[string]interface{}{"version": v.Version, "accuracy": candidate.Accuracy, "margin": margin}
+	if stable != nil {
+		details["previous_version"] = stable.Version
+	}
+	if v.RoundID != nil {
+		details["round_id"] = *v.RoundID
+	}
+	RecordAudit(models.AuditEvent{
+		Event:      AuditModelPromoted,
+		ActorID:    AuditActorSystem,
+		TargetType: "model",
+		TargetID:   v.ModelName,
+	}, details)
+	return true, "", nil
+}
+
+// RollbackModel makes any registered version of a model stable again, skipping the promotion
+// gate. It returns the version that was stable before.
+func RollbackModel(name, version, actor string) (*models.ModelVersion, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	v, err := GetModelVersion(name, version)
+	if err != nil {
+		return nil, err
+	}
+	previous, _ := ChannelVersion(name, models.ModelChannelStable)
+	if err := installStable(v, actor); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// ModelVersionInfo is a registered version with its latest metrics and the channels serving it
+type ModelVersionInfo struct {
+	models.ModelVersion
+	Metrics  *models.ModelMetric `json:"metrics,omitempty"`
+	Channels []string            `json:"channels"`
+}
+
+// ListModelVersions returns every version of a model, newest first
+func ListModelVersions(name string) ([]ModelVersionInfo, error) {
+	var versions []models.ModelVersion
+	if err := database.DB.Where("model_name = ?", name).Order("id DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	var channels []models.ModelChannel
+	database.DB.Where("model_name = ?", name).Find(&channels)
+
+	out := make([]ModelVersionInfo, 0, len(versions))
+	for _, v := range versions {
+		info := ModelVersionInfo{ModelVersion: v, Metrics: VersionMetric(name, v.Version), Channels: []string{}}
+		for _, ch := range channels {
+			if ch.Version == v.Version {
+				info.Channels = append(info.Channels, ch.Channel)
+			}
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a fixed-window, in-memory limiter keyed by an arbitrary string (user, IP, ...)
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRateLimiter allows up to limit calls per key in every window
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// Allow records a call for key and reports whether it is within the limit
+func (r *RateLimiter) Allow(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	w, ok := r.windows[key]
+	if !ok || now.Sub(w.start) >= r.window {
+		// Drop expired windows now and then so the map doesn't grow forever
+		if len(r.windows) > 10000 {
+			for k, old := range r.windows {
+				if now.Sub(old.start) >= r.window {
+					delete(r.windows, k)
+				}
+			}
+		}
+		r.windows[key] = &rateWindow{start: now, count: 1}
+		return true
+	}
+
+	if w.count >= r.limit {
+		return false
+	}
+	w.count++
+	return true
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+var (
+	// ReconcileGrace is how old an orphan object or a dangling row must be before
+	// reconciliation acts on it, so in-flight uploads are left alone. Configured with RECONCILE_GRACE.
+	ReconcileGrace = 24 * time.Hour
+
+	// ReconcileInterval is how often the scheduled reconciliation runs (0 = disabled).
+	// Configured with RECONCILE_INTERVAL.
+	ReconcileInterval time.Duration
+
+	reconcileMu sync.Mutex
+)
+
+// ReconcileReport summarises one reconciliation pass
+type ReconcileReport struct {
+	DryRun              bool      `json:"dry_run"`
+	StartedAt           time.Time `json:"started_at"`
+	FinishedAt          time.Time `json:"finished_at"`
+	ObjectsScanned      int       `json:"objects_scanned"`
+	Orphans             []string  `json:"orphans"`               // objects with no row referencing them
+	OrphansDeleted      int       `json:"orphans_deleted"`       // orphans past the grace period removed from MinIO
+	OrphanBytes         int64     `json:"orphan_bytes"`          // size of all orphans found
+	MissingImages       []string  `json:"missing_images"`        // image IDs whose original is not in MinIO
+	ImagesMarked        int       `json:"images_marked"`         // images flagged objects_missing for re-upload
+	ImagesRestored      int       `json:"images_restored"`       // images whose original reappeared
+	DanglingShares      []string  `json:"dangling_shares"`       // share IDs whose ciphertext was never uploaded
+	SharesRemoved       int       `json:"shares_removed"`        // dangling shares past the grace period deleted
+	DanglingBundleItems []string  `json:"dangling_bundle_items"` // bundle item IDs whose ciphertext was never uploaded
+	BundleItemsRemoved  int       `json:"bundle_items_removed"`  // dangling bundle items past the grace period deleted
+	LedgerFixed         int       `json:"ledger_fixed"`          // usage ledger rows added, resized or dropped
+}
+
+// InitReconciler reads the reconciliation configuration from the environment
+func InitReconciler() {
+	if env := os.Getenv("RECONCILE_GRACE"); env != "" {
+		if d, err := time.ParseDuration(env); err == nil && d >= 0 {
+			ReconcileGrace = d
+		} else {
+			log.Printf("Warning: invalid RECONCILE_GRACE %q, using %s", env, ReconcileGrace)
+		}
+	}
+	if env := os.Getenv("RECONCILE_INTERVAL"); env != "" {
+		if d, err := time.ParseDuration(env); err == nil && d > 0 {
+			ReconcileInterval = d
+		} else {
+			log.Printf("Warning: invalid RECONCILE_INTERVAL %q, scheduled reconciliation disabled", env)
+		}
+	}
+}
+
+// StartReconcileSchedule runs Reconcile in the background every ReconcileInterval
+func StartReconcileSchedule() {
+	if ReconcileInterval == 0 {
+		return
+	}
+	log.Printf("Storage reconciliation every %s (grace %s)", ReconcileInterval, ReconcileGrace)
+	go func() {
+		ticker := time.NewTicker(ReconcileInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			report, err := Reconcile(false)
+			if err != nil {
+				log.Printf("Storage reconciliation failed: %v", err)
+				continue
+			}
+			log.Printf("Storage reconciliation: %d objects, %d orphans (%d deleted), %d missing images, %d dangling shares, %d dangling bundle items",
+				report.ObjectsScanned, len(report.Orphans), report.OrphansDeleted, len(report.MissingImages), len(report.DanglingShares), len(report.DanglingBundleItems))
+		}
+	}()
+}
+
+// objectOwner resolves which row (if any) keeps an object alive, and who it is charged to
+type objectOwner struct {
+	images      map[string]models.Image // image_id -> live image
+	users       map[string]bool
+	shares      map[string]models.Share // live shares (not declined, not spent one-time)
+	bundles     map[string]models.ShareBundle
+	bundleItems map[string]models.ShareBundleItem // live bundle items
+}
+
+// ownerOf returns the user an object should be charged to, or "" if nothing references it
+func (o *objectOwner) ownerOf(key string) string {
+	if strings.HasPrefix(key, "shares/bundles/") {
+		parts := strings.Split(strings.TrimPrefix(key, "shares/bundles/"), "/")
+		if len(parts) != 2 {
+			return ""
+		}
+		item, ok := o.bundleItems[strings.TrimSuffix(parts[1], ".enc")]
+		if !ok || item.BundleID != parts[0] {
+			return ""
+		}
+		return o.bundles[item.BundleID].SenderID
+	}
+	if strings.HasPrefix(key, "shares/") {
+		share, ok := o.shares[strings.TrimSuffix(strings.TrimPrefix(key, "shares/"), ".enc")]
+		if !ok {
+			return ""
+		}
+		return share.SenderID
+	}
+
+	userID, rest, found := strings.Cut(key, "/")
+	if !found || !o.users[userID] {
+		return ""
+	}
+
+	var imageID string
+	switch {
+	case strings.HasPrefix(rest, "images/originals/"):
+		imageID = strings.TrimSuffix(strings.TrimPrefix(rest, "images/originals/"), ".enc")
+	case strings.HasPrefix(rest, "images/thumbnails/"):
+		name := strings.TrimSuffix(strings.TrimPrefix(rest, "images/thumbnails/"), ".enc")
+		if i := strings.Index(name, "_thumb_"); i >= 0 {
+			imageID = name[:i]
+		} else {
+			return ""
+		}
+	default:
+		// Metadata blobs and legacy direct uploads belong to the user as long as the account exists
+		return userID
+	}
+
+	if image, ok := o.images[imageID]; ok && image.UserID == userID {
+		return userID
+	}
+	return ""
+}
+
+// Reconcile walks the bucket against the images, shares and share bundle tables.
+// It reports orphan objects and dangling rows; unless dryRun is set it deletes orphans and
+// dangling share rows older than ReconcileGrace, flags images with a missing original, and
+// brings the usage ledger in line with the bucket.
+func Reconcile(dryRun bool) (*ReconcileReport, error) {
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+
+	report := &ReconcileReport{
+		DryRun:              dryRun,
+		StartedAt:           time.Now(),
+		Orphans:             []string{},
+		MissingImages:       []string{},
+		DanglingShares:      []string{},
+		DanglingBundleItems: []string{},
+	}
+	cutoff := report.StartedAt.Add(-ReconcileGrace)
+
+	objects, err := ListObjectInfos("")
+	if err != nil {
+		return nil, err
+	}
+	report.ObjectsScanned = len(objects)
+
+	// Load everything that can reference an object
+	owner := objectOwner{
+		images:      map[string]models.Image{},
+		users:       map[string]bool{},
+		shares:      map[string]models.Share{},
+		bundles:     map[string]models.ShareBundle{},
+		bundleItems: map[string]models.ShareBundleItem{},
+	}
+
+	var usernames []string
+	database.DB.Model(&models.User{}).Pluck("username", &usernames)
+	for _, u := range usernames {
+		owner.users[u] = true
+	}
+
+	var images []models.Image
+	database.DB.Where("is_deleted = ?", false).Find(&images)
+	for _, img := range images {
+		owner.images[img.ImageID] = img
+	}
+
+	var shares []models.Share
+	database.DB.Where("status <> ?", models.ShareStatusDeclined).
+		Where("NOT (share_type = ? AND viewed_at IS NOT NULL)", models.ShareTypeOneTime).
+		Find(&shares)
+	for _, s := range shares {
+		owner.shares[s.ID] = s
+	}
+
+	var bundles []models.ShareBundle
+	database.DB.Where("status <> ?", models.ShareStatusDeclined).Find(&bundles)
+	bundleIDs := make([]string, 0, len(bundles))
+	for _, b := range bundles {
+		owner.bundles[b.ID] = b
+		bundleIDs = append(bundleIDs, b.ID)
+	}
+
+	var items []models.ShareBundleItem
+	if len(bundleIDs) > 0 {
+		database.DB.Where("bundle_id IN ?", bundleIDs).Find(&items)
+	}
+	for _, item := range items {
+		owner.bundleItems[item.ID] = item
+	}
+
+	// Orphans: objects nothing references any more
+	present := make(map[string]int64, len(objects))
+	charged := make(map[string]string, len(objects))
+	for _, obj := range objects {
+		userID := owner.ownerOf(obj.Key)
+		if userID != "" {
+			present[obj.Key] = obj.Size
+			charged[obj.Key] = userID
+			continue
+		}
+
+		report.Orphans = append(report.Orphans, obj.Key)
+		report.OrphanBytes += obj.Size
+		if !dryRun && obj.LastModified.Before(cutoff) {
+			if err := DeleteObject(obj.Key); err != nil {
+				log.Printf("Reconcile: failed to delete orphan %s: %v", obj.Key, err)
+				continue
+			}
+			report.OrphansDeleted++
+		}
+	}
+
+	// Dangling images: rows whose original never arrived or has been lost
+	for _, img := range images {
+		_, ok := present[imageObjectKey(img.UserID, img.ImageID)]
+		switch {
+		case !ok && !img.ObjectsMissing && img.UploadedAt.Before(cutoff):
+			report.MissingImages = append(report.MissingImages, img.ImageID)
+			if !dryRun {
+				database.DB.Model(&models.Image{}).Where("image_id = ?", img.ImageID).
+					Updates(map[string]interface{}{"objects_missing": true, "modified_at": time.Now()})
+				report.ImagesMarked++
+			}
+		case !ok && img.ObjectsMissing:
+			report.MissingImages = append(report.MissingImages, img.ImageID)
+		case ok && img.ObjectsMissing:
+			if !dryRun {
+				database.DB.Model(&models.Image{}).Where("image_id = ?", img.ImageID).
+					Updates(map[string]interface{}{"objects_missing": false, "modified_at": time.Now()})
+				report.ImagesRestored++
+			}
+		}
+	}
+
+	// Dangling shares: the sender never uploaded the ciphertext, so the receiver can't open it
+	for _, s := range shares {
+		if _, ok := present["shares/"+s.ID+".enc"]; ok || !s.CreatedAt.Before(cutoff) {
+			continue
+		}
+		report.DanglingShares = append(report.DanglingShares, s.ID)
+		if !dryRun && database.DB.Delete(&models.Share{}, "id = ?", s.ID).Error == nil {
+			report.SharesRemoved++
+		}
+	}
+
+	for _, item := range items {
+		if _, ok := present["shares/bundles/"+item.BundleID+"/"+item.ID+".enc"]; ok || !item.CreatedAt.Before(cutoff) {
+			continue
+		}
+		report.DanglingBundleItems = append(report.DanglingBundleItems, item.ID)
+		if dryRun {
+			continue
+		}
+		if database.DB.Delete(&models.ShareBundleItem{}, "id = ?", item.ID).Error != nil {
+			continue
+		}
+		report.BundleItemsRemoved++
+
+		// Keep the bundle's count in step, and drop bundles left with nothing in them
+		var remaining int64
+		database.DB.Model(&models.ShareBundleItem{}).Where("bundle_id = ?", item.BundleID).Count(&remaining)
+		if remaining == 0 {
+			database.DB.Delete(&models.ShareBundle{}, "id = ?", item.BundleID)
+		} else {
+			database.DB.Model(&models.ShareBundle{}).Where("id = ?", item.BundleID).Update("item_count", remaining)
+		}
+	}
+
+	// Usage ledger: charge what's in the bucket, forget what isn't. Rows written since the
+	// listing was taken are for uploads that landed mid-run, so they are left alone.
+	var ledger []models.StorageObject
+	database.DB.Find(&ledger)
+	recorded := make(map[string]models.StorageObject, len(ledger))
+	var stale []string
+	for _, entry := range ledger {
+		if _, ok := present[entry.ObjectName]; !ok {
+			if entry.UpdatedAt.Before(report.StartedAt) {
+				stale = append(stale, entry.ObjectName)
+			}
+			continue
+		}
+		recorded[entry.ObjectName] = entry
+	}
+	report.LedgerFixed += len(stale)
+	for key, size := range present {
+		if entry, ok := recorded[key]; ok && entry.Size == size && entry.UserID == charged[key] {
+			continue
+		}
+		report.LedgerFixed++
+		if !dryRun {
+			RecordObjectSize(charged[key], key, size)
+		}
+	}
+	if !dryRun && len(stale) > 0 {
+		// Re-check the timestamp so an upload recorded since the Find above keeps its row
+		database.DB.Where("object_name IN ? AND updated_at < ?", stale, report.StartedAt).Delete(&models.StorageObject{})
+	}
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// imageObjectKey is the storage path of an image's original
+func imageObjectKey(userID, imageID string) string {
+	return userID + "/images/originals/" + imageID + ".enc"
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"chithram/models"
+)
+
+// Registration modes
+const (
+	RegistrationOpen   = "open"   // anyone can sign up; an invite code is optional
+	RegistrationInvite = "invite" // signup requires a valid invite code
+	RegistrationClosed = "closed" // no new accounts
+)
+
+var (
+	// RegistrationMode controls who can sign up. Configured with REGISTRATION_MODE.
+	RegistrationMode = RegistrationOpen
+
+	// UserInvitesEnabled lets regular users create invites, not just admins. Configured with USER_INVITES=true.
+	UserInvitesEnabled = false
+
+	// DefaultInviteTTL is the expiry of invites created without one
+	DefaultInviteTTL = 7 * 24 * time.Hour
+
+	// ErrInviteInvalid is returned for unknown, expired, revoked or used-up invite codes
+	ErrInviteInvalid = errors.New("invalid or expired invite code")
+)
+
+// InitRegistration reads the signup configuration from the environment
+func InitRegistration() {
+	if env := os.Getenv("REGISTRATION_MODE"); env != "" {
+		switch env {
+		case RegistrationOpen, RegistrationInvite, RegistrationClosed:
+			RegistrationMode = env
+		default:
+			log.Printf("Warning: invalid REGISTRATION_MODE %q, using %s", env, RegistrationMode)
+		}
+	}
+	UserInvitesEnabled = os.Getenv("USER_INVITES") == "true"
+	log.Printf("Registration mode: %s (user invites: %v)", RegistrationMode, UserInvitesEnabled)
+}
+
+// NewInviteCode returns a random, human-typeable invite code
+func NewInviteCode() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw), nil
+}
+
+// NormalizeInviteCode makes codes typed by hand match the stored form
+func NormalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// RedeemInvite uses up one use of an invite inside tx. The check and the increment are a
+// single conditional UPDATE, so concurrent signups can't redeem more uses than allowed.
+func RedeemInvite(tx *gorm.DB, code string) error {
+	result := tx.Model(&models.Invite{}).
+		Where("code = ? AND revoked_at IS NULL", code).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Where("max_uses = 0 OR uses < max_uses").
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return ErrInviteInvalid
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+var (
+	// SessionTTL is how long a login session stays valid. Configured with SESSION_TTL.
+	SessionTTL = 30 * 24 * time.Hour
+
+	// ErrInvalidSession is returned for unknown, expired or revoked tokens
+	ErrInvalidSession = errors.New("invalid or expired session")
+)
+
+// InitSessions reads the session configuration from the environment
+func InitSessions() {
+	if env := os.Getenv("SESSION_TTL"); env != "" {
+		if d, err := time.ParseDuration(env); err == nil && d > 0 {
+			SessionTTL = d
+		} else {
+			log.Printf("Warning: invalid SESSION_TTL %q, using %s", env, SessionTTL)
+		}
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession starts a new session for a user and returns its bearer token
+func CreateSession(userID, userAgent, ip string) (string, *models.Session, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	session := models.Session{
+		TokenHash:  hashToken(token),
+		UserID:     userID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(SessionTTL),
+		UserAgent:  userAgent,
+		IP:         ip,
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return "", nil, err
+	}
+	return token, &session, nil
+}
+
+// ValidateSession looks up the live session for a bearer token
+func ValidateSession(token string) (*models.Session, error) {
+	if token == "" {
+		return nil, ErrInvalidSession
+	}
+	var session models.Session
+	if err := database.DB.Where("token_hash = ?", hashToken(token)).First(&session).Error; err != nil {
+		return nil, ErrInvalidSession
+	}
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return nil, ErrInvalidSession
+	}
+
+	// Only touch last_seen_at occasionally to avoid a write per request
+	if now.Sub(session.LastSeenAt) > time.Minute {
+		database.DB.Model(&session).Update("last_seen_at", now)
+	}
+	return &session, nil
+}
+
+// IsNewDevice reports whether a user has signed in before, but never from this user agent
+func IsNewDevice(userID, userAgent string) bool {
+	var total, matching int64
+	database.DB.Model(&models.Session{}).Where("user_id = ?", userID).Count(&total)
+	if total == 0 {
+		return false
+	}
+	database.DB.Model(&models.Session{}).Where("user_id = ? AND user_agent = ?", userID, userAgent).Count(&matching)
+	return matching == 0
+}
+
+// RevokeSession ends the session of a single bearer token
+func RevokeSession(token string) {
+	database.DB.Model(&models.Session{}).
+		Where("token_hash = ? AND revoked_at IS NULL", hashToken(token)).
+		Update("revoked_at", time.Now())
+}
+
+// RevokeSessions ends every active session of a user and returns how many were revoked
+func RevokeSessions(userID string) int64 {
+	return RevokeOtherSessions(userID, 0)
+}
+
+// RevokeOtherSessions ends every active session of a user except keepID
+func RevokeOtherSessions(userID string, keepID uint) int64 {
+	result := database.DB.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL AND expires_at > ?", userID, keepID, time.Now()).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected
+}
+
+// ActiveSessionCounts returns the number of live sessions per user
+func ActiveSessionCounts() map[string]int64 {
+	var rows []struct {
+		UserID string
+		Total  int64
+	}
+	database.DB.Model(&models.Session{}).
+		Select("user_id, COUNT(*) AS total").
+		Where("revoked_at IS NULL AND expires_at > ?", time.Now()).
+		Group("user_id").
+		Scan(&rows)
+
+	counts := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		counts[r.UserID] = r.Total
+	}
+	return counts
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"chithram/database"
+	"chithram/models"
+)
+
+var (
+	// DefaultQuotaBytes is the storage quota for users without an override (0 = unlimited).
+	// Configured with STORAGE_QUOTA_BYTES.
+	DefaultQuotaBytes int64 = 0
+
+	// ErrQuotaExceeded is returned when an upload would take a user over their quota
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// InitStorageQuotas reads the quota configuration from the environment
+func InitStorageQuotas() {
+	if env := os.Getenv("STORAGE_QUOTA_BYTES"); env != "" {
+		quota, err := strconv.ParseInt(env, 10, 64)
+		if err != nil || quota < 0 {
+			log.Printf("Warning: invalid STORAGE_QUOTA_BYTES %q, quotas disabled", env)
+			return
+		}
+		DefaultQuotaBytes = quota
+	}
+	log.Printf("Storage quota: %d bytes per user (0 = unlimited)", DefaultQuotaBytes)
+}
+
+// StorageCategory derives the accounting category from an object's path
+func StorageCategory(objectName string) string {
+	switch {
+	case strings.HasPrefix(objectName, "shares/"):
+		return models.StorageCategoryShares
+	case strings.Contains(objectName, "/images/thumbnails/"):
+		return models.StorageCategoryThumbnails
+	case strings.Contains(objectName, "/metadata/"):
+		return models.StorageCategoryMetadata
+	default:
+		return models.StorageCategoryOriginals
+	}
+}
+
+// RecordObjectSize charges an object of known size to a user, replacing any previous entry for it
+func RecordObjectSize(userID, objectName string, size int64) error {
+	entry := models.StorageObject{
+		ObjectName: objectName,
+		UserID:     userID,
+		Category:   StorageCategory(objectName),
+		Size:       size,
+		UpdatedAt:  time.Now(),
+	}
+	return database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&entry).Error
+}
+
+// RecordObject looks up an uploaded object's size in MinIO and charges it to a user
+func RecordObject(userID, objectName string) (int64, error) {
+	size, err := StatObjectSize(objectName)
+	if err != nil {
+		return 0, err
+	}
+	return size, RecordObjectSize(userID, objectName, size)
+}
+
+// RecordUpload charges an object a client uploaded through a presigned URL. Presigned PUTs can't
+// limit their size, so the quota is enforced here: an object that would take the user over quota
+// is deleted from MinIO and ErrQuotaExceeded is returned along with its size.
+func RecordUpload(userID, objectName string) (int64, error) {
+	size, err := StatObjectSize(objectName)
+	if err != nil {
+		return 0, err
+	}
+
+	// A re-upload replaces the object, so its previous size no longer counts
+	var previous models.StorageObject
+	database.DB.Where("object_name = ?", objectName).First(&previous)
+	if size > previous.Size && CheckQuota(userID, size-previous.Size) != nil {
+		if err := DeleteObject(objectName); err != nil {
+			log.Printf("Failed to delete over-quota upload %s: %v", objectName, err)
+		}
+		ForgetObjects(objectName)
+		return size, ErrQuotaExceeded
+	}
+	return size, RecordObjectSize(userID, objectName, size)
+}
+
+// ForgetObjects removes deleted objects from the usage ledger
+func ForgetObjects(objectNames ...string) {
+	if len(objectNames) == 0 {
+		return
+	}
+	database.DB.Where("object_name IN ?", objectNames).Delete(&models.StorageObject{})
+}
+
+// StorageUsage returns a user's total bytes and the breakdown per category
+func StorageUsage(userID string) (int64, map[string]int64) {
+	var rows []struct {
+		Category string
+		Total    int64
+	}
+	database.DB.Model(&models.StorageObject{}).
+		Select("category, SUM(size) AS total").
+		Where("user_id = ?", userID).
+		Group("category").
+		Scan(&rows)
+
+	categories := map[string]int64{
+		models.StorageCategoryOriginals:  0,
+		models.StorageCategoryThumbnails: 0,
+		models.StorageCategoryMetadata:   0,
+		models.StorageCategoryShares:     0,
+	}
+	var total int64
+	for _, r := range rows {
+		categories[r.Category] = r.Total
+		total += r.Total
+	}
+	return total, categories
+}
+
+// QuotaFor returns the effective quota of a user in bytes (0 = unlimited). A positive override
+// replaces the server default, QuotaUnlimited lifts the quota and 0 keeps the default.
+func QuotaFor(userID string) int64 {
+	var user models.User
+	if err := database.DB.Select("quota_bytes").Where("username = ?", userID).First(&user).Error; err == nil {
+		switch {
+		case user.QuotaBytes > 0:
+			return user.QuotaBytes
+		case user.QuotaBytes == models.QuotaUnlimited:
+			return 0
+		}
+	}
+	return DefaultQuotaBytes
+}
+
+// CheckQuota returns ErrQuotaExceeded if storing additional bytes would take the user over quota.
+// With additional = 0 it only checks that the user is not already at or over quota.
+func CheckQuota(userID string, additional int64) error {
+	quota := QuotaFor(userID)
+	if quota == 0 {
+		return nil
+	}
+	used, _ := StorageUsage(userID)
+	if used+additional > quota || (additional == 0 && used >= quota) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package tensors
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ErrNoUpdates is returned when there is nothing to aggregate
+var ErrNoUpdates = errors.New("no updates to aggregate")
+
+// carriers maps every tensor in any of the updates to the indices of the updates carrying it with
+// the shape it has in the first of them. Each tensor is combined over the updates that carry it,
+// so an update that leaves tensors out can't drop them from everyone else's result.
+func carriers(updates []Weights) map[string][]int {
+	out := map[string][]int{}
+	for i, u := range updates {
+		for name, t := range u {
+			if idx, ok := out[name]; ok && !t.SameShape(updates[idx[0]][name]) {
+				continue
+			}
+			out[name] = append(out[name], i)
+		}
+	}
+	return out
+}
+
+// coordinateWise builds a result by calling combine, at each position of each tensor, with the
+// values of the updates carrying that tensor and their indices. The values slice is reused
+// between calls.
+func coordinateWise(updates []Weights, combine func(values []float64, idx []int) float64) Weights {
+	out := Weights{}
+	for name, idx := range carriers(updates) {
+		ref := updates[idx[0]][name]
+		t := &Tensor{Shape: ref.Shape, Data: make([]float32, len(ref.Data))}
+		values := make([]float64, len(idx))
+		for i := range t.Data {
+			for j, u := range idx {
+				values[j] = float64(updates[u][name].Data[i])
+			}
+			t.Data[i] = float32(combine(values, idx))
+		}
+		out[name] = t
+	}
+	return out
+}
+
+// sameTensors reports whether a and b carry the same tensors with the same shapes
+func sameTensors(a, b Weights) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, t := range a {
+		if o, ok := b[name]; !ok || !t.SameShape(o) {
+			return false
+		}
+	}
+	return true
+}
+
+// FedAvg is the weighted mean of the updates. A nil weights slice counts every update equally.
+func FedAvg(updates []Weights, weights []float64) (Weights, error) {
+	if len(updates) == 0 {
+		return nil, ErrNoUpdates
+	}
+	if weights == nil {
+		weights = make([]float64, len(updates))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	if len(weights) != len(updates) {
+		return nil, fmt.Errorf("got %d weights for %d updates", len(weights), len(updates))
+	}
+	for _, w := range weights {
+		if w <= 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("weights must be positive, got %v", weights)
+		}
+	}
+
+	return coordinateWise(updates, func(values []float64, idx []int) float64 {
+		var sum, total float64
+		for j, v := range values {
+			sum += v * weights[idx[j]]
+			total += weights[idx[j]]
+		}
+		return sum / total
+	}), nil
+}
+
+// Median is the coordinate-wise median. For an even count the two middle values are averaged.
+func Median(updates []Weights) (Weights, error) {
+	if len(updates) == 0 {
+		return nil, ErrNoUpdates
+	}
+	return coordinateWise(updates, func(values []float64, _ []int) float64 {
+		sort.Float64s(values)
+		n := len(values)
+		mid := n / 2
+		if n%2 == 1 {
+			return values[mid]
+		}
+		return (values[mid-1] + values[mid]) / 2
+	}), nil
+}
+
+// TrimmedMean drops the trimRatio largest and smallest values at each coordinate and averages
+// the rest. Sample weights are ignored so a client can't buy influence by claiming more samples.
+// Tensors carried by fewer updates are trimmed in the same proportion, keeping at least one value.
+func TrimmedMean(updates []Weights, trimRatio float64) (Weights, error) {
+	n := len(updates)
+	if n == 0 {
+		return nil, ErrNoUpdates
+	}
+	k := int(float64(n) * trimRatio)
+	if n-2*k < 1 {
+		return nil, fmt.Errorf("trim ratio %g leaves no updates out of %d", trimRatio, n)
+	}
+	return coordinateWise(updates, func(values []float64, _ []int) float64 {
+		sort.Float64s(values)
+		count := len(values)
+		trim := min(int(float64(count)*trimRatio), (count-1)/2)
+		var sum float64
+		for _, v := range values[trim : count-trim] {
+			sum += v
+		}
+		return sum / float64(count-2*trim)
+	}), nil
+}
+
+// Krum scores every update by the summed squared distance to its n - f - 2 nearest neighbours
+// and returns the indices of the m lowest-scoring ones, best first. It requires n > 2f + 2.
+// Updates that don't carry the same tensors are infinitely far apart, so one that leaves tensors
+// out can't look close to the others by being small.
+func Krum(updates []Weights, f, m int) ([]int, error) {
+	n := len(updates)
+	if n <= 2*f+2 {
+		return nil, fmt.Errorf("krum with f=%d needs more than %d updates, got %d", f, 2*f+2, n)
+	}
+	if m < 1 || m > n {
+		return nil, fmt.Errorf("krum can't select %d of %d updates", m, n)
+	}
+
+	dist := make([][]float64, n)
+	for i := range dist {
+		dist[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if !sameTensors(updates[i], updates[j]) {
+				dist[i][j], dist[j][i] = math.Inf(1), math.Inf(1)
+				continue
+			}
+			var d float64
+			for name := range updates[i] {
+				a, b := updates[i][name].Data, updates[j][name].Data
+				for k := range a {
+					diff := float64(a[k]) - float64(b[k])
+					d += diff * diff
+				}
+			}
+			dist[i][j], dist[j][i] = d, d
+		}
+	}
+
+	neighbours := n - f - 2
+	scores := make([]float64, n)
+	order := make([]int, n)
+	for i := 0; i < n; i++ {
+		others := make([]float64, 0, n-1)
+		for j := 0; j < n; j++ {
+			if j != i {
+				others = append(others, dist[i][j])
+			}
+		}
+		sort.Float64s(others)
+		for _, d := range others[:neighbours] {
+			scores[i] += d
+		}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] < scores[order[b]] })
+	return order[:m], nil
+}
@@ -0,0 +1,171 @@
+package tensors
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+const (
+	honestCount   = 10
+	poisonedCount = 3
+	// tolerance is how far a robust aggregate may stray from the honest mean at any coordinate
+	tolerance = 0.25
+)
+
+// poisonedUpdates returns honestCount updates scattered around a common model, followed by
+// poisonedCount updates pushed far off in the same direction, and the mean of the honest ones
+func poisonedUpdates(t *testing.T) ([]Weights, Weights) {
+	t.Helper()
+	rng := rand.New(rand.NewPCG(1, 2))
+	shapes := map[string][]int64{"conv.weight": {4, 8}, "conv.bias": {8}}
+
+	center := Weights{}
+	for name, shape := range shapes {
+		tensor := &Tensor{Shape: shape}
+		tensor.Data = make([]float32, tensor.NumElements())
+		for i := range tensor.Data {
+			tensor.Data[i] = float32(rng.NormFloat64())
+		}
+		center[name] = tensor
+	}
+
+	var updates []Weights
+	for i := 0; i < honestCount+poisonedCount; i++ {
+		update := Weights{}
+		for name, c := range center {
+			tensor := &Tensor{Shape: c.Shape, Data: make([]float32, len(c.Data))}
+			for j, v := range c.Data {
+				if i < honestCount {
+					tensor.Data[j] = v + float32(0.1*rng.NormFloat64())
+				} else {
+					tensor.Data[j] = v + 50 + float32(rng.NormFloat64())
+				}
+			}
+			update[name] = tensor
+		}
+		updates = append(updates, update)
+	}
+
+	honestMean, err := FedAvg(updates[:honestCount], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return updates, honestMean
+}
+
+// maxDeviation is the largest coordinate-wise distance between a and b
+func maxDeviation(t *testing.T, a, b Weights) float64 {
+	t.Helper()
+	if !slices.Equal(a.Names(), b.Names()) {
+		t.Fatalf("tensor names differ: %v and %v", a.Names(), b.Names())
+	}
+	var worst float64
+	for _, name := range a.Names() {
+		for i, v := range a[name].Data {
+			worst = max(worst, math.Abs(float64(v)-float64(b[name].Data[i])))
+		}
+	}
+	return worst
+}
+
+func TestFedAvgFollowsPoisonedUpdates(t *testing.T) {
+	updates, honestMean := poisonedUpdates(t)
+
+	result, err := FedAvg(updates, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := maxDeviation(t, result, honestMean); d <= tolerance {
+		t.Fatalf("FedAvg stayed within %v of the honest mean (%v); the poisoned updates should pull it away", tolerance, d)
+	}
+}
+
+func TestRobustAggregationResistsPoisonedUpdates(t *testing.T) {
+	updates, honestMean := poisonedUpdates(t)
+
+	krumMean := func(m int) func() (Weights, error) {
+		return func() (Weights, error) {
+			selected, err := Krum(updates, poisonedCount, m)
+			if err != nil {
+				return nil, err
+			}
+			for _, idx := range selected {
+				if idx >= honestCount {
+					t.Errorf("krum with m=%d selected poisoned update %d", m, idx)
+				}
+			}
+			chosen := make([]Weights, len(selected))
+			for i, idx := range selected {
+				chosen[i] = updates[idx]
+			}
+			return FedAvg(chosen, nil)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		aggregate func() (Weights, error)
+	}{
+		{"median", func() (Weights, error) { return Median(updates) }},
+		{"trimmed mean", func() (Weights, error) { return TrimmedMean(updates, 0.25) }},
+		{"krum", krumMean(1)},
+		{"multi-krum", krumMean(len(updates) - poisonedCount)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.aggregate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := maxDeviation(t, result, honestMean); d > tolerance {
+				t.Fatalf("result is %v from the honest mean, tolerance is %v", d, tolerance)
+			}
+		})
+	}
+}
+
+func TestPartialUpdateKeepsOtherTensors(t *testing.T) {
+	updates, _ := poisonedUpdates(t)
+	honest := updates[:honestCount]
+	partial := Weights{"conv.bias": honest[0]["conv.bias"]}
+	withPartial := append(slices.Clone(honest), partial)
+
+	honestMean, err := FedAvg(honest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, aggregate := range map[string]func([]Weights) (Weights, error){
+		"fedavg":       func(u []Weights) (Weights, error) { return FedAvg(u, nil) },
+		"median":       Median,
+		"trimmed mean": func(u []Weights) (Weights, error) { return TrimmedMean(u, 0.25) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, err := aggregate(withPartial)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := maxDeviation(t, result, honestMean); d > tolerance {
+				t.Fatalf("result is %v from the honest mean, tolerance is %v", d, tolerance)
+			}
+		})
+	}
+
+	t.Run("krum", func(t *testing.T) {
+		selected, err := Krum(withPartial, 1, len(withPartial)-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slices.Contains(selected, honestCount) {
+			t.Fatalf("krum selected the partial update: %v", selected)
+		}
+	})
+}
+
+func TestKrumRequiresEnoughUpdates(t *testing.T) {
+	updates, _ := poisonedUpdates(t)
+	if _, err := Krum(updates[:8], poisonedCount, 1); err == nil {
+		t.Fatal("Krum with n <= 2f + 2 succeeded")
+	}
+}
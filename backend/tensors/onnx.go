@@ -0,0 +1,257 @@
+package tensors
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers from onnx.proto
+const (
+	modelGraphField       = 7 // ModelProto.graph
+	graphInitializerField = 5 // GraphProto.initializer
+	tensorDimsField       = 1 // TensorProto.dims
+	tensorDataTypeField   = 2 // TensorProto.data_type
+	tensorFloatDataField  = 4 // TensorProto.float_data
+	tensorNameField       = 8 // TensorProto.name
+	tensorRawDataField    = 9 // TensorProto.raw_data
+
+	onnxFloat = 1 // TensorProto.DataType.FLOAT
+)
+
+// ErrInvalidONNX is returned for models that can't be parsed
+var ErrInvalidONNX = errors.New("invalid ONNX model")
+
+// onnxTensor is the subset of a TensorProto needed to read float initializers
+type onnxTensor struct {
+	name      string
+	dims      []int64
+	dataType  uint64
+	rawData   []byte
+	floatData []float32
+}
+
+// parseTensor reads the fields of a TensorProto we care about
+func parseTensor(b []byte) (*onnxTensor, error) {
+	t := &onnxTensor{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, ErrInvalidONNX
+		}
+		b = b[n:]
+
+		switch {
+		case num == tensorDimsField && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, ErrInvalidONNX
+			}
+			t.dims = append(t.dims, int64(v))
+			b = b[n:]
+		case num == tensorDimsField && typ == protowire.BytesType:
+			packed, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, ErrInvalidONNX
+			}
+			for len(packed) > 0 {
+				v, m := protowire.ConsumeVarint(packed)
+				if m < 0 {
+					return nil, ErrInvalidONNX
+				}
+				t.dims = append(t.dims, int64(v))
+				packed = packed[m:]
+			}
+			b = b[n:]
+		case num == tensorDataTypeField && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, ErrInvalidONNX
+			}
+			t.dataType = v
+			b = b[n:]
+		case num == tensorFloatDataField && typ == protowire.BytesType:
+			packed, n := protowire.ConsumeBytes(b)
+			if n < 0 || len(packed)%4 != 0 {
+				return nil, ErrInvalidONNX
+			}
+			for i := 0; i < len(packed); i += 4 {
+				t.floatData = append(t.floatData, math.Float32frombits(binary.LittleEndian.Uint32(packed[i:])))
+			}
+			b = b[n:]
+		case num == tensorFloatDataField && typ == protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			if n < 0 {
+				return nil, ErrInvalidONNX
+			}
+			t.floatData = append(t.floatData, math.Float32frombits(v))
+			b = b[n:]
+		case num == tensorNameField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, ErrInvalidONNX
+			}
+			t.name = string(v)
+			b = b[n:]
+		case num == tensorRawDataField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, ErrInvalidONNX
+			}
+			t.rawData = v
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return nil, ErrInvalidONNX
+			}
+			b = b[n:]
+		}
+	}
+	return t, nil
+}
+
+// toTensor converts a FLOAT initializer with inline data; anything else returns nil
+func (t *onnxTensor) toTensor() *Tensor {
+	if t.dataType != onnxFloat {
+		return nil
+	}
+	out := &Tensor{Shape: t.dims}
+	n := out.NumElements()
+	switch {
+	case int64(len(t.rawData)) == n*4:
+		out.Data = make([]float32, n)
+		for i := range out.Data {
+			out.Data[i] = math.Float32frombits(binary.LittleEndian.Uint32(t.rawData[i*4:]))
+		}
+	case int64(len(t.floatData)) == n:
+		out.Data = t.floatData
+	default:
+		// External data or an empty tensor
+		return nil
+	}
+	return out
+}
+
+// forEachField walks the top-level fields of a message, passing each field's number, wire type,
+// its complete encoding (tag included) and, for length-delimited fields, its payload
+func forEachField(b []byte, fn func(num protowire.Number, typ protowire.Type, field, payload []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return ErrInvalidONNX
+		}
+		m := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if m < 0 {
+			return ErrInvalidONNX
+		}
+		field := b[:n+m]
+		var payload []byte
+		if typ == protowire.BytesType {
+			payload, _ = protowire.ConsumeBytes(b[n:])
+		}
+		if err := fn(num, typ, field, payload); err != nil {
+			return err
+		}
+		b = b[n+m:]
+	}
+	return nil
+}
+
+// ReadONNXInitializers returns the float32 initializers stored inline in an ONNX model
+func ReadONNXInitializers(model []byte) (Weights, error) {
+	weights := Weights{}
+	err := forEachField(model, func(num protowire.Number, typ protowire.Type, _, graph []byte) error {
+		if num != modelGraphField || typ != protowire.BytesType {
+			return nil
+		}
+		return forEachField(graph, func(num protowire.Number, typ protowire.Type, _, init []byte) error {
+			if num != graphInitializerField || typ != protowire.BytesType {
+				return nil
+			}
+			t, err := parseTensor(init)
+			if err != nil {
+				return err
+			}
+			if tensor := t.toTensor(); tensor != nil && t.name != "" {
+				weights[t.name] = tensor
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return weights, nil
+}
+
+// PatchONNXInitializers returns a copy of model with the named float initializers replaced by
+// weights, stored as raw_data. Every other field is copied byte for byte. It returns how many
+// initializers were replaced; a tensor whose shape differs from its initializer is an error.
+func PatchONNXInitializers(model []byte, weights Weights) ([]byte, int, error) {
+	patched := 0
+	out := make([]byte, 0, len(model))
+	err := forEachField(model, func(num protowire.Number, typ protowire.Type, field, graph []byte) error {
+		if num != modelGraphField || typ != protowire.BytesType {
+			out = append(out, field...)
+			return nil
+		}
+
+		newGraph := make([]byte, 0, len(graph))
+		err := forEachField(graph, func(num protowire.Number, typ protowire.Type, field, init []byte) error {
+			if num != graphInitializerField || typ != protowire.BytesType {
+				newGraph = append(newGraph, field...)
+				return nil
+			}
+			t, err := parseTensor(init)
+			if err != nil {
+				return err
+			}
+			w, ok := weights[t.name]
+			if !ok || t.dataType != onnxFloat {
+				newGraph = append(newGraph, field...)
+				return nil
+			}
+			if !w.SameShape(&Tensor{Shape: t.dims}) {
+				return fmt.Errorf("%w: %q has shape %v, initializer has %v", ErrInvalidONNX, t.name, w.Shape, t.dims)
+			}
+
+			// Keep every field but the old data, then append the new raw_data
+			var tensor []byte
+			err = forEachField(init, func(num protowire.Number, _ protowire.Type, field, _ []byte) error {
+				if num != tensorFloatDataField && num != tensorRawDataField {
+					tensor = append(tensor, field...)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			raw := make([]byte, 0, len(w.Data)*4)
+			for _, v := range w.Data {
+				raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(v))
+			}
+			tensor = protowire.AppendTag(tensor, tensorRawDataField, protowire.BytesType)
+			tensor = protowire.AppendBytes(tensor, raw)
+
+			newGraph = protowire.AppendTag(newGraph, graphInitializerField, protowire.BytesType)
+			newGraph = protowire.AppendBytes(newGraph, tensor)
+			patched++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		out = protowire.AppendTag(out, modelGraphField, protowire.BytesType)
+		out = protowire.AppendBytes(out, newGraph)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, patched, nil
+}
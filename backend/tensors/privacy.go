@@ -0,0 +1,53 @@
+package tensors
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// ClipToBase bounds how far an update moves from base: the delta update - base over the tensors
+// of update is scaled down to an L2 norm of at most maxNorm. It returns the clipped update and
+// the norm of the original delta. Tensors that base lacks, or has in another shape, are dropped.
+func ClipToBase(update, base Weights, maxNorm float64) (Weights, float64) {
+	shared := Weights{}
+	for name, t := range update {
+		if ref, ok := base[name]; ok && t.SameShape(ref) {
+			shared[name] = t
+		}
+	}
+
+	var sq float64
+	for name, t := range shared {
+		ref := base[name]
+		for i, v := range t.Data {
+			d := float64(v) - float64(ref.Data[i])
+			sq += d * d
+		}
+	}
+	norm := math.Sqrt(sq)
+	scale := 1.0
+	if norm > maxNorm {
+		scale = maxNorm / norm
+	}
+
+	out := Weights{}
+	for name, t := range shared {
+		ref := base[name]
+		c := &Tensor{Shape: t.Shape, Data: make([]float32, len(t.Data))}
+		for i, v := range t.Data {
+			c.Data[i] = float32(float64(ref.Data[i]) + (float64(v)-float64(ref.Data[i]))*scale)
+		}
+		out[name] = c
+	}
+	return out, norm
+}
+
+// AddGaussianNoise adds independent N(0, stddev²) noise to every value of w in place
+func AddGaussianNoise(w Weights, stddev float64, rng *rand.Rand) {
+	for _, name := range w.Names() {
+		t := w[name]
+		for i := range t.Data {
+			t.Data[i] = float32(float64(t.Data[i]) + rng.NormFloat64()*stddev)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package tensors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// maxHeaderSize bounds the JSON header of a safetensors file
+const maxHeaderSize = 16 << 20
+
+// ErrInvalidSafetensors is returned for files that don't follow the safetensors layout
+var ErrInvalidSafetensors = errors.New("invalid safetensors file")
+
+type safetensorsEntry struct {
+	DType       string   `json:"dtype"`
+	Shape       []int64  `json:"shape"`
+	DataOffsets [2]int64 `json:"data_offsets"`
+}
+
+// ReadSafetensorsFile loads a safetensors file from disk
+func ReadSafetensorsFile(path string) (Weights, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ReadSafetensors(data)
+}
+
+// ReadSafetensors decodes a safetensors buffer: an 8-byte little-endian header length, a JSON
+// header mapping tensor names to dtype, shape and byte offsets, then the tensor data.
+// F32 and F64 tensors are supported; F64 is narrowed to float32.
+func ReadSafetensors(data []byte) (Weights, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidSafetensors)
+	}
+	headerLen := binary.LittleEndian.Uint64(data[:8])
+	if headerLen > maxHeaderSize || headerLen > uint64(len(data)-8) {
+		return nil, fmt.Errorf("%w: header length %d out of range", ErrInvalidSafetensors, headerLen)
+	}
+
+	var header map[string]json.RawMessage
+	if err := json.Unmarshal(data[8:8+headerLen], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidSafetensors, err)
+	}
+	body := data[8+headerLen:]
+
+	weights := Weights{}
+	for name, raw := range header {
+		if name == "__metadata__" {
+			continue
+		}
+		var e safetensorsEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, fmt.Errorf("%w: entry %q: %v", ErrInvalidSafetensors, name, err)
+		}
+
+		// Multiply the dimensions carefully: a hostile header could overflow the element count
+		t := &Tensor{Shape: e.Shape}
+		n := int64(1)
+		for _, d := range e.Shape {
+			if d < 0 || (d > 0 && n > int64(len(body))/d) {
+				return nil, fmt.Errorf("%w: %q has a bad shape", ErrInvalidSafetensors, name)
+			}
+			n *= d
+		}
+
+		var size int64
+		switch e.DType {
+		case "F32":
+			size = 4
+		case "F64":
+			size = 8
+		default:
+			return nil, fmt.Errorf("%w: %q has unsupported dtype %s", ErrInvalidSafetensors, name, e.DType)
+		}
+
+		begin, end := e.DataOffsets[0], e.DataOffsets[1]
+		if begin < 0 || end < begin || end > int64(len(body)) || end-begin != n*size {
+			return nil, fmt.Errorf("%w: %q has bad data offsets", ErrInvalidSafetensors, name)
+		}
+		chunk := body[begin:end]
+
+		t.Data = make([]float32, n)
+		for i := range t.Data {
+			if size == 4 {
+				t.Data[i] = math.Float32frombits(binary.LittleEndian.Uint32(chunk[i*4:]))
+			} else {
+				t.Data[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(chunk[i*8:])))
+			}
+		}
+		weights[name] = t
+	}
+	return weights, nil
+}
+
+// WriteSafetensors encodes weights as F32 safetensors, tensors in name order
+func WriteSafetensors(w io.Writer, weights Weights) error {
+	header := map[string]safetensorsEntry{}
+	var offset int64
+	for _, name := range weights.Names() {
+		t := weights[name]
+		size := int64(len(t.Data)) * 4
+		header[name] = safetensorsEntry{DType: "F32", Shape: t.Shape, DataOffsets: [2]int64{offset, offset + size}}
+		offset += size
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// The data section starts 8-byte aligned; the format pads the header with spaces
+	if pad := len(headerJSON) % 8; pad != 0 {
+		headerJSON = append(headerJSON, bytes.Repeat([]byte(" "), 8-pad)...)
+	}
+
+	buf := make([]byte, 8, 8+len(headerJSON)+int(offset))
+	binary.LittleEndian.PutUint64(buf, uint64(len(headerJSON)))
+	buf = append(buf, headerJSON...)
+	for _, name := range weights.Names() {
+		for _, v := range weights[name].Data {
+			buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+		}
+	}
+	_, err = w.Write(buf)
+	return err
+}
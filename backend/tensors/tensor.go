@@ -0,0 +1,83 @@
+// Package tensors handles model weights for federated learning: the safetensors files clients
+// submit, the float initializers of ONNX models, and the math that aggregates updates.
+package tensors
+
+import (
+	"math"
+	"sort"
+)
+
+// Tensor is a dense float32 tensor in row-major order
+type Tensor struct {
+	Shape []int64
+	Data  []float32
+}
+
+// NumElements is the product of the tensor's dimensions
+func (t *Tensor) NumElements() int64 {
+	n := int64(1)
+	for _, d := range t.Shape {
+		n *= d
+	}
+	return n
+}
+
+// SameShape reports whether two tensors have identical dimensions
+func (t *Tensor) SameShape(o *Tensor) bool {
+	if len(t.Shape) != len(o.Shape) {
+		return false
+	}
+	for i := range t.Shape {
+		if t.Shape[i] != o.Shape[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Weights maps tensor names to tensors
+type Weights map[string]*Tensor
+
+// Names returns the tensor names in sorted order
+func (w Weights) Names() []string {
+	names := make([]string, 0, len(w))
+	for name := range w {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NonFinite returns the name of the first tensor containing NaN or Inf, or ""
+func (w Weights) NonFinite() string {
+	for _, name := range w.Names() {
+		for _, v := range w[name].Data {
+			f := float64(v)
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// DistanceRatio returns ||w - base|| / ||base|| over the tensors of w, which must all exist in
+// base with the same shape
+func (w Weights) DistanceRatio(base Weights) float64 {
+	var diffSq, baseSq float64
+	for name, t := range w {
+		ref := base[name]
+		for i, v := range t.Data {
+			d := float64(v) - float64(ref.Data[i])
+			diffSq += d * d
+			baseSq += float64(ref.Data[i]) * float64(ref.Data[i])
+		}
+	}
+	if baseSq == 0 {
+		if diffSq == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return math.Sqrt(diffSq) / math.Sqrt(baseSq)
+}
@@ -1555,7 +1555,7 @@ class PhotoProvider with ChangeNotifier {
             }
           }
         } else if (item.type == GalleryItemType.remote && item.remote != null && masterKey != null && userId != null) {
-          final proxyUrl = '${ApiConfig().baseUrl}/images/download/${item.id}?user_id=$userId&variant=thumb_256';
+          final proxyUrl = '${ApiConfig().baseUrl}/images/download/${item.id}?variant=thumb_256';
           try {
             imageBytes = await BackupService().fetchAndDecryptFromUrl(proxyUrl, masterKey);
           } catch (e) {
@@ -26,6 +26,18 @@ class AuthService {
   String? get currentUser => _cachedSession?['username'] as String?;
   String? get currentUserEmail => _cachedSession?['email'] as String?;
 
+  /// Headers for backend requests: the session token from /login as a bearer token,
+  /// plus [extra]. The server takes the user from the token, so requests carry no user_id.
+  /// Don't send these to presigned MinIO URLs, which carry their own signature.
+  Future<Map<String, String>> authHeaders([Map<String, String>? extra]) async {
+    final session = await loadSession();
+    final token = session?['token'] as String?;
+    return {
+      if (token != null) 'Authorization': 'Bearer $token',
+      ...?extra,
+    };
+  }
+
   Future<void> init() async {
     await _cryptoService.init();
   }
@@ -34,8 +46,9 @@ class AuthService {
   
   // --- Session Persistence ---
   
-  Future<void> saveSession(String username, String email, Uint8List masterKey, Uint8List privateKey, Uint8List publicKey) async {
+  Future<void> saveSession(String username, String email, String token, Uint8List masterKey, Uint8List privateKey, Uint8List publicKey) async {
     await _storage.write(key: 'username', value: username);
+    await _storage.write(key: 'session_token', value: token);
     await _storage.write(key: 'email', value: email);
     await _storage.write(key: 'master_key', value: base64Encode(masterKey));
     await _storage.write(key: 'private_key', value: base64Encode(privateKey));
@@ -43,6 +56,7 @@ class AuthService {
     _cachedSession = {
       'username': username,
       'email': email,
+      'token': token,
       'masterKey': masterKey,
       'privateKey': privateKey,
       'publicKey': publicKey,
@@ -54,14 +68,17 @@ class AuthService {
 
     final user = await _storage.read(key: 'username');
     final email = await _storage.read(key: 'email');
+    final token = await _storage.read(key: 'session_token');
     final mk = await _storage.read(key: 'master_key');
     final pk = await _storage.read(key: 'private_key');
     final pub = await _storage.read(key: 'public_key');
 
-    if (user != null && email != null && mk != null && pk != null && pub != null) {
+    // Sessions saved before the server issued tokens have none and must log in again
+    if (user != null && email != null && token != null && mk != null && pk != null && pub != null) {
       _cachedSession = {
         'username': user,
         'email': email,
+        'token': token,
         'masterKey': base64Decode(mk),
         'privateKey': base64Decode(pk),
         'publicKey': base64Decode(pub),
@@ -72,6 +89,15 @@ class AuthService {
   }
 
   Future<void> logout() async {
+    // Revoke the session on the server too; the local session is cleared even if that fails
+    try {
+      final headers = await authHeaders();
+      if (headers.isNotEmpty) {
+        await http.post(Uri.parse('$_baseUrl/logout'), headers: headers).timeout(const Duration(seconds: 10));
+      }
+    } catch (e) {
+      print('Logout request failed: $e');
+    }
     _cachedSession = null;
     await _storage.deleteAll();
   }
@@ -146,6 +172,7 @@ class AuthService {
 
       // 2. Extract Data
       final username = data['username'] as String;
+      final token = data['token'] as String;
       final kekSalt = base64Decode(data['kek_salt']);
       final encryptedMk = base64Decode(data['encrypted_master_key']);
       final nonceMk = base64Decode(data['master_key_nonce']);
@@ -168,6 +195,7 @@ class AuthService {
       
       final keys = {
         'username': username,
+        'token': token,
         'masterKey': masterKeyBytes, // Returning bytes for display/usage
         'privateKey': privateKeyBytes,
         'publicKey': base64Decode(data['public_key']),
@@ -177,6 +205,7 @@ class AuthService {
       await saveSession(
         username,
         email,
+        token,
         keys['masterKey'] as Uint8List, 
         keys['privateKey'] as Uint8List, 
         keys['publicKey'] as Uint8List
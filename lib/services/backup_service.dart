@@ -285,8 +285,8 @@ class BackupService {
 
   Future<Set<String>> _fetchCloudChecksums(String userId) async {
     try {
-      final uri = Uri.parse('$_baseUrl/images/checksums?user_id=$userId');
-      final response = await http.get(uri);
+      final uri = Uri.parse('$_baseUrl/images/checksums');
+      final response = await http.get(uri, headers: await _auth.authHeaders());
       
       if (response.statusCode == 200) {
         final json = jsonDecode(response.body);
@@ -301,8 +301,8 @@ class BackupService {
 
   Future<Set<String>> _fetchCloudSourceIDs(String userId) async {
     try {
-      final uri = Uri.parse('$_baseUrl/images/source_ids?user_id=$userId');
-      final response = await http.get(uri);
+      final uri = Uri.parse('$_baseUrl/images/source_ids');
+      final response = await http.get(uri, headers: await _auth.authHeaders());
       
       if (response.statusCode == 200) {
         final json = jsonDecode(response.body);
@@ -319,14 +319,13 @@ class BackupService {
     try {
       final uri = Uri.parse('$_baseUrl/images/upload_urls');
       final body = jsonEncode({
-        'user_id': userId,
         'image_id': imageId,
         'variants': variants,
       });
 
       final response = await http.post(
         uri,
-        headers: {'Content-Type': 'application/json'},
+        headers: await _auth.authHeaders({'Content-Type': 'application/json'}),
         body: body,
       );
 
@@ -399,7 +398,6 @@ class BackupService {
 
       final body = jsonEncode({
         'image_id': imageId,
-        'user_id': userId,
         'created_at': asset.createDateTime.toUtc().toIso8601String(),
         'modified_at': asset.modifiedDateTime.toUtc().toIso8601String(), // AssetEntity usually has this
         'width': width,
@@ -417,7 +415,7 @@ class BackupService {
 
       final response = await http.post(
         uri,
-        headers: {'Content-Type': 'application/json'},
+        headers: await _auth.authHeaders({'Content-Type': 'application/json'}),
         body: body,
       );
 
@@ -521,7 +519,6 @@ class BackupService {
         
         final body = jsonEncode({
           'image_id': imageId,
-          'user_id': userId,
           'created_at': now,
           'modified_at': now,
           'width': width,
@@ -538,7 +535,7 @@ class BackupService {
 
         final response = await http.post(
           uri,
-          headers: {'Content-Type': 'application/json'},
+          headers: await _auth.authHeaders({'Content-Type': 'application/json'}),
           body: body,
         );
 
@@ -568,16 +565,16 @@ class BackupService {
 
   Future<RemoteImageResponse?> fetchRemoteImages(String userId, {String? cursor, String? album}) async {
     try {
-      var uriStr = '$_baseUrl/images?user_id=$userId';
+      final params = <String, String>{};
       if (cursor != null) {
-        uriStr += '&cursor=$cursor';
+        params['cursor'] = cursor;
       }
       if (album != null && album.isNotEmpty) {
-        uriStr += '&album=${Uri.encodeComponent(album)}';
+        params['album'] = album;
       }
 
-      final uri = Uri.parse(uriStr);
-      final response = await http.get(uri);
+      final uri = Uri.parse('$_baseUrl/images').replace(queryParameters: params.isEmpty ? null : params);
+      final response = await http.get(uri, headers: await _auth.authHeaders());
 
       if (response.statusCode == 200) {
         final json = jsonDecode(response.body);
@@ -592,8 +589,8 @@ class BackupService {
 
   Future<RemoteSyncResponse?> syncImages(String userId, String modifiedAfter) async {
     try {
-      final uri = Uri.parse('$_baseUrl/sync?user_id=$userId&modified_after=${Uri.encodeComponent(modifiedAfter)}');
-      final response = await http.get(uri);
+      final uri = Uri.parse('$_baseUrl/sync?modified_after=${Uri.encodeComponent(modifiedAfter)}');
+      final response = await http.get(uri, headers: await _auth.authHeaders());
 
       if (response.statusCode == 200) {
         final json = jsonDecode(response.body);
@@ -608,8 +605,8 @@ class BackupService {
 
   Future<List<Map<String, dynamic>>> fetchAlbums(String userId) async {
     try {
-      final uri = Uri.parse('$_baseUrl/albums?user_id=$userId');
-      final response = await http.get(uri);
+      final uri = Uri.parse('$_baseUrl/albums');
+      final response = await http.get(uri, headers: await _auth.authHeaders());
 
       if (response.statusCode == 200) {
         final json = jsonDecode(response.body);
@@ -629,11 +626,17 @@ class BackupService {
       final uri = _resolveUri(url);
       final String originalAuthority = Uri.parse(url).authority;
 
+      final headers = {
+        'Host': originalAuthority, // Critical for MinIO signature validation
+      };
+      // The download proxy is a backend route and needs the session; presigned URLs carry their own signature
+      if (_baseUrl.isNotEmpty && originalAuthority == Uri.parse(_baseUrl).authority) {
+        headers.addAll(await _auth.authHeaders());
+      }
+
       final response = await http.get(
         uri,
-        headers: {
-          'Host': originalAuthority, // Critical for MinIO signature validation
-        },
+        headers: headers,
       ).timeout(const Duration(seconds: 120));
       
       if (response.statusCode != 200) {
@@ -667,9 +670,9 @@ class BackupService {
 
   Future<RemoteImage?> fetchSingleRemoteImage(String userId, String imageId) async {
     try {
-      final uriStr = '$_baseUrl/images/$imageId?user_id=$userId';
+      final uriStr = '$_baseUrl/images/$imageId';
       final uri = Uri.parse(uriStr);
-      final response = await http.get(uri);
+      final response = await http.get(uri, headers: await _auth.authHeaders());
 
       if (response.statusCode == 200) {
         final json = jsonDecode(response.body);
@@ -741,8 +744,8 @@ class BackupService {
 
       // Register new version
       try {
-          final uri = Uri.parse('$_baseUrl/images/faces/register?user_id=$userId');
-          final response = await http.post(uri);
+          final uri = Uri.parse('$_baseUrl/images/faces/register');
+          final response = await http.post(uri, headers: await _auth.authHeaders());
           if (response.statusCode == 200) {
               final newVersion = jsonDecode(response.body)['version'] as int;
               await _db.setBackupSetting('people_data_version', newVersion.toString());
@@ -757,8 +760,8 @@ class BackupService {
 
   Future<int> getRemotePeopleVersion(String userId) async {
       try {
-          final uri = Uri.parse('$_baseUrl/images/faces/version?user_id=$userId');
-          final response = await http.get(uri);
+          final uri = Uri.parse('$_baseUrl/images/faces/version');
+          final response = await http.get(uri, headers: await _auth.authHeaders());
           if (response.statusCode == 200) {
               return jsonDecode(response.body)['version'] as int;
           }
@@ -812,8 +815,8 @@ class BackupService {
 
       // Register new version
       try {
-          final uri = Uri.parse('$_baseUrl/images/semantic/register?user_id=$userId');
-          final response = await http.post(uri);
+          final uri = Uri.parse('$_baseUrl/images/semantic/register');
+          final response = await http.post(uri, headers: await _auth.authHeaders());
           if (response.statusCode == 200) {
               final newVersion = jsonDecode(response.body)['version'] as int;
               await _db.setBackupSetting('semantic_data_version', newVersion.toString());
@@ -827,8 +830,8 @@ class BackupService {
 
   Future<int> getRemoteSemanticVersion(String userId) async {
       try {
-          final uri = Uri.parse('$_baseUrl/images/semantic/version?user_id=$userId');
-          final response = await http.get(uri);
+          final uri = Uri.parse('$_baseUrl/images/semantic/version');
+          final response = await http.get(uri, headers: await _auth.authHeaders());
           if (response.statusCode == 200) {
               return jsonDecode(response.body)['version'] as int;
           }
@@ -843,14 +846,14 @@ class BackupService {
   /// Deletes specified image IDs permanently from the cloud backend.
   Future<bool> deleteCloudImages(String userId, List<String> imageIds) async {
     try {
-      final uri = Uri.parse('$_baseUrl/images/delete?user_id=$userId');
+      final uri = Uri.parse('$_baseUrl/images/delete');
       final requestBody = jsonEncode({
         'image_ids': imageIds,
       });
 
       final response = await http.post(
         uri, 
-        headers: {'Content-Type': 'application/json'},
+        headers: await _auth.authHeaders({'Content-Type': 'application/json'}),
         body: requestBody,
       );
 
@@ -870,10 +873,10 @@ class BackupService {
   /// Updates the geographic location of specified cloud images.
   Future<bool> updateRemoteLocation(String userId, List<String> imageIds, double lat, double lng) async {
     try {
-      final uri = Uri.parse('$_baseUrl/images/location?user_id=$userId');
+      final uri = Uri.parse('$_baseUrl/images/location');
       final response = await http.put(
         uri,
-        headers: {'Content-Type': 'application/json'},
+        headers: await _auth.authHeaders({'Content-Type': 'application/json'}),
         body: jsonEncode({
           'image_ids': imageIds,
           'latitude': lat,
@@ -889,10 +892,10 @@ class BackupService {
 
   Future<bool> updateRemoteFavoriteStatus(String userId, List<String> imageIds, bool isFavorite) async {
     try {
-      final uri = Uri.parse('$_baseUrl/images/favorite?user_id=$userId');
+      final uri = Uri.parse('$_baseUrl/images/favorite');
       final response = await http.put(
         uri,
-        headers: {'Content-Type': 'application/json'},
+        headers: await _auth.authHeaders({'Content-Type': 'application/json'}),
         body: jsonEncode({
           'image_ids': imageIds,
           'is_favorite': isFavorite,
@@ -958,7 +961,6 @@ class BackupService {
       
       final body = jsonEncode({
         'image_id': imageId,
-        'user_id': userId,
         'created_at': now,
         'modified_at': now,
         'width': width,
@@ -975,7 +977,7 @@ class BackupService {
 
       final response = await http.post(
         uri,
-        headers: {'Content-Type': 'application/json'},
+        headers: await _auth.authHeaders({'Content-Type': 'application/json'}),
         body: body,
       );
 
@@ -1044,7 +1046,6 @@ class BackupService {
       
       final body = jsonEncode({
         'image_id': imageId,
-        'user_id': userId,
         'created_at': now,
         'modified_at': now,
         'width': width,
@@ -1061,7 +1062,7 @@ class BackupService {
 
       final response = await http.post(
         uri,
-        headers: {'Content-Type': 'application/json'},
+        headers: await _auth.authHeaders({'Content-Type': 'application/json'}),
         body: body,
       );
 
@@ -1075,7 +1076,7 @@ class BackupService {
   /// Updates the assigned album for specified cloud images.
   Future<bool> updateCloudAlbum(String userId, List<String> imageIds, String albumName) async {
     try {
-      final uri = Uri.parse('$_baseUrl/images/album?user_id=$userId');
+      final uri = Uri.parse('$_baseUrl/images/album');
       final requestBody = jsonEncode({
         'image_ids': imageIds,
         'album_name': albumName,
@@ -1083,7 +1084,7 @@ class BackupService {
 
       final response = await http.put(
         uri,
-        headers: {'Content-Type': 'application/json'},
+        headers: await _auth.authHeaders({'Content-Type': 'application/json'}),
         body: requestBody,
       );
 
@@ -1104,12 +1105,11 @@ class BackupService {
       if (kIsWeb) return false;
       final session = await _auth.loadSession();
       if (session == null) return false;
-      final userId = session['username'] as String;
       final masterKeyBytes = session['masterKey'] as Uint8List;
       final masterKey = SecureKey.fromList(_crypto.sodium, masterKeyBytes);
 
-      final uri = Uri.parse('$_baseUrl/images/faces?user_id=$userId');
-      final response = await http.get(uri);
+      final uri = Uri.parse('$_baseUrl/images/faces');
+      final response = await http.get(uri, headers: await _auth.authHeaders());
       if (response.statusCode != 200) return false;
 
       final resBody = jsonDecode(response.body);
@@ -1194,12 +1194,11 @@ class BackupService {
       if (kIsWeb) return false;
       final session = await _auth.loadSession();
       if (session == null) return false;
-      final userId = session['username'] as String;
       final masterKeyBytes = session['masterKey'] as Uint8List;
       final masterKey = SecureKey.fromList(_crypto.sodium, masterKeyBytes);
 
-      final uri = Uri.parse('$_baseUrl/images/semantic?user_id=$userId');
-      final response = await http.get(uri);
+      final uri = Uri.parse('$_baseUrl/images/semantic');
+      final response = await http.get(uri, headers: await _auth.authHeaders());
       if (response.statusCode != 200) return false;
 
       final resBody = jsonDecode(response.body);
@@ -17,7 +17,8 @@ import 'api_config.dart';
 
 class FederatedLearningService {
   static String get serverUrl => ApiConfig().baseUrl;
-  static const String globalModelFilename = "face-detection.onnx";
+  static const String flModelName = ModelService.faceDetectionModelName;
+  static const String globalModelFilename = "$flModelName.onnx";
   static const String lastUpdatedKey = "fl_last_updated";
 
   final ModelService _modelService = ModelService();
@@ -35,6 +36,20 @@ class FederatedLearningService {
   Future<void> trainAndUpload({void Function(double progress, String status)? onProgress}) async {
     onProgress?.call(0, "Checking for latest model...");
     await _downloadGlobalModel();
+
+    // Updates are only accepted for the current round, trained from its base model version
+    final Map<String, dynamic> round;
+    try {
+      final res = await http.get(Uri.parse('$serverUrl/fl/round?model=$flModelName'));
+      if (res.statusCode != 200) {
+        onProgress?.call(0, "Could not fetch training round (${res.statusCode})");
+        return;
+      }
+      round = jsonDecode(res.body)['round'] as Map<String, dynamic>;
+    } catch (e) {
+      onProgress?.call(0, "Could not fetch training round: $e");
+      return;
+    }
     
     final appDir = await getApplicationDocumentsDirectory();
     final modelFile = File('${appDir.path}/$globalModelFilename');
@@ -44,9 +59,12 @@ class FederatedLearningService {
       return;
     }
 
-    final updatedModelPath = "${modelFile.path}_update.pth";
+    final updatedModelPath = "${modelFile.path}_update.safetensors";
     final updatedFile = File(updatedModelPath);
 
+    // Training metadata sent with the update; the server weights updates by sample_count
+    final Map<String, dynamic> metadata = {'base_version': round['base_version'] ?? ''};
+
     if (!kIsWeb && (Platform.isWindows || Platform.isLinux)) {
       onProgress?.call(0, "Preparing Training Data...");
       
@@ -125,6 +143,10 @@ class FederatedLearningService {
                 // Scale progress to 20% - 90% range
                 onProgress?.call(0.2 + (current / total * 0.7), "Training AI Model...");
               } catch (_) {}
+            } else if (line.startsWith("METRICS: ")) {
+              try {
+                metadata.addAll(jsonDecode(line.substring(9)) as Map<String, dynamic>);
+              } catch (_) {}
             } else if (line.startsWith("STATUS: ")) {
               onProgress?.call(-1, line.substring(8)); // -1 means keep current progress
             }
@@ -173,6 +195,7 @@ class FederatedLearningService {
       onProgress?.call(0.5, "Mobile training started...");
       try {
         await FlTrainingPlugin.train(modelFile.path, 1, 32); 
+        metadata['epochs'] = 1;
         onProgress?.call(0.9, "Training completed. Uploading...");
       } catch (e) {
         onProgress?.call(0, "Mobile training failed: $e");
@@ -187,12 +210,22 @@ class FederatedLearningService {
     
     // 4. Upload Update
     try {
+      // The server credits the update to the session's user
+      final headers = await AuthService().authHeaders();
+      if (headers.isEmpty) {
+        onProgress?.call(0, "Log in to upload training updates.");
+        return;
+      }
       var request = http.MultipartRequest('POST', Uri.parse('$serverUrl/fl/update'));
+      request.headers.addAll(headers);
+      request.fields['model_name'] = flModelName;
+      request.fields['round_id'] = '${round['id']}';
+      request.fields['metadata'] = jsonEncode(metadata);
       request.files.add(
         await http.MultipartFile.fromPath(
           'model', 
           updatedFile.path,
-          filename: 'local_update.pth',
+          filename: 'local_update.safetensors',
         ),
       );
       
@@ -201,6 +234,10 @@ class FederatedLearningService {
         onProgress?.call(1.0, "Success! Model improved and uploaded.");
         final prefs = await SharedPreferences.getInstance();
         await prefs.setInt(lastUpdatedKey, DateTime.now().millisecondsSinceEpoch);
+      } else if (res.statusCode == 401) {
+        onProgress?.call(0, "Session expired. Log in again to upload training updates.");
+      } else if (res.statusCode == 409) {
+        onProgress?.call(0, "Training round closed before upload. Try again with the latest model.");
       } else {
         onProgress?.call(0, "Upload failed: ${res.statusCode}");
       }
@@ -70,7 +70,7 @@ class ShareService {
         params['exclude'] = excludeUsername;
       }
       final uri = Uri.parse('$_baseUrl/users/search').replace(queryParameters: params);
-      final response = await http.get(uri);
+      final response = await http.get(uri, headers: await AuthService().authHeaders());
       if (response.statusCode != 200) return [];
       final json = jsonDecode(response.body);
       final list = json['usernames'] as List? ?? [];
@@ -84,7 +84,7 @@ class ShareService {
   Future<Uint8List?> getReceiverPublicKey(String username) async {
     try {
       final uri = Uri.parse('$_baseUrl/users/$username/public-key');
-      final response = await http.get(uri);
+      final response = await http.get(uri, headers: await AuthService().authHeaders());
       if (response.statusCode != 200) return null;
       final json = jsonDecode(response.body);
       final keyB64 = json['public_key'] as String?;
@@ -108,7 +108,6 @@ class ShareService {
     final session = await AuthService().loadSession();
     if (session == null) return null;
 
-    final userId = session['username'] as String;
     final receiverPk = await getReceiverPublicKey(receiverUsername);
     if (receiverPk == null || receiverPk.length != 32) {
       print('ShareService: Could not get receiver public key');
@@ -128,7 +127,7 @@ class ShareService {
         return null;
       }
 
-      final createUri = Uri.parse('$_baseUrl/shares?user_id=$userId');
+      final createUri = Uri.parse('$_baseUrl/shares');
       final createBody = jsonEncode({
         'receiver_username': receiverUsername,
         'image_id': imageId,
@@ -141,7 +140,7 @@ class ShareService {
 
       final createResp = await http.post(
         createUri,
-        headers: {'Content-Type': 'application/json'},
+        headers: await AuthService().authHeaders({'Content-Type': 'application/json'}),
         body: createBody,
       );
 
@@ -155,7 +154,7 @@ class ShareService {
       if (shareId == null) return null;
 
       // 2. Get upload URL and upload encrypted image
-      final uploadUrlResp = await http.get(Uri.parse('$_baseUrl/shares/$shareId/upload-url?user_id=$userId'));
+      final uploadUrlResp = await http.get(Uri.parse('$_baseUrl/shares/$shareId/upload-url'), headers: await AuthService().authHeaders());
       if (uploadUrlResp.statusCode != 200) {
         print('ShareService: Get upload URL failed');
         return null;
@@ -196,8 +195,8 @@ class ShareService {
     if (session == null) return [];
 
     try {
-      final uri = Uri.parse('$_baseUrl/shares/with-me?user_id=${session['username']}');
-      final response = await http.get(uri);
+      final uri = Uri.parse('$_baseUrl/shares/with-me');
+      final response = await http.get(uri, headers: await AuthService().authHeaders());
       if (response.statusCode != 200) return [];
 
       final json = jsonDecode(response.body);
@@ -230,8 +229,8 @@ class ShareService {
     if (session == null) return [];
 
     try {
-      final uri = Uri.parse('$_baseUrl/shares/by-me?user_id=${session['username']}');
-      final response = await http.get(uri);
+      final uri = Uri.parse('$_baseUrl/shares/by-me');
+      final response = await http.get(uri, headers: await AuthService().authHeaders());
       if (response.statusCode != 200) return [];
 
       final json = jsonDecode(response.body);
@@ -285,8 +284,8 @@ class ShareService {
       }
 
       // 1. Get share metadata (encrypted_share_key, sender_public_key)
-      final metaUri = Uri.parse('$_baseUrl/shares/$shareId?user_id=$userId');
-      final metaResp = await http.get(metaUri);
+      final metaUri = Uri.parse('$_baseUrl/shares/$shareId');
+      final metaResp = await http.get(metaUri, headers: await AuthService().authHeaders());
       if (metaResp.statusCode != 200) {
         print('ShareService: Metadata fetch failed: ${metaResp.statusCode} for $metaUri');
         return null;
@@ -329,8 +328,8 @@ class ShareService {
       final shareKey = crypto.unsealFromSender(encShareKey, ourPk, ourSk);
 
       // 2. Get download URL
-      final urlUri = Uri.parse('$_baseUrl/shares/$shareId/download-url?user_id=$userId');
-      final urlResp = await http.get(urlUri);
+      final urlUri = Uri.parse('$_baseUrl/shares/$shareId/download-url');
+      final urlResp = await http.get(urlUri, headers: await AuthService().authHeaders());
       if (urlResp.statusCode != 200) {
         print('ShareService: Download URL fetch failed: ${urlResp.statusCode} for $urlUri');
         return null;
@@ -378,8 +377,8 @@ class ShareService {
     if (session == null) return false;
 
     try {
-      final uri = Uri.parse('$_baseUrl/shares/$shareId?user_id=${session['username']}');
-      final response = await http.delete(uri);
+      final uri = Uri.parse('$_baseUrl/shares/$shareId');
+      final response = await http.delete(uri, headers: await AuthService().authHeaders());
       return response.statusCode == 200;
     } catch (e) {
       print('ShareService: revokeShare error: $e');
@@ -393,8 +392,8 @@ class ShareService {
     if (session == null) return false;
 
     try {
-      final uri = Uri.parse('$_baseUrl/shares/$shareId/received?user_id=${session['username']}');
-      final response = await http.delete(uri);
+      final uri = Uri.parse('$_baseUrl/shares/$shareId/received');
+      final response = await http.delete(uri, headers: await AuthService().authHeaders());
       return response.statusCode == 200;
     } catch (e) {
       print('ShareService: deleteReceivedShare error: $e');
@@ -1,5 +1,7 @@
 import sys
 import os
+import json
+import time
 import sqlite3
 import numpy as np
 
@@ -201,7 +203,69 @@ class FederatedLocalFacesDataset(Dataset):
             self.conn.close()
         except: pass
 
+def normalize_name(name):
+    """
+    Normalizes onnx2torch state dict keys and ONNX initializer names to a common form so they
+    can be matched, e.g. 'model.0.conv.weight' and '/model.0/conv/Conv.weight' both become '0'.
+    """
+    n = name.replace('/', '.').strip('.')
+    if n.startswith('model.'): n = n[6:]
+    if n.startswith('model'): n = n[5:]
+    n = n.replace('.Conv.', '.').replace('.conv.', '.')
+    n = n.replace('.weight', '').replace('.bias', '')
+    return n.strip('.')
+
+
+def onnx_named_weights(state_dict, onnx_model):
+    """
+    Maps trained parameters back onto the float initializers of the ONNX model they came from.
+    Returns {initializer name: float32 array in the initializer's layout}; the server aggregates
+    and patches the ONNX graph with these directly.
+    """
+    by_name = {}
+    for key, value in state_dict.items():
+        if value.is_floating_point():
+            kind = 'weight' if 'weight' in key.lower() else 'bias'
+            by_name.setdefault((normalize_name(key), kind), value.detach().cpu().numpy())
+
+    out = {}
+    for init in onnx_model.graph.initializer:
+        if init.data_type != onnx.TensorProto.FLOAT:
+            continue
+        kind = 'weight' if 'weight' in init.name.lower() else 'bias'
+        param = by_name.get((normalize_name(init.name), kind))
+        if param is None:
+            continue
+        dims = list(init.dims)
+        if list(param.shape) == dims:
+            out[init.name] = np.ascontiguousarray(param, dtype=np.float32)
+        elif list(param.shape[::-1]) == dims:
+            out[init.name] = np.ascontiguousarray(param.T, dtype=np.float32)
+    return out
+
+
+def save_safetensors(tensors, path):
+    """
+    Writes float32 arrays in the safetensors format: an 8-byte little-endian header length,
+    a JSON header of dtype, shape and byte offsets, then the raw data.
+    """
+    header = {}
+    offset = 0
+    for name in sorted(tensors):
+        size = tensors[name].nbytes
+        header[name] = {"dtype": "F32", "shape": list(tensors[name].shape), "data_offsets": [offset, offset + size]}
+        offset += size
+    header_bytes = json.dumps(header, separators=(',', ':')).encode('utf-8')
+    header_bytes += b' ' * (-len(header_bytes) % 8)
+    with open(path, 'wb') as f:
+        f.write(len(header_bytes).to_bytes(8, 'little'))
+        f.write(header_bytes)
+        for name in sorted(tensors):
+            f.write(tensors[name].astype('<f4').tobytes())
+
+
 def train_local_ssl(model_path, output_path, db_path, cache_dir=None, epochs=1):
+    started = time.time()
     print(f"Loading ONNX base model from {model_path}...")
     
     # 1. Convert downloaded generic ONNX model back to active PyTorch graph using onnx2torch
@@ -255,6 +319,8 @@ def train_local_ssl(model_path, output_path, db_path, cache_dir=None, epochs=1):
 
     print(f"STATUS: Starting Genuine Self-Supervised Edge Training on {len(dataset)} valid local image patches.")
     
+    sample_count = len(dataset)
+    avg_loss = None
     accumulation_steps = 4
     for epoch in range(epochs):
         epoch_loss = 0.0
@@ -336,22 +402,32 @@ def train_local_ssl(model_path, output_path, db_path, cache_dir=None, epochs=1):
     
     dataset.close()
 
-    # 4. Save State Dict directly
+    # 4. Save the weights under their ONNX initializer names
     print(f"STATUS: Self-Supervised Local training loop complete. Saving optimized weights...")
     pytorch_model.eval()
     
     try:
-        torch.save(pytorch_model.state_dict(), output_path)
-        print(f"STATUS: Saved PyTorch StateDict to {output_path}")
+        weights = onnx_named_weights(pytorch_model.state_dict(), onnx_model)
+        if not weights:
+            raise RuntimeError("no trained parameters matched the ONNX initializers")
+        save_safetensors(weights, output_path)
+        print(f"STATUS: Saved {len(weights)} tensors to {output_path}")
+        # Training metadata for the server, which weights each update by its sample count
+        print("METRICS: " + json.dumps({
+            "sample_count": sample_count,
+            "epochs": epochs,
+            "local_loss": avg_loss,
+            "training_seconds": round(time.time() - started, 1),
+        }))
     except Exception as e:
         print(f"STATUS: Weight saving failed: {e}")
-        # If it fails, we shouldn't copy the ONNX since the backend expects .pth now. 
+        # If it fails, we shouldn't copy the ONNX since the backend expects safetensors now. 
         # Just exit with error so Dart knows it failed.
         sys.exit(1)
 
 if __name__ == "__main__":
     if len(sys.argv) < 4:
-        print("Usage: python desktop_train.py <input.onnx> <output.pth> <chithram_faces.db path> [cache_dir]")
+        print("Usage: python desktop_train.py <input.onnx> <output.safetensors> <chithram_faces.db path> [cache_dir]")
         sys.exit(1)
         
     model_path = sys.argv[1]